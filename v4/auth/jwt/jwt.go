@@ -1,14 +1,21 @@
 package jwt
 
 import (
+	"errors"
 	"sync"
 	"time"
 
 	jwtToken "github.com/go-micro/plugins/v4/auth/jwt/token"
+	"github.com/google/uuid"
 	"go-micro.dev/v4/auth"
 	"go-micro.dev/v4/util/cmd"
 )
 
+// Revoker is implemented by the jwt auth to revoke tokens before they expire.
+type Revoker interface {
+	Revoke(token string) error
+}
+
 func init() {
 	cmd.DefaultAuths["jwt"] = NewAuth
 }
@@ -20,19 +27,34 @@ func NewAuth(opts ...auth.Option) auth.Auth {
 	return j
 }
 
-func NewRules() auth.Rules {
-	return new(jwtRules)
+// NewAuthWithProvider returns a new instance of the Auth service generating
+// and inspecting tokens with the provider, e.g. one created with a key set
+// or JWKS using the token package.
+func NewAuthWithProvider(p jwtToken.Provider, opts ...auth.Option) auth.Auth {
+	j := &jwt{provider: p}
+	j.Init(opts...)
+	return j
+}
+
+// NewAuthWithTokenOptions returns a new instance of the Auth service whose
+// token provider is created with the options, after the keys of the auth
+// options. The instances of a service share the revoked and used tokens
+// with jwtToken.WithStore, and lock the use of refresh tokens with
+// jwtToken.WithSync.
+func NewAuthWithTokenOptions(tokenOpts []jwtToken.Option, opts ...auth.Option) auth.Auth {
+	j := &jwt{tokenOpts: tokenOpts}
+	j.Init(opts...)
+	return j
 }
 
 type jwt struct {
 	sync.Mutex
 	options auth.Options
 	jwt     jwtToken.Provider
-}
-
-type jwtRules struct {
-	sync.Mutex
-	rules []*auth.Rule
+	// provider set by NewAuthWithProvider
+	provider jwtToken.Provider
+	// options of the provider set by NewAuthWithTokenOptions
+	tokenOpts []jwtToken.Option
 }
 
 func (j *jwt) String() string {
@@ -47,10 +69,15 @@ func (j *jwt) Init(opts ...auth.Option) {
 		o(&j.options)
 	}
 
-	j.jwt = jwtToken.New(
+	if j.provider != nil {
+		j.jwt = j.provider
+		return
+	}
+
+	j.jwt = jwtToken.New(append([]jwtToken.Option{
 		jwtToken.WithPrivateKey(j.options.PrivateKey),
 		jwtToken.WithPublicKey(j.options.PublicKey),
-	)
+	}, j.tokenOpts...)...)
 }
 
 func (j *jwt) Options() auth.Options {
@@ -71,7 +98,7 @@ func (j *jwt) Generate(id string, opts ...auth.GenerateOption) (*auth.Account, e
 
 	// generate a JWT secret which can be provided to the Token() method
 	// and exchanged for an access token
-	secret, err := j.jwt.Generate(account)
+	secret, err := j.jwt.Generate(account, jwtToken.WithType(jwtToken.TypeSecret))
 	if err != nil {
 		return nil, err
 	}
@@ -81,53 +108,104 @@ func (j *jwt) Generate(id string, opts ...auth.GenerateOption) (*auth.Account, e
 	return account, nil
 }
 
-func (j *jwtRules) Grant(rule *auth.Rule) error {
-	j.Lock()
-	defer j.Unlock()
-	j.rules = append(j.rules, rule)
-	return nil
+func (j *jwt) Inspect(token string) (*auth.Account, error) {
+	r, ok := j.jwt.(jwtToken.Revoker)
+	if !ok {
+		return j.jwt.Inspect(token)
+	}
+
+	claims, err := r.Claims(token)
+	if err != nil {
+		return nil, err
+	}
+	// refresh tokens can only be exchanged for new tokens
+	if claims.Type == jwtToken.TypeRefresh {
+		return nil, jwtToken.ErrInvalidToken
+	}
+	return claims.Account, nil
 }
 
-func (j *jwtRules) Revoke(rule *auth.Rule) error {
-	j.Lock()
-	defer j.Unlock()
+// Token exchanges a secret or refresh token for an access token and a
+// refresh token. Refresh tokens are single-use, presenting one twice
+// revokes every token issued from the same secret.
+func (j *jwt) Token(opts ...auth.TokenOption) (*auth.Token, error) {
+	options := auth.NewTokenOptions(opts...)
 
-	rules := make([]*auth.Rule, 0, len(j.rules))
-	for _, r := range j.rules {
-		if r.ID != rule.ID {
-			rules = append(rules, r)
-		}
+	r, ok := j.jwt.(jwtToken.Revoker)
+	if !ok {
+		return j.token(options)
 	}
 
-	j.rules = rules
-	return nil
-}
+	var (
+		claims *jwtToken.Claims
+		err    error
+	)
 
-func (j *jwtRules) Verify(acc *auth.Account, res *auth.Resource, opts ...auth.VerifyOption) error {
-	j.Lock()
-	defer j.Unlock()
+	refreshExpiry := options.Expiry + time.Hour
 
-	var options auth.VerifyOptions
-	for _, o := range opts {
-		o(&options)
+	if len(options.Secret) > 0 {
+		claims, err = r.Claims(options.Secret)
+		if err != nil {
+			return nil, err
+		}
+		// tokens issued by earlier versions have no type
+		if claims.Type != jwtToken.TypeSecret && len(claims.Type) > 0 {
+			return nil, jwtToken.ErrInvalidToken
+		}
+		// start a new token family
+		claims.Family = uuid.New().String()
+	} else {
+		claims, err = r.Claims(options.RefreshToken)
+		if err != nil {
+			return nil, err
+		}
+		if claims.Type != jwtToken.TypeRefresh || len(claims.Family) == 0 {
+			return nil, jwtToken.ErrInvalidToken
+		}
+
+		if err := r.Use(claims.ID, claims.Expiry); err == jwtToken.ErrReusedToken {
+			// the refresh token leaked, revoke its whole family
+			expiry := time.Now().Add(refreshExpiry)
+			if claims.Expiry.After(expiry) {
+				expiry = claims.Expiry
+			}
+			if rerr := r.Revoke(claims.Family, expiry); rerr != nil {
+				return nil, rerr
+			}
+			return nil, err
+		} else if err != nil {
+			return nil, err
+		}
 	}
 
-	return auth.Verify(j.rules, acc, res)
-}
+	access, err := j.jwt.Generate(claims.Account,
+		jwtToken.WithExpiry(options.Expiry),
+		jwtToken.WithType(jwtToken.TypeAccess),
+		jwtToken.WithFamily(claims.Family),
+	)
+	if err != nil {
+		return nil, err
+	}
 
-func (j *jwtRules) List(opts ...auth.ListOption) ([]*auth.Rule, error) {
-	j.Lock()
-	defer j.Unlock()
-	return j.rules, nil
-}
+	refresh, err := j.jwt.Generate(claims.Account,
+		jwtToken.WithExpiry(refreshExpiry),
+		jwtToken.WithType(jwtToken.TypeRefresh),
+		jwtToken.WithFamily(claims.Family),
+	)
+	if err != nil {
+		return nil, err
+	}
 
-func (j *jwt) Inspect(token string) (*auth.Account, error) {
-	return j.jwt.Inspect(token)
+	return &auth.Token{
+		Created:      access.Created,
+		Expiry:       access.Expiry,
+		AccessToken:  access.Token,
+		RefreshToken: refresh.Token,
+	}, nil
 }
 
-func (j *jwt) Token(opts ...auth.TokenOption) (*auth.Token, error) {
-	options := auth.NewTokenOptions(opts...)
-
+// token exchanges tokens with providers not supporting revocation.
+func (j *jwt) token(options auth.TokenOptions) (*auth.Token, error) {
 	secret := options.RefreshToken
 	if len(options.Secret) > 0 {
 		secret = options.Secret
@@ -155,3 +233,22 @@ func (j *jwt) Token(opts ...auth.TokenOption) (*auth.Token, error) {
 		RefreshToken: refresh.Token,
 	}, nil
 }
+
+// Revoke the token before it expires. Revoking a refresh token also
+// revokes the other tokens of its family, e.g. to log out.
+func (j *jwt) Revoke(token string) error {
+	r, ok := j.jwt.(jwtToken.Revoker)
+	if !ok {
+		return errors.New("token provider does not support revocation")
+	}
+
+	claims, err := r.Claims(token)
+	if err != nil {
+		return err
+	}
+
+	if claims.Type == jwtToken.TypeRefresh && len(claims.Family) > 0 {
+		return r.Revoke(claims.Family, claims.Expiry)
+	}
+	return r.Revoke(claims.ID, claims.Expiry)
+}
@@ -0,0 +1,183 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"testing"
+	"time"
+
+	jwtToken "github.com/go-micro/plugins/v4/auth/jwt/token"
+	"go-micro.dev/v4/auth"
+	"go-micro.dev/v4/store"
+)
+
+func testAuth(t *testing.T) auth.Auth {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwtToken.NewKey("test", "EdDSA", priv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := jwtToken.New(
+		jwtToken.WithKeySet(jwtToken.NewStaticKeySet(key)),
+		jwtToken.WithStore(store.NewMemoryStore()),
+	)
+	return NewAuthWithProvider(p, auth.Namespace("test"))
+}
+
+func TestTokenTypes(t *testing.T) {
+	a := testAuth(t)
+
+	acc, err := a.Generate("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tok, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret))
+	if err != nil {
+		t.Fatalf("Token returned %v error, expected nil", err)
+	}
+
+	if _, err := a.Inspect(tok.AccessToken); err != nil {
+		t.Fatalf("Inspect returned %v error, expected nil", err)
+	}
+	if _, err := a.Inspect(tok.RefreshToken); err != jwtToken.ErrInvalidToken {
+		t.Fatalf("Inspect of refresh token returned %v error, expected %v", err, jwtToken.ErrInvalidToken)
+	}
+
+	// an access token can't be used to get new tokens
+	if _, err := a.Token(auth.WithToken(tok.AccessToken)); err != jwtToken.ErrInvalidToken {
+		t.Fatalf("Token returned %v error, expected %v", err, jwtToken.ErrInvalidToken)
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	a := testAuth(t)
+
+	acc, err := a.Generate("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := a.Token(auth.WithToken(tok.RefreshToken))
+	if err != nil {
+		t.Fatalf("Token returned %v error, expected nil", err)
+	}
+	if _, err := a.Inspect(rotated.AccessToken); err != nil {
+		t.Fatalf("Inspect returned %v error, expected nil", err)
+	}
+
+	// reusing the first refresh token revokes the family
+	if _, err := a.Token(auth.WithToken(tok.RefreshToken)); err != jwtToken.ErrReusedToken {
+		t.Fatalf("Token returned %v error, expected %v", err, jwtToken.ErrReusedToken)
+	}
+	if _, err := a.Token(auth.WithToken(rotated.RefreshToken)); err != jwtToken.ErrRevokedToken {
+		t.Fatalf("Token returned %v error, expected %v", err, jwtToken.ErrRevokedToken)
+	}
+	for _, at := range []string{tok.AccessToken, rotated.AccessToken} {
+		if _, err := a.Inspect(at); err != jwtToken.ErrRevokedToken {
+			t.Fatalf("Inspect returned %v error, expected %v", err, jwtToken.ErrRevokedToken)
+		}
+	}
+
+	// the secret can still be used to start a new family
+	if _, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret), auth.WithExpiry(time.Minute)); err != nil {
+		t.Fatalf("Token returned %v error, expected nil", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	a := testAuth(t)
+
+	acc, err := a.Generate("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok2, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := a.(Revoker)
+
+	if err := r.Revoke(tok.AccessToken); err != nil {
+		t.Fatalf("Revoke returned %v error, expected nil", err)
+	}
+	if _, err := a.Inspect(tok.AccessToken); err != jwtToken.ErrRevokedToken {
+		t.Fatalf("Inspect returned %v error, expected %v", err, jwtToken.ErrRevokedToken)
+	}
+	// the refresh token of the family is still valid
+	if _, err := a.Token(auth.WithToken(tok.RefreshToken)); err != nil {
+		t.Fatalf("Token returned %v error, expected nil", err)
+	}
+
+	// logging out with the refresh token revokes its family only
+	if err := r.Revoke(tok2.RefreshToken); err != nil {
+		t.Fatalf("Revoke returned %v error, expected nil", err)
+	}
+	if _, err := a.Inspect(tok2.AccessToken); err != jwtToken.ErrRevokedToken {
+		t.Fatalf("Inspect returned %v error, expected %v", err, jwtToken.ErrRevokedToken)
+	}
+
+	// revoking the secret stops it being exchanged
+	if err := r.Revoke(acc.Secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret)); err != jwtToken.ErrRevokedToken {
+		t.Fatalf("Token returned %v error, expected %v", err, jwtToken.ErrRevokedToken)
+	}
+}
+
+func TestTokenStore(t *testing.T) {
+	privKey, err := os.ReadFile("token/test/sample_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := os.ReadFile("token/test/sample_key.pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// instances of a service sharing the token store
+	s := store.NewMemoryStore()
+	newAuth := func() auth.Auth {
+		return NewAuthWithTokenOptions(
+			[]jwtToken.Option{jwtToken.WithStore(s)},
+			auth.PrivateKey(string(privKey)),
+			auth.PublicKey(string(pubKey)),
+		)
+	}
+	a, b := newAuth(), newAuth()
+
+	acc, err := a.Generate("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := a.Token(auth.WithCredentials(acc.ID, acc.Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the refresh token used by an instance can't be used by the other
+	if _, err := a.Token(auth.WithToken(tok.RefreshToken)); err != nil {
+		t.Fatalf("Token returned %v error, expected nil", err)
+	}
+	if _, err := b.Token(auth.WithToken(tok.RefreshToken)); err != jwtToken.ErrReusedToken {
+		t.Fatalf("Token returned %v error, expected %v", err, jwtToken.ErrReusedToken)
+	}
+	if _, err := b.Inspect(tok.AccessToken); err != jwtToken.ErrRevokedToken {
+		t.Fatalf("Inspect returned %v error, expected %v", err, jwtToken.ErrRevokedToken)
+	}
+}
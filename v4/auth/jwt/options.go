@@ -0,0 +1,114 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"go-micro.dev/v4/auth"
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/store"
+)
+
+var (
+	// DefaultNamespace is the namespace rules are granted in.
+	DefaultNamespace = "default"
+	// DefaultRulesTopic is the broker topic rule changes are published to.
+	DefaultRulesTopic = "go.micro.auth.rules"
+	// DefaultRefreshInterval is how often rules are reloaded from the store.
+	DefaultRefreshInterval = time.Minute
+)
+
+type RulesOptions struct {
+	// Store persists the rules
+	Store store.Store
+	// Namespace rules are granted in and verified against
+	Namespace string
+	// Broker propagates rule changes to other instances, optional
+	Broker broker.Broker
+	// Topic rule changes are published to
+	Topic string
+	// RefreshInterval is how often rules are reloaded from the store
+	RefreshInterval time.Duration
+}
+
+type RulesOption func(o *RulesOptions)
+
+// WithStore sets the store rules are persisted in.
+func WithStore(s store.Store) RulesOption {
+	return func(o *RulesOptions) {
+		o.Store = s
+	}
+}
+
+// WithNamespace sets the namespace rules are granted in.
+func WithNamespace(ns string) RulesOption {
+	return func(o *RulesOptions) {
+		o.Namespace = ns
+	}
+}
+
+// WithBroker propagates rule changes to other instances over the broker.
+func WithBroker(b broker.Broker) RulesOption {
+	return func(o *RulesOptions) {
+		o.Broker = b
+	}
+}
+
+// WithTopic sets the broker topic rule changes are published to.
+func WithTopic(t string) RulesOption {
+	return func(o *RulesOptions) {
+		o.Topic = t
+	}
+}
+
+// WithRefreshInterval sets how often rules are reloaded from the store.
+func WithRefreshInterval(d time.Duration) RulesOption {
+	return func(o *RulesOptions) {
+		o.RefreshInterval = d
+	}
+}
+
+// NewRulesOptions from a slice of options.
+func NewRulesOptions(opts ...RulesOption) RulesOptions {
+	options := RulesOptions{
+		Namespace:       DefaultNamespace,
+		Topic:           DefaultRulesTopic,
+		RefreshInterval: DefaultRefreshInterval,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	// set default store
+	if options.Store == nil {
+		options.Store = store.DefaultStore
+	}
+	return options
+}
+
+type listNamespaceKey struct{}
+type listLimitKey struct{}
+type listOffsetKey struct{}
+
+// ListNamespace lists the rules of the namespace.
+func ListNamespace(ns string) auth.ListOption {
+	return listValue(listNamespaceKey{}, ns)
+}
+
+// ListLimit limits the number of rules listed.
+func ListLimit(n uint) auth.ListOption {
+	return listValue(listLimitKey{}, n)
+}
+
+// ListOffset skips the first n rules, combined with ListLimit it supports pagination.
+func ListOffset(n uint) auth.ListOption {
+	return listValue(listOffsetKey{}, n)
+}
+
+func listValue(k, v interface{}) auth.ListOption {
+	return func(o *auth.ListOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
@@ -0,0 +1,249 @@
+package jwt
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/auth"
+	"go-micro.dev/v4/broker"
+	log "go-micro.dev/v4/logger"
+	"go-micro.dev/v4/store"
+)
+
+// rulesPrefix is the store key prefix rules are persisted under, followed
+// by the namespace and rule id.
+const rulesPrefix = "rules/"
+
+// NewRules returns rules persisted in the store. Changes are picked up by
+// the other instances sharing the store when reloading it, or immediately
+// when a broker is set.
+func NewRules(opts ...RulesOption) auth.Rules {
+	j := &jwtRules{
+		options: NewRulesOptions(opts...),
+		exit:    make(chan bool),
+	}
+
+	// best-effort load the rules
+	if err := j.load(); err != nil {
+		log.Errorf("Error loading auth rules: %v", err)
+	}
+
+	if b := j.options.Broker; b != nil {
+		sub, err := b.Subscribe(j.options.Topic, j.handle)
+		if err != nil {
+			log.Errorf("Error subscribing to auth rules topic %s: %v", j.options.Topic, err)
+		}
+		j.sub = sub
+	}
+
+	go j.run()
+
+	return j
+}
+
+type jwtRules struct {
+	options RulesOptions
+
+	sync.RWMutex
+	// rules of the namespace
+	rules []*auth.Rule
+
+	sub  broker.Subscriber
+	exit chan bool
+	once sync.Once
+}
+
+func (j *jwtRules) Grant(rule *auth.Rule) error {
+	b, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+
+	err = j.options.Store.Write(&store.Record{
+		Key:   ruleKey(j.options.Namespace, rule.ID),
+		Value: b,
+	})
+	if err != nil {
+		return err
+	}
+
+	j.Lock()
+	rules := make([]*auth.Rule, 0, len(j.rules)+1)
+	for _, r := range j.rules {
+		if r.ID != rule.ID {
+			rules = append(rules, r)
+		}
+	}
+	j.rules = append(rules, rule)
+	j.Unlock()
+
+	j.publish(rule.ID)
+	return nil
+}
+
+func (j *jwtRules) Revoke(rule *auth.Rule) error {
+	err := j.options.Store.Delete(ruleKey(j.options.Namespace, rule.ID))
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	j.Lock()
+	rules := make([]*auth.Rule, 0, len(j.rules))
+	for _, r := range j.rules {
+		if r.ID != rule.ID {
+			rules = append(rules, r)
+		}
+	}
+	j.rules = rules
+	j.Unlock()
+
+	j.publish(rule.ID)
+	return nil
+}
+
+func (j *jwtRules) Verify(acc *auth.Account, res *auth.Resource, opts ...auth.VerifyOption) error {
+	j.RLock()
+	defer j.RUnlock()
+
+	var options auth.VerifyOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return auth.Verify(j.rules, acc, res)
+}
+
+// List the rules of a namespace ordered by id, see ListNamespace,
+// ListLimit and ListOffset.
+func (j *jwtRules) List(opts ...auth.ListOption) ([]*auth.Rule, error) {
+	var options auth.ListOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	namespace := j.options.Namespace
+	var limit, offset uint
+
+	if ctx := options.Context; ctx != nil {
+		if ns, ok := ctx.Value(listNamespaceKey{}).(string); ok {
+			namespace = ns
+		}
+		limit, _ = ctx.Value(listLimitKey{}).(uint)
+		offset, _ = ctx.Value(listOffsetKey{}).(uint)
+	}
+
+	rules, err := j.read(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset >= uint(len(rules)) {
+		return []*auth.Rule{}, nil
+	}
+	rules = rules[offset:]
+	if limit > 0 && limit < uint(len(rules)) {
+		rules = rules[:limit]
+	}
+
+	return rules, nil
+}
+
+// Close stops reloading the rules.
+func (j *jwtRules) Close() error {
+	j.once.Do(func() {
+		close(j.exit)
+		if j.sub != nil {
+			j.sub.Unsubscribe()
+		}
+	})
+	return nil
+}
+
+// read the rules of the namespace from the store.
+func (j *jwtRules) read(namespace string) ([]*auth.Rule, error) {
+	// the memory store reads no records by prefix without a limit
+	recs, err := j.options.Store.Read(ruleKey(namespace, ""), store.ReadPrefix(), store.ReadLimit(math.MaxInt32))
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+
+	rules := make([]*auth.Rule, 0, len(recs))
+
+	for _, rec := range recs {
+		rule := new(auth.Rule)
+		if err := json.Unmarshal(rec.Value, rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, k int) bool {
+		return rules[i].ID < rules[k].ID
+	})
+
+	return rules, nil
+}
+
+// load the rules of the namespace from the store.
+func (j *jwtRules) load() error {
+	rules, err := j.read(j.options.Namespace)
+	if err != nil {
+		return err
+	}
+
+	j.Lock()
+	j.rules = rules
+	j.Unlock()
+	return nil
+}
+
+// run reloads the rules at the refresh interval.
+func (j *jwtRules) run() {
+	if j.options.RefreshInterval <= 0 {
+		return
+	}
+
+	t := time.NewTicker(j.options.RefreshInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := j.load(); err != nil {
+				log.Errorf("Error loading auth rules: %v", err)
+			}
+		case <-j.exit:
+			return
+		}
+	}
+}
+
+// publish a change to the rules of the namespace.
+func (j *jwtRules) publish(id string) {
+	if j.options.Broker == nil {
+		return
+	}
+
+	err := j.options.Broker.Publish(j.options.Topic, &broker.Message{
+		Header: map[string]string{"namespace": j.options.Namespace},
+		Body:   []byte(id),
+	})
+	if err != nil {
+		log.Errorf("Error publishing auth rule change: %v", err)
+	}
+}
+
+// handle a change published by an instance.
+func (j *jwtRules) handle(e broker.Event) error {
+	if e.Message().Header["namespace"] != j.options.Namespace {
+		return nil
+	}
+	return j.load()
+}
+
+func ruleKey(namespace, id string) string {
+	return rulesPrefix + namespace + "/" + id
+}
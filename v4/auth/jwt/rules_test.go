@@ -0,0 +1,126 @@
+package jwt
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/auth"
+	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/store"
+)
+
+func testRule(id string) *auth.Rule {
+	return &auth.Rule{
+		ID:       id,
+		Scope:    "*",
+		Resource: &auth.Resource{Type: "service", Name: "go.micro.service.foo", Endpoint: id},
+		Access:   auth.AccessGranted,
+	}
+}
+
+func TestRulesPersisted(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	r := NewRules(WithStore(s))
+	defer r.(*jwtRules).Close()
+
+	for i := 0; i < 5; i++ {
+		if err := r.Grant(testRule(fmt.Sprintf("rule-%d", i))); err != nil {
+			t.Fatalf("Grant returned %v error, expected nil", err)
+		}
+	}
+	if err := r.Revoke(testRule("rule-2")); err != nil {
+		t.Fatalf("Revoke returned %v error, expected nil", err)
+	}
+
+	// a new instance loads the rules from the store
+	r2 := NewRules(WithStore(s))
+	defer r2.(*jwtRules).Close()
+
+	res := &auth.Resource{Type: "service", Name: "go.micro.service.foo", Endpoint: "rule-1"}
+	if err := r2.Verify(&auth.Account{ID: "test"}, res); err != nil {
+		t.Fatalf("Verify returned %v error, expected nil", err)
+	}
+	res.Endpoint = "rule-2"
+	if err := r2.Verify(&auth.Account{ID: "test"}, res); err != auth.ErrForbidden {
+		t.Fatalf("Verify returned %v error, expected %v", err, auth.ErrForbidden)
+	}
+
+	rules, err := r2.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 4 {
+		t.Fatalf("List returned %d rules, expected 4", len(rules))
+	}
+
+	rules, err = r2.List(ListLimit(2), ListOffset(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].ID != "rule-1" || rules[1].ID != "rule-3" {
+		t.Fatalf("List returned unexpected page %v", rules)
+	}
+
+	rules, err = r2.List(ListNamespace("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 0 {
+		t.Fatalf("List returned %d rules for other namespace, expected 0", len(rules))
+	}
+}
+
+func TestRulesPropagated(t *testing.T) {
+	s := store.NewMemoryStore()
+	b := broker.NewMemoryBroker()
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Disconnect()
+
+	// the refresh interval is too long to pick up the change
+	r1 := NewRules(WithStore(s), WithBroker(b), WithRefreshInterval(time.Hour))
+	defer r1.(*jwtRules).Close()
+	r2 := NewRules(WithStore(s), WithBroker(b), WithRefreshInterval(time.Hour))
+	defer r2.(*jwtRules).Close()
+
+	if err := r1.Grant(testRule("foo")); err != nil {
+		t.Fatal(err)
+	}
+
+	res := &auth.Resource{Type: "service", Name: "go.micro.service.foo", Endpoint: "foo"}
+	if err := r2.Verify(&auth.Account{ID: "test"}, res); err != nil {
+		t.Fatalf("Verify returned %v error, expected nil", err)
+	}
+
+	if err := r1.Revoke(testRule("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.Verify(&auth.Account{ID: "test"}, res); err != auth.ErrForbidden {
+		t.Fatalf("Verify returned %v error, expected %v", err, auth.ErrForbidden)
+	}
+}
+
+func TestRulesPolled(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	r1 := NewRules(WithStore(s))
+	defer r1.(*jwtRules).Close()
+	r2 := NewRules(WithStore(s), WithRefreshInterval(10*time.Millisecond))
+	defer r2.(*jwtRules).Close()
+
+	if err := r1.Grant(testRule("foo")); err != nil {
+		t.Fatal(err)
+	}
+
+	res := &auth.Resource{Type: "service", Name: "go.micro.service.foo", Endpoint: "foo"}
+	deadline := time.Now().Add(time.Second)
+	for r2.Verify(&auth.Account{ID: "test"}, res) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("rule was not picked up by polling the store")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,290 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultJWKSRefresh is how often a JWKS is fetched again.
+	DefaultJWKSRefresh = time.Hour
+
+	// minimum time between fetches triggered by unknown key ids
+	minJWKSRefresh = 10 * time.Second
+)
+
+// jwk is a JSON Web Key, only the public parameters are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// MarshalJWKS encodes the public keys as a JWKS document. Symmetric keys
+// are never published and skipped.
+func MarshalJWKS(keys []*Key) ([]byte, error) {
+	doc := jwks{Keys: []jwk{}}
+
+	for _, k := range keys {
+		j := jwk{Kid: k.ID, Alg: k.Algorithm, Use: "sig"}
+
+		switch pub := k.Public.(type) {
+		case *rsa.PublicKey:
+			j.Kty = "RSA"
+			j.N = encodeBase64(pub.N.Bytes())
+			j.E = encodeBase64(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			j.Kty = "EC"
+			j.Crv = pub.Curve.Params().Name
+			j.X = encodeBase64(pad(pub.X.Bytes(), size))
+			j.Y = encodeBase64(pad(pub.Y.Bytes(), size))
+		case ed25519.PublicKey:
+			j.Kty = "OKP"
+			j.Crv = "Ed25519"
+			j.X = encodeBase64(pub)
+		case []byte:
+			continue
+		default:
+			return nil, ErrUnsupportedKey
+		}
+
+		doc.Keys = append(doc.Keys, j)
+	}
+
+	return json.Marshal(doc)
+}
+
+// ParseJWKS decodes the keys of a JWKS document, skipping keys of
+// unsupported types.
+func ParseJWKS(b []byte) ([]*Key, error) {
+	var doc jwks
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*Key, 0, len(doc.Keys))
+
+	for _, j := range doc.Keys {
+		if len(j.Use) > 0 && j.Use != "sig" {
+			continue
+		}
+
+		var pub interface{}
+
+		switch j.Kty {
+		case "RSA":
+			n, err := decodeBase64(j.N)
+			if err != nil {
+				return nil, err
+			}
+			e, err := decodeBase64(j.E)
+			if err != nil {
+				return nil, err
+			}
+			pub = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			var curve elliptic.Curve
+			switch j.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err := decodeBase64(j.X)
+			if err != nil {
+				return nil, err
+			}
+			y, err := decodeBase64(j.Y)
+			if err != nil {
+				return nil, err
+			}
+			pub = &ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		case "OKP":
+			if j.Crv != "Ed25519" {
+				continue
+			}
+			x, err := decodeBase64(j.X)
+			if err != nil {
+				return nil, err
+			}
+			if len(x) != ed25519.PublicKeySize {
+				return nil, ErrUnsupportedKey
+			}
+			pub = ed25519.PublicKey(x)
+		default:
+			continue
+		}
+
+		alg := j.Alg
+		if len(alg) == 0 {
+			alg = algorithm(pub)
+		}
+
+		k, err := NewKey(j.Kid, alg, nil, pub)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// JWKSHandler serves the public keys of the key set as a JWKS document.
+func JWKSHandler(ks KeySet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys, err := ks.Keys("")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		b, err := MarshalJWKS(keys)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
+
+// JWKSKeySet verifies tokens with the keys of a remote JWKS document.
+// The document is cached and fetched again after the refresh interval,
+// or earlier when a token is signed with an unknown key id.
+type JWKSKeySet struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	sync.RWMutex
+	keys      []*Key
+	fetched   time.Time
+	attempted time.Time
+}
+
+// NewJWKSKeySet returns a key set for the JWKS at the url.
+func NewJWKSKeySet(url string, refresh time.Duration) *JWKSKeySet {
+	if refresh <= 0 {
+		refresh = DefaultJWKSRefresh
+	}
+
+	return &JWKSKeySet{
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SigningKey always fails, tokens can't be signed with remote keys.
+func (s *JWKSKeySet) SigningKey() (*Key, error) {
+	return nil, ErrNoSigningKey
+}
+
+// Keys returns the cached keys with the key id.
+func (s *JWKSKeySet) Keys(kid string) ([]*Key, error) {
+	s.RLock()
+	keys := filterKeys(s.keys, kid)
+	stale := time.Since(s.fetched) >= s.refresh
+	recent := time.Since(s.attempted) < minJWKSRefresh
+	s.RUnlock()
+
+	if recent || (!stale && len(keys) > 0) {
+		return keys, nil
+	}
+
+	if err := s.fetch(); err != nil {
+		// keep using the cached keys
+		if len(keys) > 0 {
+			return keys, nil
+		}
+		return nil, err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+	return filterKeys(s.keys, kid), nil
+}
+
+func (s *JWKSKeySet) fetch() error {
+	s.Lock()
+	s.attempted = time.Now()
+	s.Unlock()
+
+	rsp, err := s.client.Get(s.url)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks: unexpected status %s", rsp.Status)
+	}
+
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+
+	keys, err := ParseJWKS(b)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.keys = keys
+	s.fetched = time.Now()
+	s.Unlock()
+
+	return nil
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+func pad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	p := make([]byte, size)
+	copy(p[size-len(b):], b)
+	return p
+}
@@ -1,10 +1,10 @@
 package token
 
 import (
-	"encoding/base64"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 	"go-micro.dev/v4/auth"
 )
 
@@ -13,6 +13,9 @@ type authClaims struct {
 	Type     string            `json:"type"`
 	Scopes   []string          `json:"scopes"`
 	Metadata map[string]string `json:"metadata"`
+	// TokenType and Family are empty for tokens issued by earlier versions
+	TokenType string `json:"token_type,omitempty"`
+	Family    string `json:"family,omitempty"`
 
 	jwt.StandardClaims
 }
@@ -20,26 +23,45 @@ type authClaims struct {
 // JWT implementation of token provider.
 type JWT struct {
 	opts Options
+	// local keys, also published as JWKS
+	local KeySet
+	// local and remote keys
+	keys KeySet
+	// claims of single-use tokens in progress
+	claims *keyLocks
 }
 
 // New returns an initialized basic provider.
 func New(opts ...Option) Provider {
+	options := NewOptions(opts...)
+
+	local := options.KeySet
+	if local == nil {
+		local = pemKeySet(options.PrivateKey, options.PublicKey)
+	}
+
+	keys := local
+	if len(options.JWKSURL) > 0 {
+		keys = multiKeySet{local, NewJWKSKeySet(options.JWKSURL, options.JWKSRefresh)}
+	}
+
 	return &JWT{
-		opts: NewOptions(opts...),
+		opts:   options,
+		local:  local,
+		keys:   keys,
+		claims: new(keyLocks),
 	}
 }
 
 // Generate a new JWT.
 func (j *JWT) Generate(acc *auth.Account, opts ...GenerateOption) (*Token, error) {
-	// decode the private key
-	priv, err := base64.StdEncoding.DecodeString(j.opts.PrivateKey)
+	key, err := j.keys.SigningKey()
 	if err != nil {
-		return nil, err
+		return nil, ErrEncodingToken
 	}
 
-	// parse the private key
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(priv)
-	if err != nil {
+	method := jwt.GetSigningMethod(key.Algorithm)
+	if method == nil {
 		return nil, ErrEncodingToken
 	}
 
@@ -47,21 +69,28 @@ func (j *JWT) Generate(acc *auth.Account, opts ...GenerateOption) (*Token, error
 	options := NewGenerateOptions(opts...)
 
 	// generate the JWT
+	id := uuid.New().String()
 	expiry := time.Now().Add(options.Expiry)
-	t := jwt.NewWithClaims(jwt.SigningMethodRS256, authClaims{
-		acc.Type, acc.Scopes, acc.Metadata, jwt.StandardClaims{
+	t := jwt.NewWithClaims(method, authClaims{
+		acc.Type, acc.Scopes, acc.Metadata, options.Type, options.Family, jwt.StandardClaims{
+			Id:        id,
 			Subject:   acc.ID,
 			Issuer:    acc.Issuer,
 			ExpiresAt: expiry.Unix(),
 		},
 	})
-	tok, err := t.SignedString(key)
+	if len(key.ID) > 0 {
+		t.Header["kid"] = key.ID
+	}
+
+	tok, err := t.SignedString(key.Private)
 	if err != nil {
 		return nil, err
 	}
 
 	// return the token
 	return &Token{
+		ID:      id,
 		Token:   tok,
 		Expiry:  expiry,
 		Created: time.Now(),
@@ -70,37 +99,90 @@ func (j *JWT) Generate(acc *auth.Account, opts ...GenerateOption) (*Token, error
 
 // Inspect a JWT.
 func (j *JWT) Inspect(t string) (*auth.Account, error) {
-	// decode the public key
-	pub, err := base64.StdEncoding.DecodeString(j.opts.PublicKey)
+	claims, err := j.Claims(t)
 	if err != nil {
 		return nil, err
 	}
+	return claims.Account, nil
+}
 
-	// parse the public key
-	res, err := jwt.ParseWithClaims(t, &authClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwt.ParseRSAPublicKeyFromPEM(pub)
-	})
+// Claims returns the claims of a valid JWT which hasn't been revoked.
+func (j *JWT) Claims(t string) (*Claims, error) {
+	claims, err := j.parse(t)
 	if err != nil {
-		return nil, ErrInvalidToken
+		return nil, err
 	}
 
-	// validate the token
-	if !res.Valid {
+	if err := j.checkRevoked(claims.Id, claims.Family); err != nil {
+		return nil, err
+	}
+
+	return &Claims{
+		ID:     claims.Id,
+		Type:   claims.TokenType,
+		Family: claims.Family,
+		Expiry: time.Unix(claims.ExpiresAt, 0),
+		Account: &auth.Account{
+			ID:       claims.Subject,
+			Issuer:   claims.Issuer,
+			Type:     claims.Type,
+			Scopes:   claims.Scopes,
+			Metadata: claims.Metadata,
+		},
+	}, nil
+}
+
+// parse and verify a JWT.
+func (j *JWT) parse(t string) (*authClaims, error) {
+	// find the keys the token may be signed with
+	var parser jwt.Parser
+	unverified, _, err := parser.ParseUnverified(t, &authClaims{})
+	if err != nil {
 		return nil, ErrInvalidToken
 	}
-	claims, ok := res.Claims.(*authClaims)
-	if !ok {
+
+	kid, _ := unverified.Header["kid"].(string)
+	keys, err := j.keys.Keys(kid)
+	if err != nil {
 		return nil, ErrInvalidToken
 	}
 
-	// return the token
-	return &auth.Account{
-		ID:       claims.Subject,
-		Issuer:   claims.Issuer,
-		Type:     claims.Type,
-		Scopes:   claims.Scopes,
-		Metadata: claims.Metadata,
-	}, nil
+	for _, key := range keys {
+		// the algorithm is pinned by the key, never by the token
+		if key.Algorithm != unverified.Method.Alg() {
+			continue
+		}
+
+		res, err := jwt.ParseWithClaims(t, &authClaims{}, func(token *jwt.Token) (interface{}, error) {
+			return key.Public, nil
+		})
+		if err != nil || !res.Valid {
+			continue
+		}
+
+		claims, ok := res.Claims.(*authClaims)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		return claims, nil
+	}
+
+	return nil, ErrInvalidToken
+}
+
+// KeySet returns the local keys tokens are signed and verified with.
+func (j *JWT) KeySet() KeySet {
+	return j.local
+}
+
+// JWKS returns the local public keys as a JWKS document, it can be served
+// with JWKSHandler(j.KeySet()).
+func (j *JWT) JWKS() ([]byte, error) {
+	keys, err := j.local.Keys("")
+	if err != nil {
+		return nil, err
+	}
+	return MarshalJWKS(keys)
 }
 
 // String returns JWT.
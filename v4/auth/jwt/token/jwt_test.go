@@ -2,10 +2,14 @@ package token
 
 import (
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"go-micro.dev/v4/auth"
+	"go-micro.dev/v4/store"
+	gosync "go-micro.dev/v4/sync"
 )
 
 func TestGenerate(t *testing.T) {
@@ -37,6 +41,7 @@ func TestInspect(t *testing.T) {
 	j := New(
 		WithPublicKey(string(pubKey)),
 		WithPrivateKey(string(privKey)),
+		WithStore(store.NewMemoryStore()),
 	)
 
 	t.Run("Valid token", func(t *testing.T) {
@@ -76,6 +81,79 @@ func TestInspect(t *testing.T) {
 		}
 	})
 
+	t.Run("Revoked token", func(t *testing.T) {
+		tok, err := j.Generate(&auth.Account{ID: "test"}, WithType(TypeAccess), WithFamily("family"))
+		if err != nil {
+			t.Fatalf("Generate returned %v error, expected nil", err)
+		}
+
+		claims, err := j.(*JWT).Claims(tok.Token)
+		if err != nil {
+			t.Fatalf("Claims returned %v error, expected nil", err)
+		}
+		if claims.ID != tok.ID || claims.Type != TypeAccess || claims.Family != "family" {
+			t.Fatalf("Claims returned %+v, expected token %v of family family", claims, tok.ID)
+		}
+
+		if err := j.(*JWT).Revoke("family", tok.Expiry); err != nil {
+			t.Fatalf("Revoke returned %v error, expected nil", err)
+		}
+		if _, err = j.Inspect(tok.Token); err != ErrRevokedToken {
+			t.Fatalf("Inspect returned %v error, expected %v", err, ErrRevokedToken)
+		}
+	})
+
+	t.Run("Used token", func(t *testing.T) {
+		tok, err := j.Generate(&auth.Account{ID: "test"}, WithType(TypeRefresh))
+		if err != nil {
+			t.Fatalf("Generate returned %v error, expected nil", err)
+		}
+
+		if err := j.(*JWT).Use(tok.ID, tok.Expiry); err != nil {
+			t.Fatalf("Use returned %v error, expected nil", err)
+		}
+		if err := j.(*JWT).Use(tok.ID, tok.Expiry); err != ErrReusedToken {
+			t.Fatalf("Use returned %v error, expected %v", err, ErrReusedToken)
+		}
+	})
+
+	t.Run("Concurrently used token", func(t *testing.T) {
+		tok, err := j.Generate(&auth.Account{ID: "test"}, WithType(TypeRefresh))
+		if err != nil {
+			t.Fatalf("Generate returned %v error, expected nil", err)
+		}
+
+		// instances sharing the store and the sync
+		s := store.NewMemoryStore()
+		lock := new(testSync)
+		providers := []Provider{
+			New(WithPrivateKey(string(privKey)), WithStore(s), WithSync(lock)),
+			New(WithPrivateKey(string(privKey)), WithStore(s), WithSync(lock)),
+		}
+
+		var (
+			wg   sync.WaitGroup
+			used int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(p Provider) {
+				defer wg.Done()
+				err := p.(*JWT).Use(tok.ID, tok.Expiry)
+				if err == nil {
+					atomic.AddInt32(&used, 1)
+				} else if err != ErrReusedToken {
+					t.Errorf("Use returned %v error, expected %v", err, ErrReusedToken)
+				}
+			}(providers[i%len(providers)])
+		}
+		wg.Wait()
+
+		if used != 1 {
+			t.Fatalf("Use succeeded %d times, expected once", used)
+		}
+	})
+
 	t.Run("Invalid token", func(t *testing.T) {
 		_, err := j.Inspect("Invalid token")
 		if err != ErrInvalidToken {
@@ -83,3 +161,23 @@ func TestInspect(t *testing.T) {
 		}
 	})
 }
+
+// testSync locks in-process, as a sync shared by instances would.
+type testSync struct {
+	locks keyLocks
+}
+
+func (s *testSync) Init(...gosync.Option) error { return nil }
+func (s *testSync) Options() gosync.Options     { return gosync.Options{} }
+func (s *testSync) Leader(string, ...gosync.LeaderOption) (gosync.Leader, error) {
+	return nil, nil
+}
+func (s *testSync) Lock(id string, _ ...gosync.LockOption) error {
+	s.locks.lock(id)
+	return nil
+}
+func (s *testSync) Unlock(id string) error {
+	s.locks.unlock(id)
+	return nil
+}
+func (s *testSync) String() string { return "test" }
@@ -0,0 +1,305 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	// ErrNoSigningKey is returned when a key set has no key to sign tokens with.
+	ErrNoSigningKey = errors.New("no signing key")
+	// ErrUnsupportedKey is returned for keys of an unsupported type.
+	ErrUnsupportedKey = errors.New("unsupported key type")
+)
+
+// Key signs or verifies tokens.
+type Key struct {
+	// ID is set as the kid header of the tokens signed with the key
+	ID string
+	// Algorithm is the signing algorithm: RS256, ES256, EdDSA or HS256
+	Algorithm string
+	// Private signs tokens: *rsa.PrivateKey, *ecdsa.PrivateKey,
+	// ed25519.PrivateKey or the []byte secret for HS256
+	Private interface{}
+	// Public verifies tokens: *rsa.PublicKey, *ecdsa.PublicKey,
+	// ed25519.PublicKey or the []byte secret for HS256
+	Public interface{}
+}
+
+// KeySet provides the keys tokens are signed and verified with.
+type KeySet interface {
+	// SigningKey returns the key new tokens are signed with
+	SigningKey() (*Key, error)
+	// Keys returns the verification keys with the key id, or all of them
+	// for an empty id
+	Keys(kid string) ([]*Key, error)
+}
+
+// NewKey returns a key for the algorithm, deriving the public key from
+// the private key when it's not set.
+func NewKey(id, alg string, private, public interface{}) (*Key, error) {
+	if public == nil {
+		switch k := private.(type) {
+		case *rsa.PrivateKey:
+			public = &k.PublicKey
+		case *ecdsa.PrivateKey:
+			public = &k.PublicKey
+		case ed25519.PrivateKey:
+			public = k.Public()
+		case []byte:
+			public = k
+		}
+	}
+
+	if jwt.GetSigningMethod(alg) == nil || !compatible(alg, public) {
+		return nil, ErrUnsupportedKey
+	}
+
+	return &Key{
+		ID:        id,
+		Algorithm: alg,
+		Private:   private,
+		Public:    public,
+	}, nil
+}
+
+// StaticKeySet is a KeySet held in memory. Rotating the signing key keeps
+// the previous one for verification so tokens it signed remain valid.
+type StaticKeySet struct {
+	sync.RWMutex
+	signing *Key
+	keys    []*Key
+}
+
+// NewStaticKeySet returns a key set signing with the signing key and
+// verifying with it and the additional keys.
+func NewStaticKeySet(signing *Key, keys ...*Key) *StaticKeySet {
+	s := &StaticKeySet{signing: signing}
+	if signing != nil {
+		s.keys = append(s.keys, signing)
+	}
+	s.keys = append(s.keys, keys...)
+	return s
+}
+
+// SigningKey returns the current signing key.
+func (s *StaticKeySet) SigningKey() (*Key, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if s.signing == nil || s.signing.Private == nil {
+		return nil, ErrNoSigningKey
+	}
+	return s.signing, nil
+}
+
+// Keys returns the verification keys with the key id.
+func (s *StaticKeySet) Keys(kid string) ([]*Key, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	return filterKeys(s.keys, kid), nil
+}
+
+// Rotate signs new tokens with the key, the previous signing key is
+// still used for verification until it's removed.
+func (s *StaticKeySet) Rotate(key *Key) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.signing = key
+	s.keys = append([]*Key{key}, removeKey(s.keys, key.ID)...)
+}
+
+// Add a verification key, replacing any key with the same id.
+func (s *StaticKeySet) Add(key *Key) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.keys = append(removeKey(s.keys, key.ID), key)
+}
+
+// Remove the verification key with the id.
+func (s *StaticKeySet) Remove(kid string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.keys = removeKey(s.keys, kid)
+	if s.signing != nil && s.signing.ID == kid {
+		s.signing = nil
+	}
+}
+
+func filterKeys(keys []*Key, kid string) []*Key {
+	if len(kid) == 0 {
+		return append([]*Key(nil), keys...)
+	}
+
+	var res []*Key
+	for _, k := range keys {
+		if k.ID == kid {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+func removeKey(keys []*Key, kid string) []*Key {
+	res := make([]*Key, 0, len(keys))
+	for _, k := range keys {
+		if k.ID != kid {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// multiKeySet signs with the first key set and verifies with all of them.
+type multiKeySet []KeySet
+
+func (m multiKeySet) SigningKey() (*Key, error) {
+	for _, ks := range m {
+		if k, err := ks.SigningKey(); err == nil {
+			return k, nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+func (m multiKeySet) Keys(kid string) ([]*Key, error) {
+	var (
+		keys []*Key
+		err  error
+	)
+	for _, ks := range m {
+		k, kerr := ks.Keys(kid)
+		if kerr != nil {
+			err = kerr
+			continue
+		}
+		keys = append(keys, k...)
+	}
+	if len(keys) == 0 && err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// pemKeySet parses the base64 encoded PEM key pair of the Options.
+func pemKeySet(privateKey, publicKey string) KeySet {
+	ks := NewStaticKeySet(nil)
+
+	var priv interface{}
+	if b, err := base64.StdEncoding.DecodeString(privateKey); err == nil && len(b) > 0 {
+		priv, _ = parsePrivateKeyPEM(b)
+	}
+
+	var pub interface{}
+	if b, err := base64.StdEncoding.DecodeString(publicKey); err == nil && len(b) > 0 {
+		pub, _ = parsePublicKeyPEM(b)
+	}
+
+	if priv == nil && pub == nil {
+		return ks
+	}
+
+	alg := algorithm(priv)
+	if priv == nil {
+		alg = algorithm(pub)
+	}
+
+	if k, err := NewKey("", alg, priv, pub); err == nil {
+		if priv != nil {
+			ks.Rotate(k)
+		} else {
+			ks.Add(k)
+		}
+	}
+
+	return ks
+}
+
+// algorithm returns the default signing algorithm of the key.
+func algorithm(key interface{}) string {
+	switch key.(type) {
+	case *ecdsa.PrivateKey, *ecdsa.PublicKey:
+		return "ES256"
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return SigningMethodEdDSA.Alg()
+	case []byte:
+		return "HS256"
+	default:
+		return "RS256"
+	}
+}
+
+// compatible reports whether the key can be used with the algorithm.
+func compatible(alg string, key interface{}) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return strings.HasPrefix(alg, "ES")
+	case ed25519.PublicKey:
+		return alg == SigningMethodEdDSA.Alg()
+	case []byte:
+		return strings.HasPrefix(alg, "HS")
+	default:
+		return false
+	}
+}
+
+// parsePrivateKeyPEM parses RSA, EC or Ed25519 private keys.
+func parsePrivateKeyPEM(b []byte) (interface{}, error) {
+	if k, err := jwt.ParseRSAPrivateKeyFromPEM(b); err == nil {
+		return k, nil
+	}
+	if k, err := jwt.ParseECPrivateKeyFromPEM(b); err == nil {
+		return k, nil
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, ErrUnsupportedKey
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := k.(ed25519.PrivateKey); ok {
+		return k, nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// parsePublicKeyPEM parses RSA, EC or Ed25519 public keys.
+func parsePublicKeyPEM(b []byte) (interface{}, error) {
+	if k, err := jwt.ParseRSAPublicKeyFromPEM(b); err == nil {
+		return k, nil
+	}
+	if k, err := jwt.ParseECPublicKeyFromPEM(b); err == nil {
+		return k, nil
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, ErrUnsupportedKey
+	}
+	k, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := k.(ed25519.PublicKey); ok {
+		return k, nil
+	}
+	return nil, ErrUnsupportedKey
+}
@@ -0,0 +1,214 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"go-micro.dev/v4/auth"
+)
+
+func testKeys(t *testing.T) map[string]*Key {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make(map[string]*Key)
+	for _, k := range []struct {
+		alg string
+		key interface{}
+	}{
+		{"RS256", rsaKey},
+		{"ES256", ecKey},
+		{"EdDSA", edKey},
+		{"HS256", []byte("secret")},
+	} {
+		key, err := NewKey(k.alg+"-key", k.alg, k.key, nil)
+		if err != nil {
+			t.Fatalf("NewKey(%s) returned %v error, expected nil", k.alg, err)
+		}
+		keys[k.alg] = key
+	}
+	return keys
+}
+
+func TestAlgorithms(t *testing.T) {
+	for alg, key := range testKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			j := New(WithKeySet(NewStaticKeySet(key)))
+
+			tok, err := j.Generate(&auth.Account{ID: "test"})
+			if err != nil {
+				t.Fatalf("Generate returned %v error, expected nil", err)
+			}
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(tok.Token, &authClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["kid"] != key.ID || parsed.Method.Alg() != alg {
+				t.Fatalf("unexpected header %v", parsed.Header)
+			}
+
+			acc, err := j.Inspect(tok.Token)
+			if err != nil {
+				t.Fatalf("Inspect returned %v error, expected nil", err)
+			}
+			if acc.ID != "test" {
+				t.Fatalf("Inspect returned %v as the account id, expected test", acc.ID)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	keys := testKeys(t)
+
+	ks := NewStaticKeySet(keys["RS256"])
+	j := New(WithKeySet(ks))
+
+	old, err := j.Generate(&auth.Account{ID: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks.Rotate(keys["ES256"])
+
+	tok, err := j.Generate(&auth.Account{ID: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tk := range []string{old.Token, tok.Token} {
+		if _, err := j.Inspect(tk); err != nil {
+			t.Fatalf("Inspect returned %v error, expected nil", err)
+		}
+	}
+
+	ks.Remove(keys["RS256"].ID)
+	if _, err := j.Inspect(old.Token); err != ErrInvalidToken {
+		t.Fatalf("Inspect returned %v error, expected %v", err, ErrInvalidToken)
+	}
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	keys := testKeys(t)
+
+	// sign a HS256 token with the RSA key id, using the public key as secret
+	pub, err := MarshalJWKS([]*Key{keys["RS256"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, authClaims{StandardClaims: jwt.StandardClaims{
+		Subject:   "attacker",
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}})
+	tok.Header["kid"] = keys["RS256"].ID
+	forged, err := tok.SignedString(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := New(WithKeySet(NewStaticKeySet(keys["RS256"])))
+	if _, err := j.Inspect(forged); err != ErrInvalidToken {
+		t.Fatalf("Inspect returned %v error, expected %v", err, ErrInvalidToken)
+	}
+
+	if _, err := NewKey("bad", "HS256", nil, keys["RS256"].Public); err != ErrUnsupportedKey {
+		t.Fatalf("NewKey returned %v error, expected %v", err, ErrUnsupportedKey)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	keys := testKeys(t)
+
+	issuer := NewStaticKeySet(keys["EdDSA"], keys["RS256"], keys["ES256"], keys["HS256"])
+	srv := httptest.NewServer(JWKSHandler(issuer))
+	defer srv.Close()
+
+	// the symmetric key must never be published
+	b, err := New(WithKeySet(issuer)).(*JWT).JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := ParseJWKS(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 3 {
+		t.Fatalf("expected 3 published keys got %d", len(published))
+	}
+
+	signer := New(WithKeySet(issuer))
+	verifier := New(WithJWKS(srv.URL, time.Hour))
+
+	for _, alg := range []string{"EdDSA", "RS256", "ES256"} {
+		issuer.Rotate(keys[alg])
+
+		tok, err := signer.Generate(&auth.Account{ID: "test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifier.Inspect(tok.Token); err != nil {
+			t.Fatalf("Inspect of %s token returned %v error, expected nil", alg, err)
+		}
+	}
+
+	// a key added after the last fetch is found by refetching on unknown kid
+	minJWKSRefresh = 0
+	defer func() { minJWKSRefresh = 10 * time.Second }()
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	newKey, _ := NewKey("new-key", "EdDSA", edKey, nil)
+	issuer.Rotate(newKey)
+
+	tok, err := signer.Generate(&auth.Account{ID: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Inspect(tok.Token); err != nil {
+		t.Fatalf("Inspect returned %v error, expected nil", err)
+	}
+
+	if _, err := verifier.Generate(&auth.Account{ID: "test"}); err != ErrEncodingToken {
+		t.Fatalf("Generate returned %v error, expected %v", err, ErrEncodingToken)
+	}
+}
+
+func TestPEMKeys(t *testing.T) {
+	privKey, err := os.ReadFile("test/sample_key")
+	if err != nil {
+		t.Fatalf("Unable to read private key: %v", err)
+	}
+
+	// the public key is derived from the private key
+	j := New(WithPrivateKey(string(privKey)))
+	tok, err := j.Generate(&auth.Account{ID: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Inspect(tok.Token); err != nil {
+		t.Fatalf("Inspect returned %v error, expected nil", err)
+	}
+
+	if _, err := New(WithPrivateKey(base64.StdEncoding.EncodeToString([]byte("invalid")))).Generate(&auth.Account{}); err != ErrEncodingToken {
+		t.Fatalf("Generate returned %v error, expected %v", err, ErrEncodingToken)
+	}
+}
@@ -4,15 +4,26 @@ import (
 	"time"
 
 	"go-micro.dev/v4/store"
+	"go-micro.dev/v4/sync"
 )
 
 type Options struct {
 	// Store to persist the tokens
 	Store store.Store
+	// Sync locks the claims of single-use tokens across the instances
+	// sharing the store
+	Sync sync.Sync
 	// PublicKey base64 encoded, used by JWT
 	PublicKey string
 	// PrivateKey base64 encoded, used by JWT
 	PrivateKey string
+	// KeySet signs and verifies tokens, it takes precedence over the
+	// PublicKey and PrivateKey
+	KeySet KeySet
+	// JWKSURL is a JWKS document tokens are also verified with
+	JWKSURL string
+	// JWKSRefresh is how often the JWKS document is fetched
+	JWKSRefresh time.Duration
 }
 
 type Option func(o *Options)
@@ -24,6 +35,15 @@ func WithStore(s store.Store) Option {
 	}
 }
 
+// WithSync sets the sync locking the claims of single-use tokens, so that
+// the instances sharing the store can't use the same token concurrently.
+// The claims are only locked in-process without.
+func WithSync(s sync.Sync) Option {
+	return func(o *Options) {
+		o.Sync = s
+	}
+}
+
 // WithPublicKey sets the JWT public key.
 func WithPublicKey(key string) Option {
 	return func(o *Options) {
@@ -38,6 +58,22 @@ func WithPrivateKey(key string) Option {
 	}
 }
 
+// WithKeySet sets the keys to sign and verify tokens with.
+func WithKeySet(ks KeySet) Option {
+	return func(o *Options) {
+		o.KeySet = ks
+	}
+}
+
+// WithJWKS verifies tokens with the keys of the JWKS document at the url,
+// which is fetched again after the refresh interval.
+func WithJWKS(url string, refresh time.Duration) Option {
+	return func(o *Options) {
+		o.JWKSURL = url
+		o.JWKSRefresh = refresh
+	}
+}
+
 func NewOptions(opts ...Option) Options {
 	var options Options
 	for _, o := range opts {
@@ -53,6 +89,10 @@ func NewOptions(opts ...Option) Options {
 type GenerateOptions struct {
 	// Expiry for the token
 	Expiry time.Duration
+	// Type of the token, e.g. access
+	Type string
+	// Family the token belongs to
+	Family string
 }
 
 type GenerateOption func(o *GenerateOptions)
@@ -64,6 +104,21 @@ func WithExpiry(d time.Duration) GenerateOption {
 	}
 }
 
+// WithType sets the type of the generated token.
+func WithType(t string) GenerateOption {
+	return func(o *GenerateOptions) {
+		o.Type = t
+	}
+}
+
+// WithFamily sets the family of the generated token, revoking the family
+// revokes all of its tokens.
+func WithFamily(f string) GenerateOption {
+	return func(o *GenerateOptions) {
+		o.Family = f
+	}
+}
+
 // NewGenerateOptions from a slice of options.
 func NewGenerateOptions(opts ...GenerateOption) GenerateOptions {
 	var options GenerateOptions
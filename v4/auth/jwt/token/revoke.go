@@ -0,0 +1,169 @@
+package token
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go-micro.dev/v4/store"
+	gosync "go-micro.dev/v4/sync"
+)
+
+const (
+	// store key prefixes of revoked and used token ids
+	revokedPrefix = "revoked/"
+	usedPrefix    = "used/"
+)
+
+// Revoke the token or token family id until the expiry, after which the
+// tokens are no longer valid anyway.
+func (j *JWT) Revoke(id string, expiry time.Time) error {
+	if len(id) == 0 {
+		return ErrInvalidToken
+	}
+	return j.mark(revokedPrefix+id, expiry)
+}
+
+// Use marks a single-use token id as used until the expiry. The claim is
+// locked across the instances sharing the sync of the options, in-process
+// without, and read back to detect the instances claiming it at the same
+// time.
+func (j *JWT) Use(id string, expiry time.Time) error {
+	if len(id) == 0 {
+		return ErrInvalidToken
+	}
+	if !expiry.After(time.Now()) {
+		// already expired
+		return nil
+	}
+
+	key := usedPrefix + id
+
+	unlock, err := j.lock(key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	used, err := j.exists(key)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrReusedToken
+	}
+
+	claim := uuid.New().String()
+	if err := j.write(key, claim, expiry); err != nil {
+		return err
+	}
+
+	recs, err := j.opts.Store.Read(key)
+	if err != nil {
+		return err
+	}
+	if len(recs) == 0 || string(recs[0].Value) != claim {
+		return ErrReusedToken
+	}
+
+	return nil
+}
+
+// checkRevoked returns ErrRevokedToken if the token or its family has
+// been revoked.
+func (j *JWT) checkRevoked(ids ...string) error {
+	for _, id := range ids {
+		if len(id) == 0 {
+			continue
+		}
+
+		revoked, err := j.exists(revokedPrefix + id)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrRevokedToken
+		}
+	}
+	return nil
+}
+
+func (j *JWT) mark(key string, expiry time.Time) error {
+	return j.write(key, expiry.Format(time.RFC3339), expiry)
+}
+
+func (j *JWT) write(key, value string, expiry time.Time) error {
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		// already expired
+		return nil
+	}
+
+	return j.opts.Store.Write(&store.Record{
+		Key:    key,
+		Value:  []byte(value),
+		Expiry: ttl,
+	})
+}
+
+func (j *JWT) exists(key string) (bool, error) {
+	recs, err := j.opts.Store.Read(key)
+	if err == store.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return len(recs) > 0, nil
+}
+
+// lock the key with the sync of the options, or in-process.
+func (j *JWT) lock(key string) (func(), error) {
+	if s := j.opts.Sync; s != nil {
+		if err := s.Lock(key, gosync.LockTTL(time.Minute)); err != nil {
+			return nil, err
+		}
+		return func() { s.Unlock(key) }, nil
+	}
+
+	j.claims.lock(key)
+	return func() { j.claims.unlock(key) }, nil
+}
+
+// keyLocks are mutexes by key, held while in use.
+type keyLocks struct {
+	sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (k *keyLocks) lock(key string) {
+	k.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyLock)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = new(keyLock)
+		k.locks[key] = l
+	}
+	l.refs++
+	k.Unlock()
+
+	l.Lock()
+}
+
+func (k *keyLocks) unlock(key string) {
+	k.Lock()
+	l := k.locks[key]
+	l.refs--
+	if l.refs == 0 {
+		delete(k.locks, key)
+	}
+	k.Unlock()
+
+	l.Unlock()
+}
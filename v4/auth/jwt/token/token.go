@@ -14,6 +14,19 @@ var (
 	ErrEncodingToken = errors.New("error encoding the token")
 	// ErrInvalidToken is returned when the token provided is not valid.
 	ErrInvalidToken = errors.New("invalid token provided")
+	// ErrRevokedToken is returned when the token or its family has been revoked.
+	ErrRevokedToken = errors.New("token has been revoked")
+	// ErrReusedToken is returned when a single-use token is used again.
+	ErrReusedToken = errors.New("token has already been used")
+)
+
+const (
+	// TypeSecret tokens are exchanged for access and refresh tokens.
+	TypeSecret = "secret"
+	// TypeAccess tokens authenticate requests.
+	TypeAccess = "access"
+	// TypeRefresh tokens are exchanged once for new access and refresh tokens.
+	TypeRefresh = "refresh"
 )
 
 // Provider generates and inspects tokens.
@@ -23,7 +36,34 @@ type Provider interface {
 	String() string
 }
 
+// Revoker is implemented by providers supporting token revocation.
+type Revoker interface {
+	// Claims returns the claims of a valid token which hasn't been revoked
+	Claims(token string) (*Claims, error)
+	// Revoke a token or token family id until the expiry
+	Revoke(id string, expiry time.Time) error
+	// Use marks a single-use token id as used until the expiry, it returns
+	// ErrReusedToken if it already was
+	Use(id string, expiry time.Time) error
+}
+
+// Claims of a token.
+type Claims struct {
+	// ID of the token, the jti claim
+	ID string
+	// Type of the token, e.g. access
+	Type string
+	// Family the token was issued in, refresh token rotation keeps the family
+	Family string
+	// Expiry of the token
+	Expiry time.Time
+	// Account the token was issued to
+	Account *auth.Account
+}
+
 type Token struct {
+	// ID of the token
+	ID string `json:"id"`
 	// The actual token
 	Token string `json:"token"`
 	// Time of token creation
@@ -5,7 +5,9 @@ import (
 	"context"
 	"errors"
 	"math/rand"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -25,7 +27,8 @@ type memoryBroker struct {
 
 	addr string
 	sync.RWMutex
-	connected   bool
+	connected bool
+	// Subscribers by topic, the topic may contain wildcards
 	Subscribers map[string][]*memorySubscriber
 }
 
@@ -34,14 +37,23 @@ type memoryEvent struct {
 	topic   string
 	err     error
 	message interface{}
+
+	once    sync.Once
+	decoded *broker.Message
+	// set by Ack, maybe from another goroutine
+	acked int32
 }
 
 type memorySubscriber struct {
 	id      string
 	topic   string
 	exit    chan bool
+	once    sync.Once
 	handler broker.Handler
 	opts    broker.SubscribeOptions
+
+	// messages of async subscribers
+	queue chan *memoryEvent
 }
 
 func (m *memoryBroker) Options() broker.Options {
@@ -102,9 +114,31 @@ func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker
 		return errors.New("not connected")
 	}
 
-	subs, ok := m.Subscribers[topic]
+	// subscribers of the same queue receive a message once
+	var groups [][]*memorySubscriber
+	queues := make(map[string]int)
+
+	for pattern, subs := range m.Subscribers {
+		if !match(pattern, topic) {
+			continue
+		}
+		for _, sub := range subs {
+			if len(sub.opts.Queue) == 0 {
+				groups = append(groups, []*memorySubscriber{sub})
+				continue
+			}
+			k := pattern + "\x00" + sub.opts.Queue
+			if i, ok := queues[k]; ok {
+				groups[i] = append(groups[i], sub)
+				continue
+			}
+			queues[k] = len(groups)
+			groups = append(groups, []*memorySubscriber{sub})
+		}
+	}
 	m.RUnlock()
-	if !ok {
+
+	if len(groups) == 0 {
 		return nil
 	}
 
@@ -119,15 +153,24 @@ func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker
 		v = msg
 	}
 
-	p := &memoryEvent{
-		topic:   topic,
-		message: v,
-		opts:    m.opts,
-	}
+	for _, group := range groups {
+		sub := group[rand.Intn(len(group))]
+
+		p := &memoryEvent{
+			topic:   topic,
+			message: v,
+			opts:    m.opts,
+		}
+
+		if sub.queue != nil {
+			select {
+			case sub.queue <- p:
+			case <-sub.exit:
+			}
+			continue
+		}
 
-	for _, sub := range subs {
-		if err := sub.handler(p); err != nil {
-			p.err = err
+		if err := m.deliver(sub, p); err != nil {
 			if eh := m.opts.ErrorHandler; eh != nil {
 				eh(p)
 				continue
@@ -139,6 +182,66 @@ func (m *memoryBroker) Publish(topic string, msg *broker.Message, opts ...broker
 	return nil
 }
 
+// deliver the event to the subscriber, redelivering it while the handler
+// fails or doesn't ack it. The error of the last attempt is returned, the
+// messages which weren't acked aren't an error of the publisher.
+func (m *memoryBroker) deliver(sub *memorySubscriber, p *memoryEvent) error {
+	var rd redelivery
+	if ctx := m.opts.Context; ctx != nil {
+		rd, _ = ctx.Value(redeliveryKey{}).(redelivery)
+	}
+
+	for attempt := 0; ; attempt++ {
+		e := &memoryEvent{
+			topic:   p.topic,
+			message: p.message,
+			opts:    p.opts,
+		}
+
+		err := sub.handler(e)
+		if err == nil {
+			if sub.opts.AutoAck {
+				e.Ack()
+			}
+			if atomic.LoadInt32(&e.acked) == 1 {
+				return nil
+			}
+		}
+
+		p.err = err
+
+		if attempt >= rd.max {
+			return err
+		}
+
+		if rd.delay > 0 {
+			select {
+			case <-time.After(rd.delay):
+			case <-sub.exit:
+				return err
+			}
+		}
+	}
+}
+
+// run delivers the messages queued for an async subscriber.
+func (m *memoryBroker) run(sub *memorySubscriber) {
+	for {
+		select {
+		case p := <-sub.queue:
+			if err := m.deliver(sub, p); err != nil {
+				if eh := m.opts.ErrorHandler; eh != nil {
+					eh(p)
+					continue
+				}
+				m.opts.Logger.Logf(logger.ErrorLevel, "[memory]: failed to deliver message on %s: %v", p.topic, err)
+			}
+		case <-sub.exit:
+			return
+		}
+	}
+}
+
 func (m *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...broker.SubscribeOption) (broker.Subscriber, error) {
 	m.RLock()
 	if !m.connected {
@@ -147,19 +250,29 @@ func (m *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...b
 	}
 	m.RUnlock()
 
-	var options broker.SubscribeOptions
-	for _, o := range opts {
-		o(&options)
-	}
+	options := broker.NewSubscribeOptions(opts...)
 
 	sub := &memorySubscriber{
-		exit:    make(chan bool, 1),
+		exit:    make(chan bool),
 		id:      uuid.New().String(),
 		topic:   topic,
 		handler: handler,
 		opts:    options,
 	}
 
+	var buffer int
+	var ok bool
+	if options.Context != nil {
+		buffer, ok = options.Context.Value(asyncKey{}).(int)
+	}
+	if !ok && m.opts.Context != nil {
+		buffer, ok = m.opts.Context.Value(asyncKey{}).(int)
+	}
+	if ok {
+		sub.queue = make(chan *memoryEvent, buffer)
+		go m.run(sub)
+	}
+
 	m.Lock()
 	m.Subscribers[topic] = append(m.Subscribers[topic], sub)
 	m.Unlock()
@@ -174,7 +287,11 @@ func (m *memoryBroker) Subscribe(topic string, handler broker.Handler, opts ...b
 			}
 			newSubscribers = append(newSubscribers, sb)
 		}
-		m.Subscribers[topic] = newSubscribers
+		if len(newSubscribers) == 0 {
+			delete(m.Subscribers, topic)
+		} else {
+			m.Subscribers[topic] = newSubscribers
+		}
 		m.Unlock()
 	}()
 
@@ -189,23 +306,35 @@ func (m *memoryEvent) Topic() string {
 	return m.topic
 }
 
+// Message returns a copy of the published message, decoded with the codec
+// when the broker has one.
 func (m *memoryEvent) Message() *broker.Message {
-	switch v := m.message.(type) {
-	case *broker.Message:
-		return v
-	case []byte:
-		msg := &broker.Message{}
-		if err := m.opts.Codec.Unmarshal(v, msg); err != nil {
-			m.opts.Logger.Logf(logger.ErrorLevel, "[memory]: failed to unmarshal: %v\n", err)
-			return nil
+	m.once.Do(func() {
+		switch v := m.message.(type) {
+		case *broker.Message:
+			header := make(map[string]string, len(v.Header))
+			for k, val := range v.Header {
+				header[k] = val
+			}
+			m.decoded = &broker.Message{
+				Header: header,
+				Body:   append([]byte(nil), v.Body...),
+			}
+		case []byte:
+			msg := &broker.Message{}
+			if err := m.opts.Codec.Unmarshal(v, msg); err != nil {
+				m.opts.Logger.Logf(logger.ErrorLevel, "[memory]: failed to unmarshal: %v\n", err)
+				return
+			}
+			m.decoded = msg
 		}
-		return msg
-	}
+	})
 
-	return nil
+	return m.decoded
 }
 
 func (m *memoryEvent) Ack() error {
+	atomic.StoreInt32(&m.acked, 1)
 	return nil
 }
 
@@ -222,10 +351,37 @@ func (m *memorySubscriber) Topic() string {
 }
 
 func (m *memorySubscriber) Unsubscribe() error {
-	m.exit <- true
+	m.once.Do(func() {
+		close(m.exit)
+	})
 	return nil
 }
 
+// match reports whether the topic matches the pattern. Topics are split in
+// tokens by dots, * matches a token and > matches the remaining tokens.
+func match(pattern, topic string) bool {
+	if pattern == topic {
+		return true
+	}
+
+	pt := strings.Split(pattern, ".")
+	tt := strings.Split(topic, ".")
+
+	for i, p := range pt {
+		if p == ">" && i == len(pt)-1 {
+			return len(tt) > i
+		}
+		if i >= len(tt) {
+			return false
+		}
+		if p != "*" && p != tt[i] {
+			return false
+		}
+	}
+
+	return len(pt) == len(tt)
+}
+
 func NewBroker(opts ...broker.Option) broker.Broker {
 	options := broker.Options{
 		Context: context.Background(),
@@ -1,10 +1,14 @@
 package memory
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"go-micro.dev/v4/broker"
+	"go-micro.dev/v4/codec/json"
 )
 
 func TestMemoryBroker(t *testing.T) {
@@ -48,3 +52,235 @@ func TestMemoryBroker(t *testing.T) {
 		t.Fatalf("Unexpected connect error %v", err)
 	}
 }
+
+func newBroker(t *testing.T, opts ...broker.Option) broker.Broker {
+	b := NewBroker(opts...)
+	if err := b.Connect(); err != nil {
+		t.Fatalf("Unexpected connect error %v", err)
+	}
+	t.Cleanup(func() {
+		b.Disconnect()
+	})
+	return b
+}
+
+func publish(t *testing.T, b broker.Broker, topic string, count int) {
+	for i := 0; i < count; i++ {
+		msg := &broker.Message{
+			Header: map[string]string{"id": fmt.Sprintf("%d", i)},
+			Body:   []byte(`hello world`),
+		}
+		if err := b.Publish(topic, msg); err != nil {
+			t.Fatalf("Unexpected error publishing %d: %v", i, err)
+		}
+	}
+}
+
+func TestMemoryBrokerQueue(t *testing.T) {
+	b := newBroker(t)
+
+	var mu sync.Mutex
+	counts := make(map[string]int)
+
+	handler := func(name string) broker.Handler {
+		return func(p broker.Event) error {
+			mu.Lock()
+			counts[name]++
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := b.Subscribe("test", handler(name), broker.Queue("workers")); err != nil {
+			t.Fatalf("Unexpected error subscribing %v", err)
+		}
+	}
+	if _, err := b.Subscribe("test", handler("all")); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	publish(t, b, "test", 90)
+
+	if counts["all"] != 90 {
+		t.Fatalf("Expected every message without a queue, got %d", counts["all"])
+	}
+	if total := counts["a"] + counts["b"] + counts["c"]; total != 90 {
+		t.Fatalf("Expected each message once in the queue, got %d", total)
+	}
+	if counts["a"] == 0 || counts["b"] == 0 || counts["c"] == 0 {
+		t.Fatalf("Expected messages balanced across the queue, got %v", counts)
+	}
+}
+
+func TestMemoryBrokerWildcard(t *testing.T) {
+	b := newBroker(t)
+
+	var mu sync.Mutex
+	received := make(map[string][]string)
+
+	for _, pattern := range []string{"orders.*", "orders.>", "orders.*.created", "orders"} {
+		pattern := pattern
+		fn := func(p broker.Event) error {
+			mu.Lock()
+			received[pattern] = append(received[pattern], p.Topic())
+			mu.Unlock()
+			return nil
+		}
+		if _, err := b.Subscribe(pattern, fn); err != nil {
+			t.Fatalf("Unexpected error subscribing %v", err)
+		}
+	}
+
+	publish(t, b, "orders.eu", 1)
+	publish(t, b, "orders.eu.created", 1)
+	publish(t, b, "orders", 1)
+
+	expected := map[string]int{
+		"orders.*":         1,
+		"orders.>":         2,
+		"orders.*.created": 1,
+		"orders":           1,
+	}
+	for pattern, n := range expected {
+		if len(received[pattern]) != n {
+			t.Fatalf("Expected %d messages on %s, got %v", n, pattern, received[pattern])
+		}
+	}
+}
+
+func TestMemoryBrokerAsync(t *testing.T) {
+	b := newBroker(t)
+
+	release := make(chan bool)
+	done := make(chan string, 10)
+
+	fn := func(p broker.Event) error {
+		<-release
+		done <- p.Message().Header["id"]
+		return nil
+	}
+
+	if _, err := b.Subscribe("test", fn, SubscribeAsync(10)); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	// publishing doesn't wait for the handler
+	publish(t, b, "test", 5)
+	close(release)
+
+	for i := 0; i < 5; i++ {
+		select {
+		case id := <-done:
+			if id != fmt.Sprintf("%d", i) {
+				t.Fatalf("Expected message %d in order, got %s", i, id)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for messages")
+		}
+	}
+}
+
+func TestMemoryBrokerRedelivery(t *testing.T) {
+	b := newBroker(t, Redelivery(2, 0))
+
+	var attempts int
+	fn := func(p broker.Event) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("failed")
+		}
+		return nil
+	}
+
+	if _, err := b.Subscribe("errors", fn); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	publish(t, b, "errors", 1)
+
+	if attempts != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", attempts)
+	}
+
+	// messages which aren't acked are redelivered
+	var unacked int
+	ack := func(p broker.Event) error {
+		unacked++
+		if unacked == 2 {
+			return p.Ack()
+		}
+		return nil
+	}
+
+	if _, err := b.Subscribe("acks", ack, broker.DisableAutoAck()); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+
+	publish(t, b, "acks", 1)
+
+	if unacked != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", unacked)
+	}
+
+	// failing every attempt is returned
+	errFailed := errors.New("failed")
+	if _, err := b.Subscribe("fail", func(broker.Event) error { return errFailed }, broker.DisableAutoAck()); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+	if err := b.Publish("fail", &broker.Message{}); err != errFailed {
+		t.Fatalf("Expected %v, got %v", errFailed, err)
+	}
+
+	// not acking isn't an error of the publisher, the handlers may ack
+	// from another goroutine
+	var wg sync.WaitGroup
+	later := func(p broker.Event) error {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Ack()
+		}()
+		return nil
+	}
+	if _, err := b.Subscribe("later", later, broker.DisableAutoAck()); err != nil {
+		t.Fatalf("Unexpected error subscribing %v", err)
+	}
+	if err := b.Publish("later", &broker.Message{}); err != nil {
+		t.Fatalf("Unexpected error publishing %v", err)
+	}
+	wg.Wait()
+}
+
+func TestMemoryBrokerCodec(t *testing.T) {
+	b := newBroker(t, broker.Codec(json.Marshaler{}))
+
+	var got []*broker.Message
+	var ids []string
+	fn := func(p broker.Event) error {
+		msg := p.Message()
+		got = append(got, msg)
+		ids = append(ids, msg.Header["id"])
+		// changes aren't seen by other subscribers
+		msg.Header["id"] = "changed"
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := b.Subscribe("test", fn); err != nil {
+			t.Fatalf("Unexpected error subscribing %v", err)
+		}
+	}
+
+	publish(t, b, "test", 1)
+
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(got))
+	}
+	if ids[0] != "0" || ids[1] != "0" {
+		t.Fatalf("Expected the published header, got %v", ids)
+	}
+	if string(got[1].Body) != "hello world" || got[0] == got[1] {
+		t.Fatalf("Expected a decoded copy of the message, got %+v", got[1])
+	}
+}
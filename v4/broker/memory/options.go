@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"time"
+
+	"go-micro.dev/v4/broker"
+)
+
+type asyncKey struct{}
+type redeliveryKey struct{}
+
+type redelivery struct {
+	max   int
+	delay time.Duration
+}
+
+// Async delivers messages to every subscriber in its own goroutine, with
+// up to buffer messages queued. Publishing blocks while the buffer of a
+// subscriber is full.
+func Async(buffer int) broker.Option {
+	return setBrokerOption(asyncKey{}, buffer)
+}
+
+// SubscribeAsync delivers messages to the subscriber in its own goroutine,
+// with up to buffer messages queued.
+func SubscribeAsync(buffer int) broker.SubscribeOption {
+	return setSubscribeOption(asyncKey{}, buffer)
+}
+
+// Redelivery redelivers messages the handler failed or didn't ack up to max
+// times, waiting delay before each redelivery.
+func Redelivery(max int, delay time.Duration) broker.Option {
+	return setBrokerOption(redeliveryKey{}, redelivery{max, delay})
+}
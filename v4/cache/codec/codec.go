@@ -0,0 +1,129 @@
+// Package codec serializes the values of the redis, memcached and file caches.
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/gob"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-micro.dev/v4/cache"
+)
+
+// Codec serializes the values put in the cache.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte) (interface{}, error)
+}
+
+// RawCodec is the default codec. Values are written as redis formats them,
+// strings and byte slices as is, numbers and bools in decimal, times in
+// RFC 3339 and encoding.BinaryMarshaler values as they marshal, and they're
+// returned as raw bytes.
+type RawCodec struct{}
+
+// Marshal the value as redis formats it.
+func (RawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return []byte{}, nil
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), nil
+	case uint:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(nil, v, 10), nil
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'f', -1, 64), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return []byte("1"), nil
+		}
+		return []byte("0"), nil
+	case time.Time:
+		return v.AppendFormat(nil, time.RFC3339Nano), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	default:
+		return nil, fmt.Errorf("can't marshal %T (implement encoding.BinaryMarshaler)", v)
+	}
+}
+
+// Unmarshal returns the raw bytes.
+func (RawCodec) Unmarshal(b []byte) (interface{}, error) {
+	return b, nil
+}
+
+// GobCodec keeps the type of the values, a value is returned as the type it
+// was put as. Types other than the basic ones and their slices, such as maps
+// and structs, need to be registered with gob.Register.
+type GobCodec struct{}
+
+// gobValue carries the type of the value.
+type gobValue struct {
+	V interface{}
+}
+
+// Marshal the value with its type.
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gobValue{V: v}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal the value as its type.
+func (GobCodec) Unmarshal(b []byte) (interface{}, error) {
+	var v gobValue
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v.V, nil
+}
+
+type codecKey struct{}
+
+// WithCodec sets the codec of the values, RawCodec by default.
+func WithCodec(c Codec) cache.Option {
+	return func(o *cache.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+
+		o.Context = context.WithValue(o.Context, codecKey{}, c)
+	}
+}
+
+// FromOptions returns the codec of the cache options, RawCodec if not set.
+func FromOptions(o cache.Options) Codec {
+	if o.Context != nil {
+		if c, ok := o.Context.Value(codecKey{}).(Codec); ok {
+			return c
+		}
+	}
+	return RawCodec{}
+}
@@ -0,0 +1,76 @@
+package codec
+
+import (
+	"encoding/gob"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/cache"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestRawCodec(t *testing.T) {
+	ts := time.Date(2022, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	for v, expected := range map[interface{}]string{
+		"hello":     "hello",
+		42:          "42",
+		int64(-1):   "-1",
+		uint8(255):  "255",
+		1.5:         "1.5",
+		float32(.5): "0.5",
+		true:        "1",
+		false:       "0",
+		ts:          "2022-01-02T03:04:05.000000006Z",
+	} {
+		b, err := RawCodec{}.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := RawCodec{}.Unmarshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, []byte(expected)) {
+			t.Fatalf("expected %q for %#v, got %q", expected, v, got)
+		}
+	}
+
+	if _, err := (RawCodec{}).Marshal(point{}); err == nil {
+		t.Fatal("expected an error marshaling a struct")
+	}
+}
+
+func TestGobCodec(t *testing.T) {
+	gob.Register(point{})
+	gob.Register(map[string]int{})
+
+	for _, v := range []interface{}{"hello", 42, 1.5, true, []string{"a", "b"}, map[string]int{"a": 1}, point{X: 1, Y: 2}} {
+		b, err := GobCodec{}.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := GobCodec{}.Unmarshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Fatalf("expected %#v, got %#v", v, got)
+		}
+	}
+}
+
+func TestFromOptions(t *testing.T) {
+	if _, ok := FromOptions(cache.NewOptions()).(RawCodec); !ok {
+		t.Fatal("expected the raw codec by default")
+	}
+	if _, ok := FromOptions(cache.NewOptions(WithCodec(GobCodec{}))).(GobCodec); !ok {
+		t.Fatal("expected the gob codec")
+	}
+}
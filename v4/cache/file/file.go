@@ -0,0 +1,386 @@
+// Package file is a bbolt backed cache persisted to disk.
+package file
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/cache"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/util/cmd"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/go-micro/plugins/v4/cache/codec"
+)
+
+var (
+	// DefaultDir is the default directory of the cache file.
+	DefaultDir = filepath.Join(os.TempDir(), "micro", "cache")
+	// DefaultSweepInterval is how often the expired items are deleted.
+	DefaultSweepInterval = time.Minute
+
+	// bucket used for the cached items.
+	cacheBucket = []byte("cache")
+	// bucket indexing the keys of the items by expiry.
+	expiryBucket = []byte("expiry")
+)
+
+const (
+	// items deleted per transaction by a sweep
+	sweepBatchSize = 1000
+)
+
+func init() {
+	cmd.DefaultCaches["file"] = NewCache
+}
+
+// NewCache returns a new file cache, items are kept in a bbolt database in the
+// directory of DirOption. The database is opened on first use and held until
+// the cache is closed, the expired items are deleted in the background while
+// it's open.
+func NewCache(opts ...cache.Option) cache.Cache {
+	options := cache.NewOptions(opts...)
+
+	c := &fileCache{
+		opts:          options,
+		dir:           DefaultDir,
+		codec:         codec.FromOptions(options),
+		sweepInterval: DefaultSweepInterval,
+	}
+	if options.Context != nil {
+		if dir, ok := options.Context.Value(dirOptionKey{}).(string); ok {
+			c.dir = dir
+		}
+		if interval, ok := options.Context.Value(sweepIntervalKey{}).(time.Duration); ok {
+			c.sweepInterval = interval
+		}
+	}
+
+	return c
+}
+
+type fileCache struct {
+	opts          cache.Options
+	dir           string
+	codec         codec.Codec
+	sweepInterval time.Duration
+
+	sync.Mutex
+	db *bolt.DB
+	// stops the sweeper
+	done    chan struct{}
+	sweeper sync.WaitGroup
+}
+
+// item stored by us.
+type item struct {
+	Value     []byte
+	ExpiresAt time.Time
+}
+
+func (c *fileCache) getDB() (*bolt.DB, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.db != nil {
+		return c.db, nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	// Bolt DB only allows one process to open the file R/W
+	db, err := bolt.Open(filepath.Join(c.dir, "cache.db"), 0700, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(indexExpiry); err != nil {
+		db.Close()
+		return nil, err
+	}
+	c.db = db
+
+	// sweep the database until the cache is closed
+	if c.sweepInterval > 0 {
+		c.done = make(chan struct{})
+		c.sweeper.Add(1)
+		go c.sweep(db, c.done, c.sweepInterval)
+	}
+
+	return db, nil
+}
+
+func (c *fileCache) sweep(db *bolt.DB, done chan struct{}, interval time.Duration) {
+	defer c.sweeper.Done()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+
+		if _, err := sweep(db); err != nil {
+			logger.Errorf("Error sweeping expired cache items: %v", err)
+		}
+	}
+}
+
+// sweep deletes the expired items in batches, so puts aren't held up by a
+// long transaction.
+func sweep(db *bolt.DB) (int, error) {
+	var total int
+
+	for {
+		var expired [][]byte
+		err := db.Update(func(tx *bolt.Tx) error {
+			idx := tx.Bucket(expiryBucket)
+			if idx == nil {
+				return nil
+			}
+
+			now := uint64(time.Now().UnixNano())
+			c := idx.Cursor()
+			for k, _ := c.First(); k != nil && len(expired) < sweepBatchSize; k, _ = c.Next() {
+				if binary.BigEndian.Uint64(k) > now {
+					break
+				}
+				// the key is only valid during the transaction
+				expired = append(expired, append([]byte(nil), k...))
+			}
+
+			b := tx.Bucket(cacheBucket)
+			for _, k := range expired {
+				if b != nil {
+					if err := b.Delete(k[8:]); err != nil {
+						return err
+					}
+				}
+				if err := idx.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += len(expired)
+		if len(expired) < sweepBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// expiryKey is the key of an item in the expiry index, the expiry time
+// first. The index is the same as the one of the file store, they're kept
+// in sync.
+func expiryKey(expiresAt time.Time, key []byte) []byte {
+	k := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(expiresAt.UnixNano()))
+	copy(k[8:], key)
+	return k
+}
+
+// indexExpiry builds the expiry index of databases written before it existed.
+func indexExpiry(tx *bolt.Tx) error {
+	if tx.Bucket(expiryBucket) != nil {
+		return nil
+	}
+	idx, err := tx.CreateBucket(expiryBucket)
+	if err != nil {
+		return err
+	}
+
+	b := tx.Bucket(cacheBucket)
+	if b == nil {
+		return nil
+	}
+	return b.ForEach(func(k, v []byte) error {
+		// an item that can't be decoded doesn't stop the database from
+		// opening, it's left out of the index
+		var it item
+		if err := json.Unmarshal(v, &it); err != nil || it.ExpiresAt.IsZero() {
+			return nil
+		}
+		return idx.Put(expiryKey(it.ExpiresAt, k), nil)
+	})
+}
+
+// unindexExpiry removes the current item of the key from the expiry index.
+func unindexExpiry(tx *bolt.Tx, key []byte) error {
+	b := tx.Bucket(cacheBucket)
+	idx := tx.Bucket(expiryBucket)
+	if b == nil || idx == nil {
+		return nil
+	}
+
+	var it item
+	if err := json.Unmarshal(b.Get(key), &it); err != nil || it.ExpiresAt.IsZero() {
+		return nil
+	}
+	return idx.Delete(expiryKey(it.ExpiresAt, key))
+}
+
+// Get the value and its expiry. An expired item is removed.
+func (c *fileCache) Get(ctx context.Context, key string) (interface{}, time.Time, error) {
+	db, err := c.getDB()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var data []byte
+	err = db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(cacheBucket); b != nil {
+			// the value is only valid during the transaction
+			data = append(data, b.Get([]byte(key))...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if data == nil {
+		return nil, time.Time{}, cache.ErrKeyNotFound
+	}
+
+	var it item
+	if err := json.Unmarshal(data, &it); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if it.ExpiresAt.IsZero() {
+		it.ExpiresAt = time.Unix(1<<63-1, 0)
+	} else if time.Now().After(it.ExpiresAt) {
+		c.deleteExpired(db, key)
+		return nil, time.Time{}, cache.ErrItemExpired
+	}
+
+	val, err := c.codec.Unmarshal(it.Value)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return val, it.ExpiresAt, nil
+}
+
+// deleteExpired deletes the item unless it was put again since it was read.
+func (c *fileCache) deleteExpired(db *bolt.DB, key string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(cacheBucket)
+		if b == nil {
+			return nil
+		}
+
+		var it item
+		if err := json.Unmarshal(b.Get([]byte(key)), &it); err != nil {
+			return nil
+		}
+		if it.ExpiresAt.IsZero() || time.Now().Before(it.ExpiresAt) {
+			return nil
+		}
+		if err := unindexExpiry(tx, []byte(key)); err != nil {
+			return err
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// Put the value, a zero duration never expires.
+func (c *fileCache) Put(ctx context.Context, key string, val interface{}, d time.Duration) error {
+	db, err := c.getDB()
+	if err != nil {
+		return err
+	}
+
+	b, err := c.codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	it := item{Value: b}
+	if d > 0 {
+		it.ExpiresAt = time.Now().Add(d)
+	}
+
+	data, err := json.Marshal(it)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(cacheBucket)
+		if err != nil {
+			return err
+		}
+		idx, err := tx.CreateBucketIfNotExists(expiryBucket)
+		if err != nil {
+			return err
+		}
+
+		if err := unindexExpiry(tx, []byte(key)); err != nil {
+			return err
+		}
+		if !it.ExpiresAt.IsZero() {
+			if err := idx.Put(expiryKey(it.ExpiresAt, []byte(key)), nil); err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte(key), data)
+	})
+}
+
+// Delete the item, deleting a missing key isn't an error.
+func (c *fileCache) Delete(ctx context.Context, key string) error {
+	db, err := c.getDB()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(cacheBucket)
+		if b == nil {
+			return nil
+		}
+		if err := unindexExpiry(tx, []byte(key)); err != nil {
+			return err
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// Close stops the sweeper and closes the database, it's reopened if the
+// cache is used again.
+func (c *fileCache) Close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.db == nil {
+		return nil
+	}
+
+	// wait for a sweep in progress before closing the database
+	if c.done != nil {
+		close(c.done)
+		c.done = nil
+		c.sweeper.Wait()
+	}
+
+	err := c.db.Close()
+	c.db = nil
+	return err
+}
+
+func (c *fileCache) String() string {
+	return "file"
+}
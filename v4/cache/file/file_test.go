@@ -0,0 +1,182 @@
+package file
+
+import (
+	"context"
+	"encoding/gob"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/cache"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/go-micro/plugins/v4/cache/codec"
+)
+
+var (
+	ctx = context.TODO()
+	key = "filetestkey"
+)
+
+type point struct {
+	X, Y int
+}
+
+func newTestCache(t *testing.T, dir string, opts ...cache.Option) *fileCache {
+	c := NewCache(append([]cache.Option{DirOption(dir)}, opts...)...).(*fileCache)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCache(t *testing.T) {
+	c := newTestCache(t, t.TempDir())
+
+	t.Run("CacheGetMiss", func(t *testing.T) {
+		if _, _, err := c.Get(ctx, key); err != cache.ErrKeyNotFound {
+			t.Fatalf("expected key not found, got %v", err)
+		}
+	})
+
+	t.Run("CacheGetHit", func(t *testing.T) {
+		if err := c.Put(ctx, key, "hello", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		v, expiry, err := c.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, []byte("hello")) {
+			t.Fatalf("expected 'hello', got '%s'", v)
+		}
+		if d := time.Until(expiry); d <= 0 || d > time.Minute {
+			t.Fatalf("expected to expire in a minute, got %v", d)
+		}
+	})
+
+	t.Run("CacheGetNoExpiry", func(t *testing.T) {
+		if err := c.Put(ctx, key, "hello", 0); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, expiry, err := c.Get(ctx, key); err != nil {
+			t.Fatal(err)
+		} else if expiry != time.Unix(1<<63-1, 0) {
+			t.Fatalf("expected no expiry, got %v", expiry)
+		}
+	})
+
+	t.Run("CacheGetExpired", func(t *testing.T) {
+		if err := c.Put(ctx, key, "hello", 20*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(25 * time.Millisecond)
+		if _, _, err := c.Get(ctx, key); err != cache.ErrItemExpired {
+			t.Fatalf("expected item expired, got %v", err)
+		}
+		// the expired item is removed
+		if _, _, err := c.Get(ctx, key); err != cache.ErrKeyNotFound {
+			t.Fatalf("expected key not found, got %v", err)
+		}
+	})
+
+	t.Run("CacheDelete", func(t *testing.T) {
+		if err := c.Put(ctx, key, "hello", 0); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Delete(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := c.Get(ctx, key); err != cache.ErrKeyNotFound {
+			t.Fatalf("expected key not found, got %v", err)
+		}
+		// deleting a missing key isn't an error
+		if err := c.Delete(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("CacheTypedValues", func(t *testing.T) {
+		c := newTestCache(t, t.TempDir(), codec.WithCodec(codec.GobCodec{}))
+
+		gob.Register(point{})
+		gob.Register(map[string]int{})
+
+		for _, v := range []interface{}{"hello", []byte("bytes"), 42, 1.5, true, []string{"a", "b"}, map[string]int{"a": 1}, point{X: 1, Y: 2}} {
+			if err := c.Put(ctx, key, v, 0); err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := c.Get(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, v) {
+				t.Fatalf("expected %#v, got %#v", v, got)
+			}
+		}
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	dir := t.TempDir()
+
+	c := newTestCache(t, dir)
+	if err := c.Put(ctx, key, "persisted", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = newTestCache(t, dir)
+	if v, _, err := c.Get(ctx, key); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []byte("persisted")) {
+		t.Fatalf("expected 'persisted', got '%s'", v)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	c := newTestCache(t, t.TempDir(), SweepInterval(10*time.Millisecond))
+
+	if err := c.Put(ctx, key, "expiring", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(ctx, "kept", "kept", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// the expired item is deleted without being read
+	db, err := c.getDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := func(k string) bool {
+		var ok bool
+		db.View(func(tx *bolt.Tx) error {
+			ok = tx.Bucket(cacheBucket).Get([]byte(k)) != nil
+			return nil
+		})
+		return ok
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for stored(key) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the expired item to be swept")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if !stored("kept") {
+		t.Fatal("expected the item without expiry to be kept")
+	}
+
+	// closing stops the sweeper
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
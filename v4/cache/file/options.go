@@ -0,0 +1,34 @@
+package file
+
+import (
+	"context"
+	"time"
+
+	"go-micro.dev/v4/cache"
+)
+
+type dirOptionKey struct{}
+
+// DirOption sets the directory of the cache file.
+func DirOption(dir string) cache.Option {
+	return func(o *cache.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, dirOptionKey{}, dir)
+	}
+}
+
+type sweepIntervalKey struct{}
+
+// SweepInterval sets how often the expired items are deleted from the cache
+// file, DefaultSweepInterval by default. Zero disables the sweeper, expired
+// items are then only deleted when they're read.
+func SweepInterval(d time.Duration) cache.Option {
+	return func(o *cache.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, sweepIntervalKey{}, d)
+	}
+}
@@ -0,0 +1,134 @@
+// Package memcached is a memcached backed cache.
+package memcached
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	mc "github.com/bradfitz/gomemcache/memcache"
+	"go-micro.dev/v4/cache"
+	"go-micro.dev/v4/util/cmd"
+
+	"github.com/go-micro/plugins/v4/cache/codec"
+)
+
+var (
+	// DefaultAddress of the memcached servers.
+	DefaultAddress = "127.0.0.1:11211"
+
+	// errInvalidItem is returned for items not written by the cache.
+	errInvalidItem = errors.New("invalid cache item")
+)
+
+const (
+	// expirations longer than this are unix times for memcached
+	maxRelativeExpiration = 30 * 24 * time.Hour
+)
+
+func init() {
+	cmd.DefaultCaches["memcached"] = NewCache
+}
+
+// NewCache returns a new memcached cache. The address is a comma separated
+// list of servers.
+func NewCache(opts ...cache.Option) cache.Cache {
+	options := cache.NewOptions(opts...)
+
+	addr := DefaultAddress
+	if len(options.Address) > 0 {
+		addr = options.Address
+	}
+
+	return &memcachedCache{
+		opts:   options,
+		client: mc.New(strings.Split(addr, ",")...),
+		codec:  codec.FromOptions(options),
+	}
+}
+
+type memcachedCache struct {
+	opts   cache.Options
+	client *mc.Client
+	codec  codec.Codec
+}
+
+// Get the value and its expiry, memcached doesn't return the expiry so it's
+// stored with the value. An item expiring within the second memcached
+// expires items in is returned as expired.
+func (c *memcachedCache) Get(ctx context.Context, key string) (interface{}, time.Time, error) {
+	item, err := c.client.Get(key)
+	if err == mc.ErrCacheMiss {
+		return nil, time.Time{}, cache.ErrKeyNotFound
+	} else if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if len(item.Value) < 8 {
+		return nil, time.Time{}, errInvalidItem
+	}
+
+	expiry := time.Unix(1<<63-1, 0)
+	if e := int64(binary.BigEndian.Uint64(item.Value)); e > 0 {
+		expiry = time.Unix(0, e)
+		if time.Now().After(expiry) {
+			return nil, time.Time{}, cache.ErrItemExpired
+		}
+	}
+
+	val, err := c.codec.Unmarshal(item.Value[8:])
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return val, expiry, nil
+}
+
+// Put the value, a zero duration never expires.
+func (c *memcachedCache) Put(ctx context.Context, key string, val interface{}, d time.Duration) error {
+	b, err := c.codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	var expiry int64
+	var expiration int32
+	if d > 0 {
+		expiry = time.Now().Add(d).UnixNano()
+		expiration = toExpiration(d)
+	}
+
+	value := make([]byte, 8+len(b))
+	binary.BigEndian.PutUint64(value, uint64(expiry))
+	copy(value[8:], b)
+
+	return c.client.Set(&mc.Item{
+		Key:        key,
+		Value:      value,
+		Expiration: expiration,
+	})
+}
+
+func (c *memcachedCache) Delete(ctx context.Context, key string) error {
+	err := c.client.Delete(key)
+	if err == mc.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
+
+func (c *memcachedCache) String() string {
+	return "memcached"
+}
+
+// toExpiration returns the memcached expiration of the duration, seconds
+// rounded up or a unix time for long durations.
+func toExpiration(d time.Duration) int32 {
+	if d > maxRelativeExpiration {
+		return int32(time.Now().Add(d).Unix())
+	}
+	return int32(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,203 @@
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/cache"
+
+	"github.com/go-micro/plugins/v4/cache/codec"
+)
+
+var (
+	ctx = context.TODO()
+	key = "memcachedtestkey"
+)
+
+// server speaks enough of the memcached text protocol for the client,
+// expirations are left to the cache.
+type server struct {
+	mu    sync.Mutex
+	items map[string][]byte
+}
+
+func newServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &server{items: map[string][]byte{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func (s *server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) < 2 {
+			return
+		}
+
+		s.mu.Lock()
+		switch args[0] {
+		case "gets":
+			for _, k := range args[1:] {
+				if v, ok := s.items[k]; ok {
+					fmt.Fprintf(rw, "VALUE %s 0 %d 1\r\n%s\r\n", k, len(v), v)
+				}
+			}
+			fmt.Fprint(rw, "END\r\n")
+		case "set":
+			n, _ := strconv.Atoi(args[4])
+			v := make([]byte, n+2)
+			if _, err := io.ReadFull(rw, v); err != nil {
+				s.mu.Unlock()
+				return
+			}
+			s.items[args[1]] = v[:n]
+			fmt.Fprint(rw, "STORED\r\n")
+		case "delete":
+			if _, ok := s.items[args[1]]; ok {
+				delete(s.items, args[1])
+				fmt.Fprint(rw, "DELETED\r\n")
+			} else {
+				fmt.Fprint(rw, "NOT_FOUND\r\n")
+			}
+		}
+		s.mu.Unlock()
+
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache(cache.WithAddress(newServer(t)))
+
+	t.Run("CacheGetMiss", func(t *testing.T) {
+		if _, _, err := c.Get(ctx, key); err != cache.ErrKeyNotFound {
+			t.Fatalf("expected key not found, got %v", err)
+		}
+	})
+
+	t.Run("CacheGetHit", func(t *testing.T) {
+		if err := c.Put(ctx, key, "hello", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+
+		v, expiry, err := c.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, []byte("hello")) {
+			t.Fatalf("expected 'hello', got '%s'", v)
+		}
+		if d := time.Until(expiry); d <= 0 || d > time.Minute {
+			t.Fatalf("expected to expire in a minute, got %v", d)
+		}
+	})
+
+	t.Run("CacheGetNoExpiry", func(t *testing.T) {
+		if err := c.Put(ctx, key, "hello", 0); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, expiry, err := c.Get(ctx, key); err != nil {
+			t.Fatal(err)
+		} else if expiry != time.Unix(1<<63-1, 0) {
+			t.Fatalf("expected no expiry, got %v", expiry)
+		}
+	})
+
+	t.Run("CacheGetExpired", func(t *testing.T) {
+		if err := c.Put(ctx, key, "hello", 20*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+
+		<-time.After(25 * time.Millisecond)
+		if _, _, err := c.Get(ctx, key); err != cache.ErrItemExpired {
+			t.Fatalf("expected item expired, got %v", err)
+		}
+	})
+
+	t.Run("CacheDelete", func(t *testing.T) {
+		if err := c.Put(ctx, key, "hello", 0); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Delete(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := c.Get(ctx, key); err != cache.ErrKeyNotFound {
+			t.Fatalf("expected key not found, got %v", err)
+		}
+		// deleting a missing key succeeds
+		if err := c.Delete(ctx, key); err != nil {
+			t.Fatalf("expected no error deleting a missing key, got %v", err)
+		}
+	})
+
+	t.Run("CacheTypedValues", func(t *testing.T) {
+		c := NewCache(cache.WithAddress(newServer(t)), codec.WithCodec(codec.GobCodec{}))
+
+		gob.Register(point{})
+		gob.Register(map[string]int{})
+
+		for _, v := range []interface{}{"hello", []byte("bytes"), 42, 1.5, true, []string{"a", "b"}, map[string]int{"a": 1}, point{X: 1, Y: 2}} {
+			if err := c.Put(ctx, key, v, 0); err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := c.Get(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, v) {
+				t.Fatalf("expected %#v, got %#v", v, got)
+			}
+		}
+	})
+}
+
+func TestExpiration(t *testing.T) {
+	if e := toExpiration(1500 * time.Millisecond); e != 2 {
+		t.Fatalf("expected 2 seconds, got %d", e)
+	}
+
+	e := toExpiration(60 * 24 * time.Hour)
+	if d := time.Until(time.Unix(int64(e), 0)); d < 59*24*time.Hour {
+		t.Fatalf("expected a unix time, got %d", e)
+	}
+}
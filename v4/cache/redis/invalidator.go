@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"go-micro.dev/v4/cache"
+)
+
+// DefaultInvalidationChannel is the channel invalidations are published to.
+const DefaultInvalidationChannel = "micro:cache:invalidate"
+
+type invalidationChannelKey struct{}
+
+// InvalidationChannel sets the channel invalidations are published to.
+func InvalidationChannel(channel string) cache.Option {
+	return func(o *cache.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+
+		o.Context = context.WithValue(o.Context, invalidationChannelKey{}, channel)
+	}
+}
+
+// Invalidator publishes the invalidations of in-process caches over redis
+// pub/sub, so an instance drops the keys written or deleted by the others.
+// Messages published while a subscriber is reconnecting are lost.
+type Invalidator struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+// NewInvalidator returns an invalidator publishing to the channel of the
+// options, DefaultInvalidationChannel by default.
+func NewInvalidator(opts ...cache.Option) *Invalidator {
+	options := cache.NewOptions(opts...)
+
+	channel := DefaultInvalidationChannel
+	if options.Context != nil {
+		if c, ok := options.Context.Value(invalidationChannelKey{}).(string); ok && len(c) > 0 {
+			channel = c
+		}
+	}
+
+	return &Invalidator{
+		client:  newUniversalClient(options),
+		channel: channel,
+	}
+}
+
+// Publish an invalidation.
+func (i *Invalidator) Publish(ctx context.Context, msg string) error {
+	return i.client.Publish(ctx, i.channel, msg).Err()
+}
+
+// Subscribe calls the function with the invalidations published until the
+// returned function is called.
+func (i *Invalidator) Subscribe(fn func(msg string)) (func() error, error) {
+	ctx := context.Background()
+
+	sub := i.client.Subscribe(ctx, i.channel)
+
+	// wait for the subscription, invalidations are missed otherwise
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	go func() {
+		for m := range sub.Channel() {
+			fn(m.Payload)
+		}
+	}()
+
+	return sub.Close, nil
+}
+
+// Close the client.
+func (i *Invalidator) Close() error {
+	return i.client.Close()
+}
@@ -0,0 +1,37 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"go-micro.dev/v4/cache"
+)
+
+func TestInvalidator(t *testing.T) {
+	mr := miniredis.RunT(t)
+	i := NewInvalidator(cache.WithAddress(mr.Addr()), InvalidationChannel("test"))
+	defer i.Close()
+
+	msgs := make(chan string, 1)
+	stop, err := i.Subscribe(func(msg string) {
+		msgs <- msg
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	if err := i.Publish(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-msgs:
+		if msg != key {
+			t.Fatalf("expected %s, got %s", key, msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no invalidation received")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/go-redis/redis/v8"
 	"go-micro.dev/v4/cache"
 	"go-micro.dev/v4/util/cmd"
+
+	"github.com/go-micro/plugins/v4/cache/codec"
 )
 
 func init() {
@@ -16,26 +18,48 @@ func init() {
 // NewCache returns a new redis cache.
 func NewCache(opts ...cache.Option) cache.Cache {
 	options := cache.NewOptions(opts...)
+
 	return &redisCache{
 		opts:   options,
 		client: newUniversalClient(options),
+		codec:  codec.FromOptions(options),
 	}
 }
 
 type redisCache struct {
 	opts   cache.Options
 	client redis.UniversalClient
+	codec  codec.Codec
 }
 
 func (c *redisCache) Get(ctx context.Context, key string) (interface{}, time.Time, error) {
-	val, err := c.client.Get(ctx, key).Bytes()
+	// the value and its ttl in one round trip
+	var (
+		get *redis.StringCmd
+		ttl *redis.DurationCmd
+	)
+	_, err := c.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		get = p.Get(ctx, key)
+		ttl = p.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, time.Time{}, err
+	}
+
+	b, err := get.Bytes()
 	if err != nil && err == redis.Nil {
 		return nil, time.Time{}, cache.ErrKeyNotFound
 	} else if err != nil {
 		return nil, time.Time{}, err
 	}
 
-	dur, err := c.client.TTL(ctx, key).Result()
+	val, err := c.codec.Unmarshal(b)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	dur, err := ttl.Result()
 	if err != nil {
 		return nil, time.Time{}, err
 	}
@@ -50,7 +74,12 @@ func (c *redisCache) Get(ctx context.Context, key string) (interface{}, time.Tim
 }
 
 func (c *redisCache) Put(ctx context.Context, key string, val interface{}, dur time.Duration) error {
-	return c.client.Set(ctx, key, val, dur).Err()
+	b, err := c.codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	return c.client.Set(ctx, key, b, dur).Err()
 }
 
 func (c *redisCache) Delete(ctx context.Context, key string) error {
@@ -2,11 +2,16 @@ package redis
 
 import (
 	"context"
+	"encoding/gob"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"go-micro.dev/v4/cache"
+
+	"github.com/go-micro/plugins/v4/cache/codec"
 )
 
 var (
@@ -86,3 +91,60 @@ func TestCache(t *testing.T) {
 		}
 	})
 }
+
+func TestGet(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := NewCache(cache.WithAddress(mr.Addr()))
+
+	if _, _, err := c.Get(ctx, key); err != cache.ErrKeyNotFound {
+		t.Fatalf("expected key not found, got %v", err)
+	}
+
+	if err := c.Put(ctx, key, val, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	v, expiry, err := c.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(v.([]byte)) != val {
+		t.Fatalf("expected '%v', got '%v'", val, v)
+	}
+	if d := time.Until(expiry); d <= 0 || d > time.Minute {
+		t.Fatalf("expected to expire in a minute, got %v", d)
+	}
+
+	if err := c.Put(ctx, key, val, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, expiry, err := c.Get(ctx, key); err != nil {
+		t.Fatal(err)
+	} else if expiry != time.Unix(1<<63-1, 0) {
+		t.Fatalf("expected no expiry, got %v", expiry)
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+func TestCodec(t *testing.T) {
+	gob.Register(point{})
+	gob.Register(map[string]int{})
+
+	mr := miniredis.RunT(t)
+	c := NewCache(cache.WithAddress(mr.Addr()), codec.WithCodec(codec.GobCodec{}))
+
+	for _, v := range []interface{}{"hello", 42, 1.5, true, []string{"a", "b"}, map[string]int{"a": 1}, point{X: 1, Y: 2}} {
+		if err := c.Put(ctx, key, v, 0); err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := c.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Fatalf("expected %#v, got %#v", v, got)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/go-micro/plugins/v4/cache/tiered
+
+go 1.17
+
+require (
+	github.com/google/uuid v1.2.0
+	go-micro.dev/v4 v4.9.0
+)
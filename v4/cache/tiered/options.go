@@ -0,0 +1,54 @@
+package tiered
+
+import (
+	"context"
+	"time"
+
+	"go-micro.dev/v4/cache"
+)
+
+type l2Key struct{}
+type sizeKey struct{}
+type ttlKey struct{}
+type lfuKey struct{}
+type invalidatorKey struct{}
+
+// L2 sets the cache behind the in-process cache, e.g. cache/redis.
+func L2(c cache.Cache) cache.Option {
+	return setOption(l2Key{}, c)
+}
+
+// Size sets the number of items kept in process, DefaultSize by default.
+func Size(n int) cache.Option {
+	return setOption(sizeKey{}, n)
+}
+
+// TTL sets the time items are kept in process at most, DefaultTTL by
+// default. It bounds the staleness of an item if an invalidation is lost,
+// items expiring in the L2 cache earlier are dropped earlier. Zero keeps
+// items until they expire in the L2 cache or are evicted.
+func TTL(d time.Duration) cache.Option {
+	return setOption(ttlKey{}, d)
+}
+
+// LFU evicts the least frequently used items instead of the least recently
+// used ones.
+func LFU() cache.Option {
+	return setOption(lfuKey{}, true)
+}
+
+// WithInvalidator sets the invalidator dropping the in-process items written
+// or deleted by the other instances.
+func WithInvalidator(i Invalidator) cache.Option {
+	return setOption(invalidatorKey{}, i)
+}
+
+func setOption(k, v interface{}) cache.Option {
+	return func(o *cache.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
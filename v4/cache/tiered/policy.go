@@ -0,0 +1,131 @@
+package tiered
+
+import (
+	"container/heap"
+	"container/list"
+	"time"
+)
+
+// entry is an item kept in process.
+type entry struct {
+	key string
+	val interface{}
+	// expiry of the item in the L2 cache, returned by Get
+	expiry time.Time
+	// deadline of the item in process
+	deadline time.Time
+
+	// lru
+	elem *list.Element
+	// lfu
+	freq  int
+	tick  uint64
+	index int
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.deadline.IsZero() && now.After(e.deadline)
+}
+
+// policy picks the entries evicted when the cache is full.
+type policy interface {
+	add(e *entry)
+	touch(e *entry)
+	remove(e *entry)
+	victim() *entry
+}
+
+// lru evicts the least recently used entry.
+type lru struct {
+	l *list.List
+}
+
+func newLRU() *lru {
+	return &lru{l: list.New()}
+}
+
+func (p *lru) add(e *entry) {
+	e.elem = p.l.PushFront(e)
+}
+
+func (p *lru) touch(e *entry) {
+	p.l.MoveToFront(e.elem)
+}
+
+func (p *lru) remove(e *entry) {
+	p.l.Remove(e.elem)
+}
+
+func (p *lru) victim() *entry {
+	if back := p.l.Back(); back != nil {
+		return back.Value.(*entry)
+	}
+	return nil
+}
+
+// lfu evicts the least frequently used entry, the least recently used of
+// them on a tie.
+type lfu struct {
+	h    lfuHeap
+	tick uint64
+}
+
+func newLFU() *lfu {
+	return &lfu{}
+}
+
+func (p *lfu) add(e *entry) {
+	p.tick++
+	e.freq, e.tick = 1, p.tick
+	heap.Push(&p.h, e)
+}
+
+func (p *lfu) touch(e *entry) {
+	p.tick++
+	e.freq++
+	e.tick = p.tick
+	heap.Fix(&p.h, e.index)
+}
+
+func (p *lfu) remove(e *entry) {
+	heap.Remove(&p.h, e.index)
+}
+
+func (p *lfu) victim() *entry {
+	if len(p.h) == 0 {
+		return nil
+	}
+	return p.h[0]
+}
+
+type lfuHeap []*entry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
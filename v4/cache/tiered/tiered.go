@@ -0,0 +1,224 @@
+// Package tiered provides a cache keeping the hot items of another cache,
+// e.g. cache/redis, in process. The items written or deleted by the other
+// instances sharing that cache are dropped with an Invalidator.
+package tiered
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go-micro.dev/v4/cache"
+	"go-micro.dev/v4/logger"
+)
+
+var (
+	// DefaultSize is the number of items kept in process by default.
+	DefaultSize = 1024
+	// DefaultTTL is the time items are kept in process at most by default.
+	DefaultTTL = time.Minute
+)
+
+// Invalidator broadcasts invalidations between the instances sharing the L2
+// cache, cache/redis implements it with pub/sub.
+type Invalidator interface {
+	// Publish an invalidation to all the instances
+	Publish(ctx context.Context, msg string) error
+	// Subscribe calls the function with the invalidations until stopped
+	Subscribe(fn func(msg string)) (stop func() error, err error)
+}
+
+type tieredCache struct {
+	opts cache.Options
+	id   string
+	l2   cache.Cache
+	size int
+	ttl  time.Duration
+
+	invalidator Invalidator
+	stop        func() error
+
+	sync.Mutex
+	items  map[string]*entry
+	policy policy
+	// invalidations so far, items read from the L2 cache while one
+	// happens aren't kept as they may be stale already
+	invalidations uint64
+}
+
+// NewCache returns a cache keeping the items of the L2 cache in process.
+func NewCache(opts ...cache.Option) cache.Cache {
+	options := cache.NewOptions(opts...)
+	if options.Context == nil {
+		options.Context = context.Background()
+	}
+
+	c := &tieredCache{
+		opts:   options,
+		id:     uuid.New().String(),
+		l2:     cache.DefaultCache,
+		size:   DefaultSize,
+		ttl:    DefaultTTL,
+		items:  make(map[string]*entry),
+		policy: newLRU(),
+	}
+
+	if l2, ok := options.Context.Value(l2Key{}).(cache.Cache); ok {
+		c.l2 = l2
+	}
+	if size, ok := options.Context.Value(sizeKey{}).(int); ok && size > 0 {
+		c.size = size
+	}
+	if ttl, ok := options.Context.Value(ttlKey{}).(time.Duration); ok {
+		c.ttl = ttl
+	}
+	if lfu, ok := options.Context.Value(lfuKey{}).(bool); ok && lfu {
+		c.policy = newLFU()
+	}
+
+	if i, ok := options.Context.Value(invalidatorKey{}).(Invalidator); ok {
+		stop, err := i.Subscribe(c.invalidated)
+		if err != nil {
+			// the items are kept for the ttl at most still
+			options.Logger.Logf(logger.ErrorLevel, "Error subscribing to cache invalidations: %v", err)
+		} else {
+			c.invalidator = i
+			c.stop = stop
+		}
+	}
+
+	return c
+}
+
+func (c *tieredCache) Get(ctx context.Context, key string) (interface{}, time.Time, error) {
+	now := time.Now()
+
+	c.Lock()
+	if e, ok := c.items[key]; ok {
+		if !e.expired(now) {
+			c.policy.touch(e)
+			c.Unlock()
+			return e.val, e.expiry, nil
+		}
+		c.remove(e)
+	}
+	invalidations := c.invalidations
+	c.Unlock()
+
+	val, expiry, err := c.l2.Get(ctx, key)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	c.Lock()
+	if c.invalidations == invalidations {
+		c.add(key, val, expiry, now)
+	}
+	c.Unlock()
+
+	return val, expiry, nil
+}
+
+// Put the item in the L2 cache, it's read back from it on the next Get so
+// the value is the one the L2 cache returns.
+func (c *tieredCache) Put(ctx context.Context, key string, val interface{}, d time.Duration) error {
+	if err := c.l2.Put(ctx, key, val, d); err != nil {
+		return err
+	}
+
+	c.invalidate(ctx, key)
+
+	return nil
+}
+
+func (c *tieredCache) Delete(ctx context.Context, key string) error {
+	err := c.l2.Delete(ctx, key)
+
+	c.invalidate(ctx, key)
+
+	return err
+}
+
+func (c *tieredCache) String() string {
+	return "tiered"
+}
+
+// Close stops receiving invalidations.
+func (c *tieredCache) Close() error {
+	if c.stop == nil {
+		return nil
+	}
+	return c.stop()
+}
+
+// invalidate drops the item and publishes the invalidation to the other
+// instances.
+func (c *tieredCache) invalidate(ctx context.Context, key string) {
+	c.drop(key)
+
+	if c.invalidator == nil {
+		return
+	}
+
+	if err := c.invalidator.Publish(ctx, c.id+":"+key); err != nil {
+		// the other instances keep the item for the ttl at most
+		c.opts.Logger.Logf(logger.WarnLevel, "Error publishing cache invalidation of %s: %v", key, err)
+	}
+}
+
+// invalidated drops the item invalidated by another instance.
+func (c *tieredCache) invalidated(msg string) {
+	parts := strings.SplitN(msg, ":", 2)
+	if len(parts) != 2 || parts[0] == c.id {
+		return
+	}
+
+	c.drop(parts[1])
+}
+
+func (c *tieredCache) drop(key string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.invalidations++
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+}
+
+func (c *tieredCache) add(key string, val interface{}, expiry, now time.Time) {
+	// the item is dropped when it expires in the L2 cache, or after the
+	// ttl. An expiry in the past means there's none, as returned by the
+	// memory cache.
+	var deadline time.Time
+	if c.ttl > 0 {
+		deadline = now.Add(c.ttl)
+	}
+	if expiry.After(now) && (deadline.IsZero() || expiry.Before(deadline)) {
+		deadline = expiry
+	}
+
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+
+	for len(c.items) >= c.size {
+		c.remove(c.policy.victim())
+	}
+
+	e := &entry{
+		key:      key,
+		val:      val,
+		expiry:   expiry,
+		deadline: deadline,
+	}
+	c.items[key] = e
+	c.policy.add(e)
+}
+
+func (c *tieredCache) remove(e *entry) {
+	delete(c.items, e.key)
+	c.policy.remove(e)
+}
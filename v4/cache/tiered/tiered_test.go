@@ -0,0 +1,224 @@
+package tiered
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/cache"
+)
+
+var ctx = context.TODO()
+
+// countingCache counts the reads of the cache.
+type countingCache struct {
+	cache.Cache
+
+	sync.Mutex
+	gets map[string]int
+}
+
+func newCountingCache() *countingCache {
+	return &countingCache{Cache: cache.NewCache(), gets: map[string]int{}}
+}
+
+func (c *countingCache) Get(ctx context.Context, key string) (interface{}, time.Time, error) {
+	c.Lock()
+	c.gets[key]++
+	c.Unlock()
+	return c.Cache.Get(ctx, key)
+}
+
+func (c *countingCache) count(key string) int {
+	c.Lock()
+	defer c.Unlock()
+	return c.gets[key]
+}
+
+// bus broadcasts invalidations in process.
+type bus struct {
+	sync.Mutex
+	subs []func(string)
+}
+
+func (b *bus) Publish(ctx context.Context, msg string) error {
+	b.Lock()
+	defer b.Unlock()
+	for _, fn := range b.subs {
+		fn(msg)
+	}
+	return nil
+}
+
+func (b *bus) Subscribe(fn func(msg string)) (func() error, error) {
+	b.Lock()
+	defer b.Unlock()
+	b.subs = append(b.subs, fn)
+	return func() error { return nil }, nil
+}
+
+func get(t *testing.T, c cache.Cache, key string) interface{} {
+	t.Helper()
+	v, _, err := c.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("expected a value for %s, got err: %s", key, err)
+	}
+	return v
+}
+
+func TestGet(t *testing.T) {
+	l2 := newCountingCache()
+	c := NewCache(L2(l2))
+
+	if _, _, err := c.Get(ctx, "foo"); err != cache.ErrKeyNotFound {
+		t.Fatalf("expected key not found, got %v", err)
+	}
+
+	if err := c.Put(ctx, "foo", "bar", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if v := get(t, c, "foo"); v != "bar" {
+			t.Fatalf("expected bar, got %v", v)
+		}
+	}
+	if n := l2.count("foo"); n != 2 {
+		t.Fatalf("expected 2 reads of the L2 cache, got %d", n)
+	}
+
+	// the expiry is the one of the L2 cache
+	_, expiry, _ := c.Get(ctx, "foo")
+	if d := time.Until(expiry); d <= 0 || d > time.Minute {
+		t.Fatalf("expected to expire in a minute, got %v", d)
+	}
+
+	// writes drop the item
+	if err := c.Put(ctx, "foo", "baz", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v := get(t, c, "foo"); v != "baz" {
+		t.Fatalf("expected baz, got %v", v)
+	}
+
+	if err := c.Delete(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Get(ctx, "foo"); err != cache.ErrKeyNotFound {
+		t.Fatalf("expected key not found, got %v", err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	t.Run("TTL", func(t *testing.T) {
+		l2 := newCountingCache()
+		c := NewCache(L2(l2), TTL(20*time.Millisecond))
+
+		l2.Put(ctx, "foo", "bar", 0)
+		get(t, c, "foo")
+		get(t, c, "foo")
+
+		<-time.After(25 * time.Millisecond)
+		get(t, c, "foo")
+
+		if n := l2.count("foo"); n != 2 {
+			t.Fatalf("expected 2 reads of the L2 cache, got %d", n)
+		}
+	})
+
+	t.Run("L2", func(t *testing.T) {
+		l2 := newCountingCache()
+		c := NewCache(L2(l2), TTL(0))
+
+		l2.Put(ctx, "foo", "bar", 20*time.Millisecond)
+		get(t, c, "foo")
+
+		<-time.After(25 * time.Millisecond)
+		if _, _, err := c.Get(ctx, "foo"); err != cache.ErrItemExpired {
+			t.Fatalf("expected item expired, got %v", err)
+		}
+	})
+}
+
+func TestEviction(t *testing.T) {
+	t.Run("LRU", func(t *testing.T) {
+		l2 := newCountingCache()
+		c := NewCache(L2(l2), Size(2))
+
+		for _, k := range []string{"a", "b", "c"} {
+			l2.Put(ctx, k, k, 0)
+		}
+
+		get(t, c, "a")
+		get(t, c, "b")
+		get(t, c, "a")
+		// evicts b, the least recently used
+		get(t, c, "c")
+		get(t, c, "a")
+		get(t, c, "b")
+
+		if n := l2.count("a"); n != 1 {
+			t.Fatalf("expected a read once, got %d", n)
+		}
+		if n := l2.count("b"); n != 2 {
+			t.Fatalf("expected b read twice, got %d", n)
+		}
+	})
+
+	t.Run("LFU", func(t *testing.T) {
+		l2 := newCountingCache()
+		c := NewCache(L2(l2), Size(2), LFU())
+
+		for _, k := range []string{"a", "b", "c"} {
+			l2.Put(ctx, k, k, 0)
+		}
+
+		get(t, c, "a")
+		get(t, c, "a")
+		get(t, c, "a")
+		get(t, c, "b")
+		get(t, c, "b")
+		// evicts b, the least frequently used although used last
+		get(t, c, "c")
+		get(t, c, "a")
+		get(t, c, "b")
+
+		if n := l2.count("a"); n != 1 {
+			t.Fatalf("expected a read once, got %d", n)
+		}
+		if n := l2.count("b"); n != 2 {
+			t.Fatalf("expected b read twice, got %d", n)
+		}
+	})
+}
+
+func TestInvalidation(t *testing.T) {
+	l2 := cache.NewCache()
+	b := &bus{}
+
+	one := NewCache(L2(l2), WithInvalidator(b))
+	two := NewCache(L2(l2), WithInvalidator(b))
+
+	if err := one.Put(ctx, "foo", "bar", 0); err != nil {
+		t.Fatal(err)
+	}
+	get(t, one, "foo")
+	get(t, two, "foo")
+
+	// the other instance drops the item written
+	if err := two.Put(ctx, "foo", "baz", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v := get(t, one, "foo"); v != "baz" {
+		t.Fatalf("expected baz, got %v", v)
+	}
+
+	// and deleted
+	if err := two.Delete(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := one.Get(ctx, "foo"); err != cache.ErrKeyNotFound {
+		t.Fatalf("expected key not found, got %v", err)
+	}
+}
@@ -25,3 +25,14 @@ func subscriberFromContext(ctx context.Context) (map[string]MockSubscriber, bool
 func newSubscriberContext(ctx context.Context, r map[string]MockSubscriber) context.Context {
 	return context.WithValue(ctx, subscriberKey{}, r)
 }
+
+type streamKey struct{}
+
+func streamFromContext(ctx context.Context) (map[string][]MockStream, bool) {
+	r, ok := ctx.Value(streamKey{}).(map[string][]MockStream)
+	return r, ok
+}
+
+func newStreamContext(ctx context.Context, r map[string][]MockStream) context.Context {
+	return context.WithValue(ctx, streamKey{}, r)
+}
@@ -23,6 +23,22 @@ type MockResponse struct {
 
 type MockSubscriber func(client.Message) error
 
+// MockCall is a request made through the client.
+type MockCall struct {
+	Service  string
+	Endpoint string
+	Request  interface{}
+	Error    error
+}
+
+// MockPublication is a message published through the client.
+type MockPublication struct {
+	Topic       string
+	ContentType string
+	Payload     interface{}
+	Error       error
+}
+
 type MockClient struct {
 	Client client.Client
 	Opts   client.Options
@@ -30,6 +46,11 @@ type MockClient struct {
 	sync.Mutex
 	Response   map[string][]MockResponse
 	Subscriber map[string]MockSubscriber
+	Streams    map[string][]MockStream
+
+	calls        []MockCall
+	publications []MockPublication
+	streams      []*mockStream
 }
 
 func (m *MockClient) Init(opts ...client.Option) error {
@@ -46,6 +67,12 @@ func (m *MockClient) Init(opts ...client.Option) error {
 	}
 	m.Response = r
 
+	st, ok := streamFromContext(m.Opts.Context)
+	if !ok {
+		st = make(map[string][]MockStream)
+	}
+	m.Streams = st
+
 	return nil
 }
 
@@ -65,6 +92,18 @@ func (m *MockClient) Call(ctx context.Context, req client.Request, rsp interface
 	m.Lock()
 	defer m.Unlock()
 
+	err := m.call(ctx, req, rsp)
+	m.calls = append(m.calls, MockCall{
+		Service:  req.Service(),
+		Endpoint: req.Endpoint(),
+		Request:  req.Body(),
+		Error:    err,
+	})
+
+	return err
+}
+
+func (m *MockClient) call(ctx context.Context, req client.Request, rsp interface{}) error {
 	response, ok := m.Response[req.Service()]
 	if !ok {
 		return errors.NotFound("go.micro.client.mock", "service not found")
@@ -119,18 +158,51 @@ func (m *MockClient) Stream(ctx context.Context, req client.Request, opts ...cli
 	m.Lock()
 	defer m.Unlock()
 
-	// TODO: mock stream
-	return nil, nil
+	streams, ok := m.Streams[req.Service()]
+	if !ok {
+		return nil, errors.NotFound("go.micro.client.mock", "service not found")
+	}
+
+	for _, st := range streams {
+		if st.Endpoint != req.Endpoint() {
+			continue
+		}
+
+		if st.Error != nil {
+			return nil, st.Error
+		}
+
+		handler := st.Handler
+		if handler == nil {
+			handler = scriptHandler(st.Messages)
+		}
+
+		s := newMockStream(ctx, req.Service(), req, handler)
+		m.streams = append(m.streams, s)
+
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("rpc: can't find service %s", req.Endpoint())
 }
 
 func (m *MockClient) Publish(ctx context.Context, p client.Message, opts ...client.PublishOption) error {
 	m.Lock()
 	defer m.Unlock()
 
+	var err error
 	if s, ok := m.Subscriber[p.Topic()]; ok {
-		return s(p)
+		err = s(p)
 	}
-	return nil
+
+	m.publications = append(m.publications, MockPublication{
+		Topic:       p.Topic(),
+		ContentType: p.ContentType(),
+		Payload:     p.Payload(),
+		Error:       err,
+	})
+
+	return err
 }
 
 func (m *MockClient) String() string {
@@ -165,6 +237,81 @@ func (m *MockClient) SetSubscriber(topic string, subscriber MockSubscriber) {
 	m.Subscriber[topic] = subscriber
 }
 
+func (m *MockClient) SetStream(service string, stream MockStream) {
+	m.Lock()
+	defer m.Unlock()
+
+	for i, s := range m.Streams[service] {
+		if s.Endpoint == stream.Endpoint {
+			// update service endpoint
+			m.Streams[service][i] = stream
+			return
+		}
+	}
+	// add new endpoint for service
+	m.Streams[service] = append(m.Streams[service], stream)
+}
+
+// Calls returns the requests made to the service endpoint, an empty
+// service or endpoint matches any.
+func (m *MockClient) Calls(service, endpoint string) []MockCall {
+	m.Lock()
+	defer m.Unlock()
+
+	var calls []MockCall
+	for _, c := range m.calls {
+		if match(service, c.Service) && match(endpoint, c.Endpoint) {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Publications returns the messages published to the topic, an empty
+// topic matches any.
+func (m *MockClient) Publications(topic string) []MockPublication {
+	m.Lock()
+	defer m.Unlock()
+
+	var pubs []MockPublication
+	for _, p := range m.publications {
+		if match(topic, p.Topic) {
+			pubs = append(pubs, p)
+		}
+	}
+	return pubs
+}
+
+// StreamCalls returns the streams opened to the service endpoint along
+// with the messages sent on them, an empty service or endpoint matches any.
+func (m *MockClient) StreamCalls(service, endpoint string) []MockStreamCall {
+	m.Lock()
+	defer m.Unlock()
+
+	var calls []MockStreamCall
+	for _, s := range m.streams {
+		c := s.call()
+		if match(service, c.Service) && match(endpoint, c.Endpoint) {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset clears the recorded calls, publications and streams.
+func (m *MockClient) Reset() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.calls = nil
+	m.publications = nil
+	m.streams = nil
+}
+
+func match(want, got string) bool {
+	return len(want) == 0 || want == got
+}
+
 func NewClient(opts ...client.Option) *MockClient {
 	options := client.Options{
 		Context: context.TODO(),
@@ -184,10 +331,16 @@ func NewClient(opts ...client.Option) *MockClient {
 		s = make(map[string]MockSubscriber)
 	}
 
+	st, ok := streamFromContext(options.Context)
+	if !ok {
+		st = make(map[string][]MockStream)
+	}
+
 	return &MockClient{
 		Client:     client.DefaultClient,
 		Opts:       options,
 		Response:   r,
 		Subscriber: s,
+		Streams:    st,
 	}
 }
@@ -27,3 +27,15 @@ func Subscriber(topic string, subscriber MockSubscriber) client.Option {
 		o.Context = newSubscriberContext(o.Context, r)
 	}
 }
+
+// Stream sets the streams for a service.
+func Stream(service string, streams []MockStream) client.Option {
+	return func(o *client.Options) {
+		r, ok := streamFromContext(o.Context)
+		if !ok {
+			r = make(map[string][]MockStream)
+		}
+		r[service] = streams
+		o.Context = newStreamContext(o.Context, r)
+	}
+}
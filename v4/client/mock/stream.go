@@ -0,0 +1,355 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/codec"
+	"go-micro.dev/v4/errors"
+)
+
+// MockStream scripts the server side of a stream for an endpoint. Either
+// Messages or Handler is used, Handler takes precedence.
+type MockStream struct {
+	Endpoint string
+	// Messages is the scripted exchange, played in order
+	Messages []MockStreamMessage
+	// Handler acts as the server side of the stream
+	Handler MockStreamHandler
+	// Error is returned when opening the stream
+	Error error
+}
+
+// MockStreamMessage is a step of a scripted stream. The expected Send is
+// received before Recv is delivered or Error ends the stream.
+type MockStreamMessage struct {
+	// Send is the message the client is expected to send, nil expects nothing
+	Send interface{}
+	// Recv is the message returned by the client's next Recv
+	Recv interface{}
+	// Error ends the stream, it's returned by the client's next Recv
+	Error error
+}
+
+// MockStreamHandler is the server side of a mock stream. Returning nil
+// ends the stream with io.EOF, any other error is returned to the client.
+type MockStreamHandler func(ctx context.Context, stream MockServerStream) error
+
+// MockServerStream is the server side view of a mock stream.
+type MockServerStream interface {
+	// Context of the stream, done when the client closes it
+	Context() context.Context
+	// Request the stream was opened with
+	Request() client.Request
+	// Send a message to the client
+	Send(interface{}) error
+	// Recv the next message sent by the client, io.EOF after CloseSend
+	Recv() (interface{}, error)
+}
+
+// MockStreamCall is a stream opened through the client.
+type MockStreamCall struct {
+	Service  string
+	Endpoint string
+	Request  interface{}
+	// Sent are the messages sent by the client
+	Sent []interface{}
+	// Error the stream ended with, if any
+	Error error
+}
+
+// queue is an unbounded message queue between the two sides of a stream.
+type queue struct {
+	sync.Mutex
+	items  []interface{}
+	closed bool
+	err    error
+	notify chan struct{}
+}
+
+func newQueue() *queue {
+	return &queue{notify: make(chan struct{}, 1)}
+}
+
+func (q *queue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue) push(v interface{}) error {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.closed {
+		return q.err
+	}
+	q.items = append(q.items, v)
+	q.signal()
+	return nil
+}
+
+func (q *queue) pop(ctx context.Context) (interface{}, error) {
+	for {
+		q.Lock()
+		if len(q.items) > 0 {
+			v := q.items[0]
+			q.items = q.items[1:]
+			q.Unlock()
+			return v, nil
+		}
+		if q.closed {
+			q.Unlock()
+			return nil, q.err
+		}
+		q.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// close the queue, pending items can still be read. Pushing or reading
+// past them returns err.
+func (q *queue) close(err error) {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.err = err
+	q.signal()
+}
+
+type mockStream struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	service string
+	request client.Request
+
+	// client to server
+	in *queue
+	// server to client
+	out *queue
+
+	sync.RWMutex
+	sent   []interface{}
+	err    error
+	closed bool
+}
+
+func newMockStream(ctx context.Context, service string, req client.Request, handler MockStreamHandler) *mockStream {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &mockStream{
+		ctx:     ctx,
+		cancel:  cancel,
+		service: service,
+		request: req,
+		in:      newQueue(),
+		out:     newQueue(),
+	}
+
+	go func() {
+		err := handler(ctx, &mockServerStream{s})
+		if err != nil {
+			s.Lock()
+			s.err = err
+			s.Unlock()
+			s.out.close(err)
+		} else {
+			s.out.close(io.EOF)
+		}
+		// the server is gone
+		s.in.close(io.EOF)
+	}()
+
+	return s
+}
+
+func (s *mockStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *mockStream) Request() client.Request {
+	return s.request
+}
+
+func (s *mockStream) Response() client.Response {
+	return &mockResponse{}
+}
+
+func (s *mockStream) Send(msg interface{}) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.in.push(msg); err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.sent = append(s.sent, msg)
+	s.Unlock()
+
+	return nil
+}
+
+func (s *mockStream) Recv(msg interface{}) error {
+	v, err := s.out.pop(s.ctx)
+	if err != nil {
+		if err != io.EOF {
+			s.Lock()
+			if s.err == nil {
+				s.err = err
+			}
+			s.Unlock()
+		}
+		return err
+	}
+
+	return setValue(msg, v)
+}
+
+func (s *mockStream) Error() error {
+	s.RLock()
+	defer s.RUnlock()
+	return s.err
+}
+
+func (s *mockStream) CloseSend() error {
+	s.in.close(io.EOF)
+	return nil
+}
+
+func (s *mockStream) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.cancel()
+	s.in.close(io.EOF)
+	s.out.close(io.EOF)
+	return nil
+}
+
+func (s *mockStream) call() MockStreamCall {
+	s.RLock()
+	defer s.RUnlock()
+
+	sent := make([]interface{}, len(s.sent))
+	copy(sent, s.sent)
+
+	return MockStreamCall{
+		Service:  s.service,
+		Endpoint: s.request.Endpoint(),
+		Request:  s.request.Body(),
+		Sent:     sent,
+		Error:    s.err,
+	}
+}
+
+type mockServerStream struct {
+	s *mockStream
+}
+
+func (m *mockServerStream) Context() context.Context {
+	return m.s.ctx
+}
+
+func (m *mockServerStream) Request() client.Request {
+	return m.s.request
+}
+
+func (m *mockServerStream) Send(msg interface{}) error {
+	if err := m.s.ctx.Err(); err != nil {
+		return err
+	}
+	return m.s.out.push(msg)
+}
+
+func (m *mockServerStream) Recv() (interface{}, error) {
+	return m.s.in.pop(m.s.ctx)
+}
+
+type mockResponse struct{}
+
+func (r *mockResponse) Codec() codec.Reader {
+	return nil
+}
+
+func (r *mockResponse) Header() map[string]string {
+	return map[string]string{}
+}
+
+func (r *mockResponse) Read() ([]byte, error) {
+	return nil, nil
+}
+
+// scriptHandler plays the scripted messages as the server side of a stream.
+func scriptHandler(messages []MockStreamMessage) MockStreamHandler {
+	return func(ctx context.Context, stream MockServerStream) error {
+		for i, m := range messages {
+			if m.Send != nil {
+				v, err := stream.Recv()
+				if err != nil {
+					return errors.BadRequest("go.micro.client.mock", "expected message %d %v: %v", i, m.Send, err)
+				}
+				if !reflect.DeepEqual(v, m.Send) {
+					return errors.BadRequest("go.micro.client.mock", "unexpected message %d: expected %v got %v", i, m.Send, v)
+				}
+			}
+
+			if m.Error != nil {
+				return m.Error
+			}
+
+			if m.Recv != nil {
+				if err := stream.Send(m.Recv); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// setValue sets the value pointed to by dst to src, or to the value
+// pointed to by src.
+func setValue(dst, src interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("mock: can't decode into %T", dst)
+	}
+	v = v.Elem()
+
+	sv := reflect.ValueOf(src)
+	if !sv.IsValid() {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch {
+	case sv.Type().AssignableTo(v.Type()):
+		v.Set(sv)
+	case sv.Kind() == reflect.Ptr && !sv.IsNil() && sv.Elem().Type().AssignableTo(v.Type()):
+		v.Set(sv.Elem())
+	default:
+		return fmt.Errorf("mock: can't decode %T into %T", src, dst)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package mock
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/errors"
+)
+
+func TestStreamScript(t *testing.T) {
+	type Msg struct {
+		Value string
+	}
+
+	c := NewClient(Stream("go.mock", []MockStream{
+		{Endpoint: "Foo.Stream", Messages: []MockStreamMessage{
+			{Send: &Msg{Value: "ping"}, Recv: &Msg{Value: "pong"}},
+			{Recv: &Msg{Value: "pong2"}},
+			{Send: &Msg{Value: "bye"}},
+		}},
+		{Endpoint: "Foo.Fail", Error: errors.InternalServerError("go.mock", "failed")},
+	}))
+
+	req := c.NewRequest("go.mock", "Foo.Stream", &Msg{Value: "open"})
+	s, err := c.Stream(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Send(&Msg{Value: "ping"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"pong", "pong2"} {
+		var rsp Msg
+		if err := s.Recv(&rsp); err != nil {
+			t.Fatal(err)
+		}
+		if rsp.Value != want {
+			t.Fatalf("expected %s got %s", want, rsp.Value)
+		}
+	}
+
+	if err := s.Send(&Msg{Value: "bye"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rsp Msg
+	if err := s.Recv(&rsp); err != io.EOF {
+		t.Fatalf("expected EOF got %v", err)
+	}
+	if err := s.Error(); err != nil {
+		t.Fatalf("unexpected stream error %v", err)
+	}
+	s.Close()
+
+	calls := c.StreamCalls("go.mock", "Foo.Stream")
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 stream got %d", len(calls))
+	}
+	if len(calls[0].Sent) != 2 || calls[0].Sent[1].(*Msg).Value != "bye" {
+		t.Fatalf("unexpected sent messages %v", calls[0].Sent)
+	}
+
+	if _, err := c.Stream(context.TODO(), c.NewRequest("go.mock", "Foo.Fail", nil)); err == nil {
+		t.Fatal("expected error opening stream")
+	}
+}
+
+func TestStreamUnexpectedMessage(t *testing.T) {
+	c := NewClient(Stream("go.mock", []MockStream{
+		{Endpoint: "Foo.Stream", Messages: []MockStreamMessage{
+			{Send: "foo", Recv: "bar"},
+		}},
+	}))
+
+	s, err := c.Stream(context.TODO(), c.NewRequest("go.mock", "Foo.Stream", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Send("baz"); err != nil {
+		t.Fatal(err)
+	}
+
+	var rsp string
+	if err := s.Recv(&rsp); err == nil || err == io.EOF {
+		t.Fatalf("expected mismatch error got %v", err)
+	}
+	if s.Error() == nil {
+		t.Fatal("expected stream error")
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	c := NewClient()
+	c.SetStream("go.mock", MockStream{
+		Endpoint: "Foo.Echo",
+		Handler: func(ctx context.Context, stream MockServerStream) error {
+			for {
+				msg, err := stream.Recv()
+				if err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				if err := stream.Send(msg); err != nil {
+					return err
+				}
+			}
+		},
+	})
+
+	s, err := c.Stream(context.TODO(), c.NewRequest("go.mock", "Foo.Echo", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := s.Send(i); err != nil {
+			t.Fatal(err)
+		}
+		var rsp int
+		if err := s.Recv(&rsp); err != nil {
+			t.Fatal(err)
+		}
+		if rsp != i {
+			t.Fatalf("expected %d got %d", i, rsp)
+		}
+	}
+	s.CloseSend()
+
+	var rsp int
+	if err := s.Recv(&rsp); err != io.EOF {
+		t.Fatalf("expected EOF got %v", err)
+	}
+}
+
+func TestStreamContext(t *testing.T) {
+	c := NewClient()
+	c.SetStream("go.mock", MockStream{
+		Endpoint: "Foo.Block",
+		Handler: func(ctx context.Context, stream MockServerStream) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	s, err := c.Stream(ctx, c.NewRequest("go.mock", "Foo.Block", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rsp string
+	if err := s.Recv(&rsp); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded got %v", err)
+	}
+	if err := s.Send("foo"); err == nil {
+		t.Fatal("expected error sending on cancelled stream")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var received int
+
+	c := NewClient(
+		Response("go.mock", []MockResponse{{Endpoint: "Foo.Bar", Response: "bar"}}),
+		Subscriber("topic", func(m client.Message) error { received++; return nil }),
+	)
+
+	for i := 0; i < 2; i++ {
+		var rsp interface{}
+		if err := c.Call(context.TODO(), c.NewRequest("go.mock", "Foo.Bar", i), &rsp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var rsp interface{}
+	if err := c.Call(context.TODO(), c.NewRequest("go.mock", "Foo.Missing", nil), &rsp); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if n := len(c.Calls("go.mock", "Foo.Bar")); n != 2 {
+		t.Fatalf("expected 2 calls got %d", n)
+	}
+	if calls := c.Calls("", ""); len(calls) != 3 || calls[1].Request != 1 || calls[2].Error == nil {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+
+	if err := c.Publish(context.TODO(), c.NewMessage("topic", "foo")); err != nil {
+		t.Fatal(err)
+	}
+	pubs := c.Publications("topic")
+	if len(pubs) != 1 || pubs[0].Payload != "foo" || received != 1 {
+		t.Fatalf("unexpected publications %v", pubs)
+	}
+
+	c.Reset()
+	if len(c.Calls("", "")) != 0 || len(c.Publications("")) != 0 {
+		t.Fatal("expected recordings to be cleared")
+	}
+}
@@ -2,11 +2,13 @@ package url
 
 import (
 	"context"
+	"time"
 
 	"go-micro.dev/v4/config/source"
 )
 
 type urlKey struct{}
+type intervalKey struct{}
 
 func WithURL(u string) source.Option {
 	return func(o *source.Options) {
@@ -16,3 +18,13 @@ func WithURL(u string) source.Option {
 		o.Context = context.WithValue(o.Context, urlKey{}, u)
 	}
 }
+
+// WithInterval sets how often the watcher polls the url for changes.
+func WithInterval(d time.Duration) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, intervalKey{}, d)
+	}
+}
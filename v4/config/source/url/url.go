@@ -2,32 +2,65 @@
 package url
 
 import (
+	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"go-micro.dev/v4/config/source"
 )
 
 type urlSource struct {
-	url  string
-	opts source.Options
+	url      string
+	interval time.Duration
+	opts     source.Options
+
+	sync.RWMutex
+	// last successfully fetched changeset and its cache validators,
+	// used by the watcher to detect changes
+	last         *source.ChangeSet
+	etag         string
+	lastModified string
 }
 
 var (
 	DefaultURL = "http://localhost:8080/config"
+	// DefaultInterval is how often the watcher polls the url.
+	DefaultInterval = 30 * time.Second
 )
 
-func (u *urlSource) Read() (*source.ChangeSet, error) {
-	rsp, err := http.Get(u.url)
+// fetch requests the url, sending the given cache validators as a
+// conditional request. A nil changeset with a nil error means the
+// content was not modified.
+func (u *urlSource) fetch(etag, lastModified string) (*source.ChangeSet, *http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u.url, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if len(etag) > 0 {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if len(lastModified) > 0 {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
 	}
 	defer rsp.Body.Close()
 
+	if rsp.StatusCode == http.StatusNotModified {
+		return nil, rsp, nil
+	}
+	if rsp.StatusCode >= http.StatusBadRequest {
+		return nil, nil, fmt.Errorf("url source: unexpected status %s", rsp.Status)
+	}
+
 	b, err := io.ReadAll(rsp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	ft := format(rsp.Header.Get("Content-Type"))
@@ -43,6 +76,24 @@ func (u *urlSource) Read() (*source.ChangeSet, error) {
 	}
 	cs.Checksum = cs.Sum()
 
+	return cs, rsp, nil
+}
+
+// update records the changeset and validators of the latest response.
+func (u *urlSource) update(cs *source.ChangeSet, rsp *http.Response) {
+	u.Lock()
+	defer u.Unlock()
+	u.last = cs
+	u.etag = rsp.Header.Get("ETag")
+	u.lastModified = rsp.Header.Get("Last-Modified")
+}
+
+func (u *urlSource) Read() (*source.ChangeSet, error) {
+	cs, rsp, err := u.fetch("", "")
+	if err != nil {
+		return nil, err
+	}
+	u.update(cs, rsp)
 	return cs, nil
 }
 
@@ -67,5 +118,10 @@ func NewSource(opts ...source.Option) source.Source {
 		url = DefaultURL
 	}
 
-	return &urlSource{url: url, opts: options}
+	interval, ok := options.Context.Value(intervalKey{}).(time.Duration)
+	if !ok || interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &urlSource{url: url, interval: interval, opts: options}
 }
@@ -1,9 +1,10 @@
 package url
 
 import (
-	"errors"
+	"time"
 
 	"go-micro.dev/v4/config/source"
+	"go-micro.dev/v4/util/backoff"
 )
 
 type urlWatcher struct {
@@ -18,15 +19,58 @@ func newWatcher(u *urlSource) (*urlWatcher, error) {
 	}, nil
 }
 
+// Next polls the url until its content changes. Requests are conditional
+// on the last seen ETag/Last-Modified and the response is compared by
+// checksum, so only actual changes are returned. Fetch errors are retried
+// with backoff.
 func (u *urlWatcher) Next() (*source.ChangeSet, error) {
-	<-u.exit
-	return nil, errors.New("url watcher stopped")
+	var attempts int
+
+	for {
+		wait := u.u.interval
+		if attempts > 0 {
+			if d := backoff.Do(attempts); d > wait {
+				wait = d
+			}
+		}
+
+		select {
+		case <-u.exit:
+			return nil, source.ErrWatcherStopped
+		case <-time.After(wait):
+		}
+
+		u.u.RLock()
+		last, etag, lastModified := u.u.last, u.u.etag, u.u.lastModified
+		u.u.RUnlock()
+
+		cs, rsp, err := u.u.fetch(etag, lastModified)
+		if err != nil {
+			attempts++
+			continue
+		}
+		attempts = 0
+
+		// not modified
+		if cs == nil {
+			continue
+		}
+
+		u.u.update(cs, rsp)
+
+		if last != nil && last.Checksum == cs.Checksum {
+			continue
+		}
+
+		return cs, nil
+	}
 }
 
 func (u *urlWatcher) Stop() error {
 	select {
 	case <-u.exit:
 	default:
+		close(u.exit)
 	}
 	return nil
 }
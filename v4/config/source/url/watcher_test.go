@@ -0,0 +1,126 @@
+package url
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		data     = `{"foo":"bar"}`
+		etag     = `"1"`
+		requests int
+		notMod   int
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			notMod++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(data))
+	}))
+	defer srv.Close()
+
+	src := NewSource(WithURL(srv.URL), WithInterval(10*time.Millisecond))
+
+	cs, err := src.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cs.Data) != data {
+		t.Fatalf("expected %s got %s", data, cs.Data)
+	}
+
+	w, err := src.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// change the content after a few unmodified polls
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		data = `{"foo":"baz"}`
+		etag = `"2"`
+		mu.Unlock()
+	}()
+
+	cs, err = w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cs.Data) != `{"foo":"baz"}` {
+		t.Fatalf("expected changed data got %s", cs.Data)
+	}
+
+	mu.Lock()
+	if notMod == 0 {
+		t.Fatalf("expected conditional requests, got %d requests", requests)
+	}
+	mu.Unlock()
+
+	if err := w.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Next(); err == nil {
+		t.Fatal("expected error from stopped watcher")
+	}
+}
+
+func TestWatcherChecksum(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		data = `{"foo":"bar"}`
+	)
+
+	// no validators, so every poll returns the full content
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(data))
+	}))
+	defer srv.Close()
+
+	src := NewSource(WithURL(srv.URL), WithInterval(10*time.Millisecond))
+	if _, err := src.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := src.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		data = `{"foo":"baz"}`
+		mu.Unlock()
+	}()
+
+	start := time.Now()
+	cs, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cs.Data) != `{"foo":"baz"}` {
+		t.Fatalf("expected changed data got %s", cs.Data)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("unchanged content returned as a change")
+	}
+}
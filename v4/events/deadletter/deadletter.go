@@ -0,0 +1,75 @@
+// Package deadletter holds the metadata of the dead letters of the events
+// plugins, and redrives them to the topic they were consumed from.
+package deadletter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-micro.dev/v4/events"
+)
+
+const (
+	// MetadataTopic is the metadata key of the topic a dead letter was consumed from.
+	MetadataTopic = "dead_letter_topic"
+	// MetadataAttempts is the metadata key of the number of attempts to process a dead letter.
+	MetadataAttempts = "dead_letter_attempts"
+	// MetadataError is the metadata key of the reason a message was dead lettered.
+	MetadataError = "dead_letter_error"
+	// MetadataTimestamp is the metadata key of the time a message was dead lettered.
+	MetadataTimestamp = "dead_letter_timestamp"
+)
+
+var (
+	// ErrNotDeadLetter is returned when redriving an event that isn't a dead letter.
+	ErrNotDeadLetter = errors.New("not a dead letter")
+)
+
+// New returns the dead letter of an event consumed from its topic, published
+// to the topic with the suffix. The metadata of the event is kept.
+func New(ev events.Event, suffix string, attempts int, reason error) *events.Event {
+	md := make(map[string]string, len(ev.Metadata)+4)
+	for k, v := range ev.Metadata {
+		md[k] = v
+	}
+	md[MetadataTopic] = ev.Topic
+	md[MetadataAttempts] = strconv.Itoa(attempts)
+	md[MetadataError] = reason.Error()
+	md[MetadataTimestamp] = time.Now().Format(time.RFC3339Nano)
+
+	return &events.Event{
+		ID:        ev.ID,
+		Topic:     ev.Topic + suffix,
+		Timestamp: ev.Timestamp,
+		Metadata:  md,
+		Payload:   ev.Payload,
+	}
+}
+
+// Redrive publishes a dead letter back to the topic it was consumed from,
+// with its original metadata and timestamp.
+func Redrive(s events.Stream, ev events.Event) error {
+	topic, ok := ev.Metadata[MetadataTopic]
+	if !ok {
+		return ErrNotDeadLetter
+	}
+
+	md := make(map[string]string, len(ev.Metadata))
+	for k, v := range ev.Metadata {
+		switch k {
+		case MetadataTopic, MetadataAttempts, MetadataError, MetadataTimestamp:
+		default:
+			md[k] = v
+		}
+	}
+
+	return s.Publish(topic, ev.Payload, events.WithMetadata(md), events.WithTimestamp(ev.Timestamp))
+}
+
+// RetryLimitError is the reason of the messages dead lettered after
+// exceeding the retry limit of the consumer.
+func RetryLimitError(limit int) error {
+	return fmt.Errorf("retry limit of %d exceeded", limit)
+}
@@ -0,0 +1,71 @@
+package deadletter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/events"
+)
+
+// stream records the events published.
+type stream struct {
+	events.Stream
+
+	published []events.Event
+}
+
+func (s *stream) Publish(topic string, msg interface{}, opts ...events.PublishOption) error {
+	var options events.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	s.published = append(s.published, events.Event{
+		Topic:     topic,
+		Timestamp: options.Timestamp,
+		Metadata:  options.Metadata,
+		Payload:   msg.([]byte),
+	})
+
+	return nil
+}
+
+func TestRedrive(t *testing.T) {
+	ev := events.Event{
+		ID:        "1",
+		Topic:     "orders",
+		Timestamp: time.Unix(1, 0),
+		Metadata:  map[string]string{"meta": "bar"},
+		Payload:   []byte("{}"),
+	}
+
+	dl := New(ev, "-dlq", 2, errors.New("failed"))
+	if dl.Topic != "orders-dlq" || dl.ID != ev.ID || !dl.Timestamp.Equal(ev.Timestamp) {
+		t.Fatalf("unexpected dead letter %+v", dl)
+	}
+	if dl.Metadata[MetadataTopic] != "orders" || dl.Metadata[MetadataAttempts] != "2" || dl.Metadata[MetadataError] != "failed" {
+		t.Fatalf("unexpected metadata %v", dl.Metadata)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, dl.Metadata[MetadataTimestamp]); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &stream{}
+	if err := Redrive(s, *dl); err != nil {
+		t.Fatal(err)
+	}
+
+	redriven := s.published[0]
+	if redriven.Topic != "orders" || !redriven.Timestamp.Equal(ev.Timestamp) || string(redriven.Payload) != "{}" {
+		t.Fatalf("unexpected event %+v", redriven)
+	}
+	if !reflect.DeepEqual(redriven.Metadata, ev.Metadata) {
+		t.Fatalf("expected the original metadata, got %v", redriven.Metadata)
+	}
+
+	if err := Redrive(s, ev); err != ErrNotDeadLetter {
+		t.Fatalf("expected %v, got %v", ErrNotDeadLetter, err)
+	}
+}
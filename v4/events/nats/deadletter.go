@@ -0,0 +1,37 @@
+package nats
+
+import (
+	"encoding/json"
+
+	stan "github.com/nats-io/stan.go"
+
+	"go-micro.dev/v4/events"
+	"go-micro.dev/v4/logger"
+
+	"github.com/go-micro/plugins/v4/events/deadletter"
+)
+
+// deadLetter publishes the event to the dead letter topic of its topic and
+// acknowledges the message, it returns false without one or on failure.
+func (s *stream) deadLetter(m *stan.Msg, ev events.Event, attempts int, reason error) bool {
+	if len(s.opts.DeadLetterSuffix) == 0 {
+		return false
+	}
+
+	bytes, err := json.Marshal(deadletter.New(ev, s.opts.DeadLetterSuffix, attempts, reason))
+	if err != nil {
+		s.opts.Logger.Logf(logger.ErrorLevel, "Error encoding dead letter: %v", err)
+		return false
+	}
+
+	if err := s.conn.Publish(ev.Topic+s.opts.DeadLetterSuffix, bytes); err != nil {
+		s.opts.Logger.Logf(logger.ErrorLevel, "Error dead lettering message: %v", err)
+		return false
+	}
+
+	if err := m.Ack(); err != nil {
+		s.opts.Logger.Logf(logger.ErrorLevel, "Error acknowledging dead letter: %v", err)
+	}
+
+	return true
+}
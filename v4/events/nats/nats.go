@@ -1,6 +1,7 @@
 package nats
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -13,6 +14,8 @@ import (
 
 	"go-micro.dev/v4/events"
 	"go-micro.dev/v4/logger"
+
+	"github.com/go-micro/plugins/v4/events/deadletter"
 )
 
 const (
@@ -20,6 +23,11 @@ const (
 	reconnectLoopDuration = 10 * time.Second
 )
 
+var (
+	// ErrClosed is returned when consuming from a closed stream.
+	ErrClosed = errors.New("stream closed")
+)
+
 // NewStream returns an initialized nats stream or an error if the connection to the nats
 // server could not be established.
 func NewStream(opts ...Option) (events.Stream, error) {
@@ -33,7 +41,7 @@ func NewStream(opts ...Option) (events.Stream, error) {
 		o(&options)
 	}
 
-	s := &stream{opts: options}
+	s := &stream{opts: options, done: make(chan struct{})}
 	clusterConn, err := connectToStan(options, s.connectionLost)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to nats cluster %v: %v", options.ClusterID, err)
@@ -43,6 +51,16 @@ func NewStream(opts ...Option) (events.Stream, error) {
 	return s, nil
 }
 
+// ContextConsumer is implemented by the streams of NewStream, consuming from
+// a topic until the context is done:
+//
+//	ch, err := stream.(nats.ContextConsumer).ConsumeContext(ctx, "topic")
+type ContextConsumer interface {
+	ConsumeContext(ctx context.Context, topic string, opts ...events.ConsumeOption) (<-chan events.Event, error)
+}
+
+var _ ContextConsumer = (*stream)(nil)
+
 type streamStatus string
 
 const (
@@ -55,6 +73,12 @@ type stream struct {
 	conn    stan.Conn
 	connMux sync.Mutex
 	status  streamStatus
+
+	// consumers in flight, stopped on close
+	mtx       sync.Mutex
+	closed    bool
+	done      chan struct{}
+	consumers sync.WaitGroup
 }
 
 func connectToStan(options Options, handler stan.ConnectionLostHandler) (stan.Conn, error) {
@@ -88,7 +112,12 @@ func (s *stream) connectionLost(conn stan.Conn, err error) {
 			clusterConn, err := connectToStan(s.opts, s.connectionLost)
 			if err != nil {
 				logger.Errorf("Error trying to reconnect %s. Will retry in %s", err, reconnectLoopDuration)
-				time.Sleep(reconnectLoopDuration)
+				select {
+				case <-time.After(reconnectLoopDuration):
+				case <-s.done:
+					// closed while reconnecting
+					return
+				}
 				continue
 			}
 			logger.Infof("Successfully reconnected to stan cluster")
@@ -151,6 +180,15 @@ func (s *stream) Publish(topic string, msg interface{}, opts ...events.PublishOp
 
 // Consume to a topic.
 func (s *stream) Consume(topic string, opts ...events.ConsumeOption) (<-chan events.Event, error) {
+	return s.ConsumeContext(context.Background(), topic, opts...)
+}
+
+// ConsumeContext consumes from a topic until the context is done or the
+// stream is closed. The channel is closed once the consumer stopped, the
+// messages in flight aren't acknowledged and are redelivered after the ack
+// wait. The subscription of a group generated for the call is removed, named
+// groups are kept.
+func (s *stream) ConsumeContext(ctx context.Context, topic string, opts ...events.ConsumeOption) (<-chan events.Event, error) {
 	// validate the topic
 	if len(topic) == 0 {
 		return nil, events.ErrMissingTopic
@@ -160,31 +198,55 @@ func (s *stream) Consume(topic string, opts ...events.ConsumeOption) (<-chan eve
 
 	// parse the options
 	options := events.ConsumeOptions{
-		Group:   uuid.New().String(),
 		AutoAck: true,
 	}
 	for _, o := range opts {
 		o(&options)
 	}
 
+	// the subscription of a generated group isn't shared and goes with the call
+	ephemeral := len(options.Group) == 0
+	if ephemeral {
+		options.Group = uuid.New().String()
+	}
+
 	// setup the subscriber
-	c := make(chan events.Event)
+	c := newConsumer()
 	handleMsg := func(m *stan.Msg) {
-		// poison message handling
-		if options.GetRetryLimit() > -1 && m.Redelivered && int(m.RedeliveryCount) > options.GetRetryLimit() {
-			log.Logf(logger.ErrorLevel, "Message retry limit reached, discarding: %v", m.Sequence)
-			m.Ack() // ignoring error
-			return
-		}
+		limit := options.GetRetryLimit()
+		exceeded := limit > -1 && m.Redelivered && int(m.RedeliveryCount) > limit
 
 		// decode the message
 		var evt events.Event
 		if err := json.Unmarshal(m.Data, &evt); err != nil {
 			log.Logf(logger.ErrorLevel, "Error decoding message: %v", err)
+			evt = events.Event{
+				ID:        uuid.New().String(),
+				Topic:     topic,
+				Timestamp: time.Now(),
+				Payload:   m.Data,
+			}
+			if s.deadLetter(m, evt, int(m.RedeliveryCount)+1, err) {
+				return
+			}
+			if exceeded {
+				log.Logf(logger.ErrorLevel, "Message retry limit reached, discarding: %v", m.Sequence)
+				m.Ack() // ignoring error
+			}
 			// not acknowledging the message is the way to indicate an error occurred
 			return
 		}
 
+		// poison message handling
+		if exceeded {
+			if s.deadLetter(m, evt, int(m.RedeliveryCount), deadletter.RetryLimitError(limit)) {
+				return
+			}
+			log.Logf(logger.ErrorLevel, "Message retry limit reached, discarding: %v", m.Sequence)
+			m.Ack() // ignoring error
+			return
+		}
+
 		if !options.AutoAck {
 			// set up the ack funcs
 			evt.SetAckFunc(func() error {
@@ -198,7 +260,10 @@ func (s *stream) Consume(topic string, opts ...events.ConsumeOption) (<-chan eve
 		}
 
 		// push onto the channel and wait for the consumer to take the event off before we acknowledge it.
-		c <- evt
+		if !c.deliver(evt) {
+			// stopped, the message is redelivered after the ack wait
+			return
+		}
 
 		if !options.AutoAck {
 			return
@@ -220,11 +285,114 @@ func (s *stream) Consume(topic string, opts ...events.ConsumeOption) (<-chan eve
 		subOpts = append(subOpts, stan.AckWait(options.AckWait))
 	}
 
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+
 	// connect the subscriber
-	_, err := s.conn.QueueSubscribe(topic, options.Group, handleMsg, subOpts...)
+	sub, err := s.conn.QueueSubscribe(topic, options.Group, handleMsg, subOpts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error subscribing to topic")
 	}
 
-	return c, nil
+	s.consumers.Add(1)
+	go func() {
+		defer s.consumers.Done()
+
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+		}
+
+		// closing keeps the durable subscription of the group for the next
+		// consumer, unsubscribing removes it
+		var err error
+		if ephemeral {
+			err = sub.Unsubscribe()
+		} else {
+			err = sub.Close()
+		}
+		if err != nil {
+			log.Logf(logger.ErrorLevel, "Error unsubscribing from topic %s: %v", topic, err)
+		}
+		c.stop()
+	}()
+
+	return c.ch, nil
+}
+
+// Close stops all the consumers and closes the connection.
+func (s *stream) Close() error {
+	s.mtx.Lock()
+	if s.closed {
+		s.mtx.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	s.mtx.Unlock()
+
+	s.consumers.Wait()
+
+	s.connMux.Lock()
+	defer s.connMux.Unlock()
+
+	// the nats connection is ours, stan doesn't close it
+	nc := s.conn.NatsConn()
+	err := s.conn.Close()
+	if nc != nil {
+		nc.Close()
+	}
+	return err
+}
+
+// consumer delivers events to its channel until it's stopped.
+type consumer struct {
+	ch   chan events.Event
+	done chan struct{}
+	once sync.Once
+
+	// held by deliveries in flight
+	mtx     sync.RWMutex
+	stopped bool
+}
+
+func newConsumer() *consumer {
+	return &consumer{
+		ch:   make(chan events.Event),
+		done: make(chan struct{}),
+	}
+}
+
+// deliver pushes the event onto the channel, it returns false if the consumer
+// was stopped before the event was taken off.
+func (c *consumer) deliver(evt events.Event) bool {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	if c.stopped {
+		return false
+	}
+
+	select {
+	case c.ch <- evt:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// stop aborts the deliveries in flight and closes the channel.
+func (c *consumer) stop() {
+	c.once.Do(func() {
+		close(c.done)
+
+		c.mtx.Lock()
+		c.stopped = true
+		close(c.ch)
+		c.mtx.Unlock()
+	})
 }
@@ -0,0 +1,156 @@
+package nats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	stand "github.com/nats-io/nats-streaming-server/server"
+	stan "github.com/nats-io/stan.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-micro.dev/v4/events"
+
+	"github.com/go-micro/plugins/v4/events/deadletter"
+	"github.com/go-micro/plugins/v4/events/nats"
+)
+
+type contextStream interface {
+	events.Stream
+	nats.ContextConsumer
+	Close() error
+}
+
+// streamingServer starts an in memory NATS Streaming server.
+func streamingServer(t *testing.T) string {
+	opts := stand.GetDefaultOptions()
+	opts.ID = "micro"
+
+	nopts := stand.DefaultNatsServerOptions
+	nopts.Host = "127.0.0.1"
+	nopts.Port = -1
+
+	server, err := stand.RunServerWithOpts(opts, &nopts)
+	require.NoError(t, err)
+	t.Cleanup(server.Shutdown)
+
+	return server.ClientURL()
+}
+
+func newStream(t *testing.T, addr string, opts ...nats.Option) contextStream {
+	s, err := nats.NewStream(append([]nats.Option{nats.Address(addr)}, opts...)...)
+	require.NoError(t, err)
+
+	cs, ok := s.(contextStream)
+	require.True(t, ok, "stream doesn't consume with a context")
+	t.Cleanup(func() { cs.Close() })
+
+	return cs
+}
+
+func receive(t *testing.T, ch <-chan events.Event) events.Event {
+	select {
+	case ev, ok := <-ch:
+		require.True(t, ok, "channel closed")
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	return events.Event{}
+}
+
+func closed(t *testing.T, ch <-chan events.Event) {
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("channel not closed")
+		}
+	}
+}
+
+func TestConsumeContext(t *testing.T) {
+	s := newStream(t, streamingServer(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, err := s.ConsumeContext(ctx, "foo")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Publish("foo", map[string]string{"bar": "baz"}))
+
+	ev := receive(t, ch)
+	assert.Equal(t, "foo", ev.Topic)
+
+	var payload map[string]string
+	require.NoError(t, ev.Unmarshal(&payload))
+	assert.Equal(t, "baz", payload["bar"])
+
+	// cancelling stops the consumer and closes the channel
+	cancel()
+	closed(t, ch)
+}
+
+func TestClose(t *testing.T) {
+	s := newStream(t, streamingServer(t))
+
+	ch, err := s.Consume("foo")
+	require.NoError(t, err)
+
+	// closing stops the consumers
+	require.NoError(t, s.Close())
+	closed(t, ch)
+
+	_, err = s.Consume("foo")
+	assert.Equal(t, nats.ErrClosed, err)
+
+	// closing again is a noop
+	assert.NoError(t, s.Close())
+}
+
+func TestDeadLetter(t *testing.T) {
+	addr := streamingServer(t)
+	s := newStream(t, addr, nats.DeadLetter("-dlq"))
+
+	ch, err := s.Consume("orders", events.WithGroup("workers"), events.WithAutoAck(false, time.Second), events.WithRetryLimit(0))
+	require.NoError(t, err)
+	dlq, err := s.Consume("orders-dlq", events.WithGroup("workers"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Publish("orders", "failing", events.WithMetadata(map[string]string{"meta": "bar"})))
+
+	// the message is nacked once, the redelivery is dead lettered
+	ev := receive(t, ch)
+	require.NoError(t, ev.Nack())
+
+	ev = receive(t, dlq)
+	assert.Equal(t, "orders-dlq", ev.Topic)
+	assert.Equal(t, `"failing"`, string(ev.Payload))
+	assert.Equal(t, "bar", ev.Metadata["meta"])
+	assert.Equal(t, "orders", ev.Metadata[deadletter.MetadataTopic])
+	assert.Equal(t, "1", ev.Metadata[deadletter.MetadataAttempts])
+	assert.Equal(t, "retry limit of 0 exceeded", ev.Metadata[deadletter.MetadataError])
+	assert.NotEmpty(t, ev.Metadata[deadletter.MetadataTimestamp])
+
+	// redriven to the original topic
+	require.NoError(t, deadletter.Redrive(s, ev))
+	ev = receive(t, ch)
+	assert.Equal(t, "orders", ev.Topic)
+	assert.Equal(t, `"failing"`, string(ev.Payload))
+	assert.Equal(t, map[string]string{"meta": "bar"}, ev.Metadata)
+	require.NoError(t, ev.Ack())
+
+	// messages that can't be decoded are dead lettered too
+	conn, err := stan.Connect("micro", "raw", stan.NatsURL(addr))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, conn.Publish("orders", []byte("{")))
+
+	ev = receive(t, dlq)
+	assert.Equal(t, "{", string(ev.Payload))
+	assert.Equal(t, "orders", ev.Metadata[deadletter.MetadataTopic])
+	assert.NotEmpty(t, ev.Metadata[deadletter.MetadataError])
+}
@@ -13,6 +13,9 @@ type Options struct {
 	Address   string
 	TLSConfig *tls.Config
 	Logger    logger.Logger
+
+	// DeadLetterSuffix names the dead letter topic of a topic, see DeadLetter.
+	DeadLetterSuffix string
 }
 
 // Option is a function which configures options.
@@ -46,6 +49,15 @@ func TLSConfig(t *tls.Config) Option {
 	}
 }
 
+// DeadLetter routes the messages exceeding the retry limit of a consumer,
+// or that can't be decoded, to a dead letter topic instead of discarding
+// them. The dead letter topic is the topic consumed with the suffix.
+func DeadLetter(suffix string) Option {
+	return func(o *Options) {
+		o.DeadLetterSuffix = suffix
+	}
+}
+
 // Logger sets the underlyin logger
 func Logger(log logger.Logger) Option {
 	return func(o *Options) {
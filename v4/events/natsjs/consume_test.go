@@ -0,0 +1,212 @@
+package natsjs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-micro/plugins/v4/events/deadletter"
+	"github.com/go-micro/plugins/v4/events/natsjs"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-micro.dev/v4/events"
+)
+
+type contextStream interface {
+	events.Stream
+	natsjs.ContextConsumer
+	Close() error
+}
+
+func newContextStream(t *testing.T, addr string) contextStream {
+	s, err := natsjs.NewStream(natsjs.Address(addr))
+	require.NoError(t, err)
+
+	cs, ok := s.(contextStream)
+	require.True(t, ok, "stream doesn't consume with a context")
+	t.Cleanup(func() { cs.Close() })
+
+	return cs
+}
+
+func jetStream(t *testing.T, addr string) nats.JetStreamContext {
+	conn, err := nats.Connect(addr)
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+
+	js, err := conn.JetStream()
+	require.NoError(t, err)
+
+	return js
+}
+
+func receive(t *testing.T, ch <-chan events.Event) events.Event {
+	select {
+	case ev, ok := <-ch:
+		require.True(t, ok, "channel closed")
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	return events.Event{}
+}
+
+func closed(t *testing.T, ch <-chan events.Event) {
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok, "channel not closed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed")
+	}
+}
+
+func TestConsumeContext(t *testing.T) {
+	addr := jetStreamServer(t)
+	s := newContextStream(t, addr)
+	js := jetStream(t, addr)
+
+	t.Run("Ephemeral", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := s.ConsumeContext(ctx, "ephemeral")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Publish("ephemeral", "one"))
+		assert.Equal(t, "ephemeral", receive(t, ch).Topic)
+
+		cancel()
+		closed(t, ch)
+
+		// the generated group is deleted with the consumer
+		require.Eventually(t, func() bool {
+			return len(consumerNames(js, "ephemeral")) == 0
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("Group", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := s.ConsumeContext(ctx, "group", events.WithGroup("workers"))
+		require.NoError(t, err)
+
+		cancel()
+		closed(t, ch)
+
+		// the named group is kept for the next consumer
+		_, err = js.ConsumerInfo("group", "workers")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Publish("group", "kept"))
+
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		ch, err = s.ConsumeContext(ctx, "group", events.WithGroup("workers"))
+		require.NoError(t, err)
+		assert.Equal(t, `"kept"`, string(receive(t, ch).Payload))
+	})
+
+	t.Run("InFlight", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := s.ConsumeContext(ctx, "inflight", events.WithGroup("workers"), events.WithAutoAck(false, time.Minute))
+		require.NoError(t, err)
+
+		require.NoError(t, s.Publish("inflight", "released"))
+
+		// wait for the message to be in flight, but don't take it off
+		require.Eventually(t, func() bool {
+			info, err := js.ConsumerInfo("inflight", "workers")
+			return err == nil && info.NumAckPending == 1
+		}, 5*time.Second, 50*time.Millisecond)
+
+		// the message is nacked when the consumer stops and redelivered
+		// without waiting for the ack wait
+		cancel()
+
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		next, err := s.ConsumeContext(ctx, "inflight", events.WithGroup("workers"), events.WithAutoAck(false, time.Minute))
+		require.NoError(t, err)
+
+		ev := receive(t, next)
+		assert.Equal(t, `"released"`, string(ev.Payload))
+		assert.NoError(t, ev.Ack())
+
+		closed(t, ch)
+	})
+}
+
+func TestClose(t *testing.T) {
+	addr := jetStreamServer(t)
+	s := newContextStream(t, addr)
+
+	one, err := s.Consume("one")
+	require.NoError(t, err)
+	two, err := s.ConsumeContext(context.Background(), "two", events.WithGroup("workers"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Close())
+	closed(t, one)
+	closed(t, two)
+
+	_, err = s.Consume("one")
+	assert.Equal(t, natsjs.ErrClosed, err)
+
+	// closing again is a noop
+	assert.NoError(t, s.Close())
+}
+
+func consumerNames(js nats.JetStreamContext, stream string) []string {
+	var names []string
+	for name := range js.ConsumerNames(stream) {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestDeadLetter(t *testing.T) {
+	addr := jetStreamServer(t)
+
+	st, err := natsjs.NewStream(natsjs.Address(addr), natsjs.DeadLetter("-dlq"))
+	require.NoError(t, err)
+	s := st.(contextStream)
+	t.Cleanup(func() { s.Close() })
+
+	ch, err := s.Consume("orders", events.WithGroup("workers"), events.WithAutoAck(false, time.Minute), events.WithRetryLimit(1))
+	require.NoError(t, err)
+	dlq, err := s.Consume("orders-dlq", events.WithGroup("workers"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Publish("orders", "failing", events.WithMetadata(map[string]string{"meta": "bar"})))
+
+	// the message is nacked once, the redelivery is dead lettered
+	ev := receive(t, ch)
+	require.NoError(t, ev.Nack())
+
+	ev = receive(t, dlq)
+	assert.Equal(t, "orders-dlq", ev.Topic)
+	assert.Equal(t, `"failing"`, string(ev.Payload))
+	assert.Equal(t, "bar", ev.Metadata["meta"])
+	assert.Equal(t, "orders", ev.Metadata[deadletter.MetadataTopic])
+	assert.Equal(t, "1", ev.Metadata[deadletter.MetadataAttempts])
+	assert.Equal(t, "retry limit of 1 exceeded", ev.Metadata[deadletter.MetadataError])
+	assert.NotEmpty(t, ev.Metadata[deadletter.MetadataTimestamp])
+
+	// redriven to the original topic
+	require.NoError(t, deadletter.Redrive(s, ev))
+	ev = receive(t, ch)
+	assert.Equal(t, "orders", ev.Topic)
+	assert.Equal(t, `"failing"`, string(ev.Payload))
+	assert.Equal(t, map[string]string{"meta": "bar"}, ev.Metadata)
+	require.NoError(t, ev.Ack())
+
+	assert.Equal(t, deadletter.ErrNotDeadLetter, deadletter.Redrive(s, ev))
+
+	// messages that can't be decoded are dead lettered too
+	js := jetStream(t, addr)
+	_, err = js.Publish("orders", []byte("{"))
+	require.NoError(t, err)
+
+	ev = receive(t, dlq)
+	assert.Equal(t, "{", string(ev.Payload))
+	assert.Equal(t, "orders", ev.Metadata[deadletter.MetadataTopic])
+	assert.NotEmpty(t, ev.Metadata[deadletter.MetadataError])
+}
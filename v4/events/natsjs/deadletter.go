@@ -0,0 +1,39 @@
+package natsjs
+
+import (
+	nats "github.com/nats-io/nats.go"
+
+	"go-micro.dev/v4/events"
+	"go-micro.dev/v4/logger"
+
+	"github.com/go-micro/plugins/v4/events/deadletter"
+)
+
+// deadLetter writes the event to the dead letter topic of its topic and
+// acknowledges the message, it returns false without one or on failure.
+func (s *stream) deadLetter(m *nats.Msg, ev events.Event, attempts int, reason error) bool {
+	if len(s.opts.DeadLetterSuffix) == 0 {
+		return false
+	}
+
+	err := s.Write(deadletter.New(ev, s.opts.DeadLetterSuffix, attempts, reason))
+	if err != nil {
+		s.opts.Logger.Logf(logger.ErrorLevel, "Error dead lettering message: %v", err)
+		return false
+	}
+
+	if err := m.Ack(); err != nil {
+		s.opts.Logger.Logf(logger.ErrorLevel, "Error acknowledging dead letter: %v", err)
+	}
+
+	return true
+}
+
+// deliveries returns the number of times the message was delivered.
+func deliveries(m *nats.Msg) int {
+	meta, err := m.Metadata()
+	if err != nil {
+		return 1
+	}
+	return int(meta.NumDelivered)
+}
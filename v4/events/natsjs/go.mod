@@ -3,6 +3,7 @@ module github.com/go-micro/plugins/v4/events/natsjs
 go 1.17
 
 require (
+	github.com/go-micro/plugins/v4/events/deadletter v1.0.0
 	github.com/google/uuid v1.3.0
 	github.com/nats-io/nats-server/v2 v2.7.4
 	github.com/nats-io/nats.go v1.16.0
@@ -38,3 +39,5 @@ require (
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
+
+replace github.com/go-micro/plugins/v4/events/deadletter => ../deadletter
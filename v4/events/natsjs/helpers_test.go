@@ -6,9 +6,11 @@ import (
 	"net"
 	"path/filepath"
 	"testing"
+	"time"
 
 	nserver "github.com/nats-io/nats-server/v2/server"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func getFreeLocalhostAddress() string {
@@ -50,6 +52,24 @@ func natsServer(ctx context.Context, t *testing.T, opts *nserver.Options) {
 	server.Shutdown()
 }
 
+// jetStreamServer starts a standalone NATS server with JetStream enabled.
+func jetStreamServer(t *testing.T) string {
+	server, err := nserver.NewServer(&nserver.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	go server.Start()
+	t.Cleanup(server.Shutdown)
+
+	require.True(t, server.ReadyForConnections(5*time.Second), "NATS server not ready")
+
+	return server.ClientURL()
+}
+
 func NewLogWrapper() *LogWrapper {
 	return &LogWrapper{}
 }
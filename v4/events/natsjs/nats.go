@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,12 +14,19 @@ import (
 
 	"go-micro.dev/v4/events"
 	"go-micro.dev/v4/logger"
+
+	"github.com/go-micro/plugins/v4/events/deadletter"
 )
 
 const (
 	defaultClusterID = "micro"
 )
 
+var (
+	// ErrClosed is returned when consuming from a closed stream.
+	ErrClosed = errors.New("stream closed")
+)
+
 // NewStream returns an initialized nats stream or an error if the connection to the nats
 // server could not be established.
 func NewStream(opts ...Option) (events.Stream, error) {
@@ -32,21 +40,43 @@ func NewStream(opts ...Option) (events.Stream, error) {
 		o(&options)
 	}
 
-	s := &stream{opts: options}
-	natsJetStreamCtx, err := connectToNatsJetStream(options)
+	s := &stream{opts: options, done: make(chan struct{}), streams: make(map[string]bool)}
+	conn, natsJetStreamCtx, err := connectToNatsJetStream(options)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to nats cluster %v: %v", options.ClusterID, err)
 	}
+	s.conn = conn
 	s.natsJetStreamCtx = natsJetStreamCtx
 	return s, nil
 }
 
+// ContextConsumer is implemented by the streams of NewStream, consuming from
+// a topic until the context is done:
+//
+//	ch, err := stream.(natsjs.ContextConsumer).ConsumeContext(ctx, "topic")
+type ContextConsumer interface {
+	ConsumeContext(ctx context.Context, topic string, opts ...events.ConsumeOption) (<-chan events.Event, error)
+}
+
+var _ ContextConsumer = (*stream)(nil)
+
 type stream struct {
 	opts             Options
+	conn             *nats.Conn
 	natsJetStreamCtx nats.JetStreamContext
+
+	// consumers in flight, stopped on close
+	mtx       sync.Mutex
+	closed    bool
+	done      chan struct{}
+	consumers sync.WaitGroup
+
+	// topics whose stream was ensured
+	streamsMtx sync.Mutex
+	streams    map[string]bool
 }
 
-func connectToNatsJetStream(options Options) (nats.JetStreamContext, error) {
+func connectToNatsJetStream(options Options) (*nats.Conn, nats.JetStreamContext, error) {
 	nopts := nats.GetDefaultOptions()
 	if options.TLSConfig != nil {
 		nopts.Secure = true
@@ -58,15 +88,16 @@ func connectToNatsJetStream(options Options) (nats.JetStreamContext, error) {
 
 	conn, err := nopts.Connect()
 	if err != nil {
-		return nil, fmt.Errorf("error connecting to nats at %v with tls enabled (%v): %v", options.Address, nopts.TLSConfig != nil, err)
+		return nil, nil, fmt.Errorf("error connecting to nats at %v with tls enabled (%v): %v", options.Address, nopts.TLSConfig != nil, err)
 	}
 
 	js, err := conn.JetStream()
 	if err != nil {
-		return nil, fmt.Errorf("error while obtaining JetStream context: %v", err)
+		conn.Close()
+		return nil, nil, fmt.Errorf("error while obtaining JetStream context: %v", err)
 	}
 
-	return js, nil
+	return conn, js, nil
 }
 
 // Publish a message to a topic.
@@ -111,6 +142,10 @@ func (s *stream) Publish(topic string, msg interface{}, opts ...events.PublishOp
 		return errors.Wrap(err, "Error encoding event")
 	}
 
+	if err := s.ensureStream(event.Topic); err != nil {
+		return err
+	}
+
 	// publish the event to the topic's channel
 	if _, err := s.natsJetStreamCtx.PublishAsync(event.Topic, bytes); err != nil {
 		return errors.Wrap(err, "Error publishing message to topic")
@@ -121,6 +156,14 @@ func (s *stream) Publish(topic string, msg interface{}, opts ...events.PublishOp
 
 // Consume from a topic.
 func (s *stream) Consume(topic string, opts ...events.ConsumeOption) (<-chan events.Event, error) {
+	return s.ConsumeContext(context.Background(), topic, opts...)
+}
+
+// ConsumeContext consumes from a topic until the context is done or the
+// stream is closed. The channel is closed once the consumer stopped, the
+// messages in flight are nacked and the consumer of a group generated for the
+// call is deleted, named groups are kept.
+func (s *stream) ConsumeContext(ctx context.Context, topic string, opts ...events.ConsumeOption) (<-chan events.Event, error) {
 	// validate the topic
 	if len(topic) == 0 {
 		return nil, events.ErrMissingTopic
@@ -129,15 +172,19 @@ func (s *stream) Consume(topic string, opts ...events.ConsumeOption) (<-chan eve
 	log := s.opts.Logger
 
 	// parse the options
-	options := events.ConsumeOptions{
-		Group: uuid.New().String(),
-	}
+	options := events.ConsumeOptions{}
 	for _, o := range opts {
 		o(&options)
 	}
 
+	// a consumer of a generated group isn't shared and goes with the call
+	ephemeral := len(options.Group) == 0
+	if ephemeral {
+		options.Group = uuid.New().String()
+	}
+
 	// setup the subscriber
-	c := make(chan events.Event)
+	c := newConsumer()
 	handleMsg := func(m *nats.Msg) {
 		ctx, cancel := context.WithCancel(context.TODO())
 		defer cancel()
@@ -146,10 +193,26 @@ func (s *stream) Consume(topic string, opts ...events.ConsumeOption) (<-chan eve
 		var evt events.Event
 		if err := json.Unmarshal(m.Data, &evt); err != nil {
 			log.Logf(logger.ErrorLevel, "Error decoding message: %v", err)
-			// not acknowledging the message is the way to indicate an error occurred
+			evt = events.Event{
+				ID:        uuid.New().String(),
+				Topic:     topic,
+				Timestamp: time.Now(),
+				Payload:   m.Data,
+			}
+			// not acknowledging the message is the way to indicate an error occurred,
+			// unless it's dead lettered
+			s.deadLetter(m, evt, deliveries(m), err)
 			return
 		}
 
+		// the delivery after the last retry is dead lettered
+		if options.CustomRetries && len(s.opts.DeadLetterSuffix) > 0 {
+			limit := options.GetRetryLimit()
+			if attempts := deliveries(m) - 1; attempts >= limit && s.deadLetter(m, evt, attempts, deadletter.RetryLimitError(limit)) {
+				return
+			}
+		}
+
 		if !options.AutoAck {
 			// set up the ack funcs
 			evt.SetAckFunc(func() error {
@@ -161,7 +224,13 @@ func (s *stream) Consume(topic string, opts ...events.ConsumeOption) (<-chan eve
 		}
 
 		// push onto the channel and wait for the consumer to take the event off before we acknowledge it.
-		c <- evt
+		if !c.deliver(evt) {
+			// stopped, release the message for redelivery
+			if err := m.Nak(nats.Context(ctx)); err != nil {
+				log.Logf(logger.ErrorLevel, "Error nacking message: %v", err)
+			}
+			return
+		}
 
 		if !options.AutoAck {
 			return
@@ -172,49 +241,244 @@ func (s *stream) Consume(topic string, opts ...events.ConsumeOption) (<-chan eve
 
 	}
 
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+
 	// ensure that a stream exists for that topic
-	_, err := s.natsJetStreamCtx.StreamInfo(topic)
+	if err := s.ensureStream(topic); err != nil {
+		return nil, err
+	}
+
+	// the consumer is created here rather than by the subscription, which
+	// would delete it on unsubscribe even if other members of the group use it
+	if err := s.ensureConsumer(topic, options); err != nil {
+		return nil, err
+	}
+
+	// connect the subscriber
+	sub, err := s.natsJetStreamCtx.QueueSubscribe(topic, options.Group, handleMsg, nats.Bind(topic, options.Group), nats.ManualAck())
 	if err != nil {
-		cfg := &nats.StreamConfig{
-			Name: topic,
+		if ephemeral {
+			s.deleteConsumer(topic, options.Group)
+		}
+		return nil, errors.Wrap(err, "Error subscribing to topic")
+	}
+
+	s.consumers.Add(1)
+	go func() {
+		defer s.consumers.Done()
+
+		select {
+		case <-ctx.Done():
+		case <-s.done:
 		}
 
-		_, err = s.natsJetStreamCtx.AddStream(cfg)
-		if err != nil {
-			return nil, errors.Wrap(err, "Stream did not exist and adding a stream failed")
+		// stop the delivery before releasing the messages in flight
+		if err := sub.Unsubscribe(); err != nil && err != nats.ErrConnectionClosed {
+			log.Logf(logger.ErrorLevel, "Error unsubscribing from topic %s: %v", topic, err)
+		}
+		c.stop()
+
+		if ephemeral {
+			s.deleteConsumer(topic, options.Group)
 		}
+	}()
+
+	return c.ch, nil
+}
+
+// Close stops all the consumers and drains the connection, publishes in
+// flight are sent first.
+func (s *stream) Close() error {
+	s.mtx.Lock()
+	if s.closed {
+		s.mtx.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	s.mtx.Unlock()
+
+	s.consumers.Wait()
+
+	return s.conn.Drain()
+}
+
+// ensureConsumer adds the durable consumer of the group if it doesn't exist.
+func (s *stream) ensureConsumer(topic string, options events.ConsumeOptions) error {
+	_, err := s.natsJetStreamCtx.ConsumerInfo(topic, options.Group)
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return errors.Wrap(err, "Error getting consumer info")
 	}
 
-	// setup the options
-	subOpts := []nats.SubOpt{
-		nats.Durable(options.Group),
+	cfg := &nats.ConsumerConfig{
+		Durable:        options.Group,
+		DeliverSubject: nats.NewInbox(),
+		DeliverGroup:   options.Group,
+		FilterSubject:  topic,
+		DeliverPolicy:  nats.DeliverNewPolicy,
+		AckPolicy:      nats.AckExplicitPolicy,
+		AckWait:        options.AckWait,
 	}
 
 	if options.CustomRetries {
-		subOpts = append(subOpts, nats.MaxDeliver(options.GetRetryLimit()))
+		cfg.MaxDeliver = options.GetRetryLimit()
+
+		// one more delivery to dead letter the message
+		if len(s.opts.DeadLetterSuffix) > 0 {
+			cfg.MaxDeliver++
+		}
 	}
 
 	if options.AutoAck {
-		subOpts = append(subOpts, nats.AckNone())
-	} else {
-		subOpts = append(subOpts, nats.AckExplicit())
+		cfg.AckPolicy = nats.AckNonePolicy
 	}
 
 	if !options.Offset.IsZero() {
-		subOpts = append(subOpts, nats.StartTime(options.Offset))
-	} else {
-		subOpts = append(subOpts, nats.DeliverNew())
+		offset := options.Offset
+		cfg.DeliverPolicy = nats.DeliverByStartTimePolicy
+		cfg.OptStartTime = &offset
 	}
 
-	if options.AckWait > 0 {
-		subOpts = append(subOpts, nats.AckWait(options.AckWait))
+	if _, err := s.natsJetStreamCtx.AddConsumer(topic, cfg); err != nil {
+		// another member of the group may have added it in the meantime
+		if _, ierr := s.natsJetStreamCtx.ConsumerInfo(topic, options.Group); ierr == nil {
+			return nil
+		}
+		return errors.Wrap(err, "Error adding consumer")
 	}
 
-	// connect the subscriber
-	_, err = s.natsJetStreamCtx.QueueSubscribe(topic, options.Group, handleMsg, subOpts...)
+	return nil
+}
+
+func (s *stream) deleteConsumer(topic, group string) {
+	err := s.natsJetStreamCtx.DeleteConsumer(topic, group)
+	if err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+		s.opts.Logger.Logf(logger.ErrorLevel, "Error deleting consumer %s of %s: %v", group, topic, err)
+	}
+}
+
+// consumer delivers events to its channel until it's stopped.
+type consumer struct {
+	ch   chan events.Event
+	done chan struct{}
+	once sync.Once
+
+	// held by deliveries in flight
+	mtx     sync.RWMutex
+	stopped bool
+}
+
+func newConsumer() *consumer {
+	return &consumer{
+		ch:   make(chan events.Event),
+		done: make(chan struct{}),
+	}
+}
+
+// deliver pushes the event onto the channel, it returns false if the consumer
+// was stopped before the event was taken off.
+func (c *consumer) deliver(evt events.Event) bool {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	if c.stopped {
+		return false
+	}
+
+	select {
+	case c.ch <- evt:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// stop aborts the deliveries in flight and closes the channel.
+func (c *consumer) stop() {
+	c.once.Do(func() {
+		close(c.done)
+
+		c.mtx.Lock()
+		c.stopped = true
+		close(c.ch)
+		c.mtx.Unlock()
+	})
+}
+
+// ensureStream adds the stream of the topic if it doesn't exist, or updates
+// the limits of the existing stream to the options with UpdateLimits. It's
+// done once per topic.
+func (s *stream) ensureStream(topic string) error {
+	s.streamsMtx.Lock()
+	defer s.streamsMtx.Unlock()
+
+	if s.streams[topic] {
+		return nil
+	}
+
+	info, err := s.natsJetStreamCtx.StreamInfo(topic)
 	if err != nil {
-		return nil, errors.Wrap(err, "Error subscribing to topic")
+		cfg := &nats.StreamConfig{
+			Name:     topic,
+			Storage:  s.opts.Storage,
+			Replicas: s.opts.Replicas,
+		}
+		s.setLimits(cfg)
+
+		_, err = s.natsJetStreamCtx.AddStream(cfg)
+		if err != nil {
+			return errors.Wrap(err, "Stream did not exist and adding a stream failed")
+		}
+
+		s.streams[topic] = true
+		return nil
+	}
+
+	// the limits set on the stream otherwise are kept
+	cfg := info.Config
+	s.setLimits(&cfg)
+	if s.opts.Replicas > 0 {
+		cfg.Replicas = s.opts.Replicas
+	}
+
+	if cfg.MaxAge != info.Config.MaxAge || cfg.MaxMsgs != info.Config.MaxMsgs ||
+		cfg.MaxBytes != info.Config.MaxBytes || cfg.Discard != info.Config.Discard ||
+		cfg.Replicas != info.Config.Replicas {
+		if !s.opts.UpdateLimits {
+			s.opts.Logger.Logf(logger.WarnLevel, "The limits of stream %s differ from the options, they're kept", topic)
+		} else {
+			s.opts.Logger.Logf(logger.InfoLevel, "Updating the limits of stream %s", topic)
+			if _, err := s.natsJetStreamCtx.UpdateStream(&cfg); err != nil {
+				return errors.Wrap(err, "Updating the stream limits failed")
+			}
+		}
+	}
+
+	s.streams[topic] = true
+	return nil
+}
+
+// setLimits sets the limits of the options on the stream config.
+func (s *stream) setLimits(cfg *nats.StreamConfig) {
+	if s.opts.MaxAge == 0 && s.opts.MaxMsgs == 0 && s.opts.MaxBytes == 0 {
+		return
 	}
 
-	return c, nil
+	if s.opts.MaxAge > 0 {
+		cfg.MaxAge = s.opts.MaxAge
+	}
+	if s.opts.MaxMsgs > 0 {
+		cfg.MaxMsgs = s.opts.MaxMsgs
+	}
+	if s.opts.MaxBytes > 0 {
+		cfg.MaxBytes = s.opts.MaxBytes
+	}
+	cfg.Discard = s.opts.Discard
 }
@@ -2,6 +2,9 @@ package natsjs
 
 import (
 	"crypto/tls"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
 
 	"go-micro.dev/v4/logger"
 )
@@ -13,6 +16,21 @@ type Options struct {
 	Address   string
 	TLSConfig *tls.Config
 	Logger    logger.Logger
+
+	// DeadLetterSuffix names the dead letter topic of a topic, see DeadLetter.
+	DeadLetterSuffix string
+
+	// Retention and storage of the topic streams, zero values are the
+	// server defaults.
+	MaxAge   time.Duration
+	MaxMsgs  int64
+	MaxBytes int64
+	Storage  nats.StorageType
+	Replicas int
+	Discard  nats.DiscardPolicy
+
+	// UpdateLimits of the existing topic streams, see UpdateLimits.
+	UpdateLimits bool
 }
 
 // Option is a function which configures options.
@@ -46,6 +64,70 @@ func TLSConfig(t *tls.Config) Option {
 	}
 }
 
+// DeadLetter routes the messages exceeding the retry limit of a consumer,
+// or that can't be decoded, to a dead letter topic instead of discarding
+// them. The dead letter topic is the topic consumed with the suffix.
+func DeadLetter(suffix string) Option {
+	return func(o *Options) {
+		o.DeadLetterSuffix = suffix
+	}
+}
+
+// MaxAge of the messages of the topic streams. The limits are set when a
+// stream is added, see UpdateLimits for the existing streams.
+func MaxAge(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxAge = d
+	}
+}
+
+// MaxMsgs is the number of messages kept in a topic stream.
+func MaxMsgs(n int64) Option {
+	return func(o *Options) {
+		o.MaxMsgs = n
+	}
+}
+
+// MaxBytes is the size of the messages kept in a topic stream.
+func MaxBytes(n int64) Option {
+	return func(o *Options) {
+		o.MaxBytes = n
+	}
+}
+
+// Storage of the topic streams, file storage by default. It's set when a
+// stream is added only.
+func Storage(t nats.StorageType) Option {
+	return func(o *Options) {
+		o.Storage = t
+	}
+}
+
+// Replicas of the topic streams in a cluster.
+func Replicas(n int) Option {
+	return func(o *Options) {
+		o.Replicas = n
+	}
+}
+
+// Discard policy of the topic streams when a limit is reached, the oldest
+// messages are discarded by default.
+func Discard(p nats.DiscardPolicy) Option {
+	return func(o *Options) {
+		o.Discard = p
+	}
+}
+
+// UpdateLimits updates the limits and the replicas of the existing topic
+// streams to the options when a topic is first used, they're only set when a
+// stream is added otherwise. The instances updating the limits of a topic
+// have to be configured the same, or they overwrite each other's limits.
+func UpdateLimits() Option {
+	return func(o *Options) {
+		o.UpdateLimits = true
+	}
+}
+
 // Logger sets the underlyin logger
 func Logger(log logger.Logger) Option {
 	return func(o *Options) {
@@ -0,0 +1,125 @@
+package natsjs
+
+import (
+	"encoding/json"
+
+	nats "github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+
+	"go-micro.dev/v4/events"
+	"go-micro.dev/v4/logger"
+)
+
+const (
+	// defaultReadLimit is the number of events read when no limit is set.
+	defaultReadLimit = 250
+)
+
+// NewStore returns an events store reading the history of the topic streams,
+// the same streams events are published to and consumed from.
+func NewStore(opts ...Option) (events.Store, error) {
+	s, err := NewStream(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.(*stream), nil
+}
+
+// Read the events of a topic, oldest first. A zero limit reads all of them.
+func (s *stream) Read(topic string, opts ...events.ReadOption) ([]*events.Event, error) {
+	// validate the topic
+	if len(topic) == 0 {
+		return nil, events.ErrMissingTopic
+	}
+
+	// parse the options
+	options := events.ReadOptions{
+		Limit: defaultReadLimit,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	result := []*events.Event{}
+
+	info, err := s.natsJetStreamCtx.StreamInfo(topic)
+	if err == nats.ErrStreamNotFound {
+		return result, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Error getting stream info")
+	}
+
+	state := info.State
+	if state.Msgs == 0 {
+		return result, nil
+	}
+
+	seq, skipped := state.FirstSeq, uint(0)
+
+	// without deleted messages the sequence of the offset is known
+	if state.LastSeq-state.FirstSeq+1 == state.Msgs {
+		seq += uint64(options.Offset)
+		skipped = options.Offset
+	}
+
+	for ; seq <= state.LastSeq; seq++ {
+		if options.Limit > 0 && uint(len(result)) >= options.Limit {
+			break
+		}
+
+		msg, err := s.natsJetStreamCtx.GetMsg(topic, seq)
+		if err == nats.ErrMsgNotFound {
+			// deleted
+			continue
+		} else if err != nil {
+			return nil, errors.Wrap(err, "Error reading message from stream")
+		}
+
+		if skipped < options.Offset {
+			skipped++
+			continue
+		}
+
+		var evt events.Event
+		if err := json.Unmarshal(msg.Data, &evt); err != nil {
+			s.opts.Logger.Logf(logger.ErrorLevel, "Error decoding message %d of %s: %v", seq, topic, err)
+			continue
+		}
+
+		result = append(result, &evt)
+	}
+
+	return result, nil
+}
+
+// Write an event to the stream of its topic, it's delivered to the consumers
+// of the topic.
+func (s *stream) Write(event *events.Event, opts ...events.WriteOption) error {
+	// validate the topic
+	if len(event.Topic) == 0 {
+		return events.ErrMissingTopic
+	}
+
+	// parse the options, the retention is configured on the stream
+	var options events.WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if err := s.ensureStream(event.Topic); err != nil {
+		return err
+	}
+
+	// serialize the event to bytes
+	bytes, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "Error encoding event")
+	}
+
+	if _, err := s.natsJetStreamCtx.Publish(event.Topic, bytes); err != nil {
+		return errors.Wrap(err, "Error writing event to stream")
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package natsjs_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-micro/plugins/v4/events/natsjs"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-micro.dev/v4/events"
+)
+
+func readLimit(l uint) events.ReadOption {
+	return func(o *events.ReadOptions) {
+		o.Limit = l
+	}
+}
+
+func readOffset(l uint) events.ReadOption {
+	return func(o *events.ReadOptions) {
+		o.Offset = l
+	}
+}
+
+func TestStore(t *testing.T) {
+	addr := jetStreamServer(t)
+
+	store, err := natsjs.NewStore(natsjs.Address(addr))
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		err := store.Write(&events.Event{
+			ID:        fmt.Sprintf("%d", i),
+			Topic:     "audit",
+			Timestamp: time.Now(),
+			Metadata:  map[string]string{"index": fmt.Sprintf("%d", i)},
+			Payload:   []byte(`{"name":"test"}`),
+		})
+		require.NoError(t, err)
+	}
+
+	// events published to an existing stream are read too
+	require.NoError(t, store.Write(&events.Event{ID: "written", Topic: "other"}))
+	stream, err := natsjs.NewStream(natsjs.Address(addr))
+	require.NoError(t, err)
+	require.NoError(t, stream.Publish("other", map[string]string{"name": "other"}))
+
+	evs, err := store.Read("audit")
+	require.NoError(t, err)
+	require.Len(t, evs, 5)
+	for i, ev := range evs {
+		assert.Equal(t, fmt.Sprintf("%d", i), ev.ID)
+		assert.Equal(t, "audit", ev.Topic)
+		assert.Equal(t, fmt.Sprintf("%d", i), ev.Metadata["index"])
+	}
+
+	evs, err = store.Read("audit", readOffset(1), readLimit(2))
+	require.NoError(t, err)
+	require.Len(t, evs, 2)
+	assert.Equal(t, "1", evs[0].ID)
+	assert.Equal(t, "2", evs[1].ID)
+
+	evs, err = store.Read("audit", readOffset(10))
+	require.NoError(t, err)
+	assert.Len(t, evs, 0)
+
+	require.Eventually(t, func() bool {
+		evs, err := store.Read("other")
+		return err == nil && len(evs) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+
+	evs, err = store.Read("missing")
+	require.NoError(t, err)
+	assert.Len(t, evs, 0)
+
+	_, err = store.Read("")
+	assert.Equal(t, events.ErrMissingTopic, err)
+}
+
+func TestRetention(t *testing.T) {
+	addr := jetStreamServer(t)
+	js := jetStream(t, addr)
+
+	store, err := natsjs.NewStore(
+		natsjs.Address(addr),
+		natsjs.MaxMsgs(5),
+		natsjs.MaxAge(time.Hour),
+		natsjs.Storage(nats.MemoryStorage),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, store.Write(&events.Event{ID: fmt.Sprintf("%d", i), Topic: "retained"}))
+	}
+
+	evs, err := store.Read("retained")
+	require.NoError(t, err)
+	require.Len(t, evs, 5)
+	assert.Equal(t, "5", evs[0].ID)
+
+	info, err := js.StreamInfo("retained")
+	require.NoError(t, err)
+	assert.Equal(t, nats.MemoryStorage, info.Config.Storage)
+	assert.Equal(t, time.Hour, info.Config.MaxAge)
+	assert.Equal(t, nats.DiscardOld, info.Config.Discard)
+
+	// the limits of existing streams are kept by default
+	store, err = natsjs.NewStore(natsjs.Address(addr), natsjs.MaxMsgs(3))
+	require.NoError(t, err)
+	require.NoError(t, store.Write(&events.Event{ID: "10", Topic: "retained"}))
+
+	info, err = js.StreamInfo("retained")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), info.Config.MaxMsgs)
+
+	// the limits of existing streams are updated, the full stream rejects
+	// new messages
+	store, err = natsjs.NewStore(natsjs.Address(addr), natsjs.MaxMsgs(3), natsjs.Discard(nats.DiscardNew), natsjs.UpdateLimits())
+	require.NoError(t, err)
+	assert.Error(t, store.Write(&events.Event{ID: "11", Topic: "retained"}))
+
+	info, err = js.StreamInfo("retained")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), info.Config.MaxMsgs)
+	assert.Equal(t, time.Hour, info.Config.MaxAge)
+	assert.Equal(t, nats.DiscardNew, info.Config.Discard)
+	assert.Equal(t, uint64(3), info.State.Msgs)
+}
+
+func TestPublishRetention(t *testing.T) {
+	addr := jetStreamServer(t)
+	js := jetStream(t, addr)
+
+	// a publishing only stream adds the stream with its limits
+	s, err := natsjs.NewStream(natsjs.Address(addr), natsjs.MaxMsgs(2), natsjs.Storage(nats.MemoryStorage))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.(contextStream).Close() })
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Publish("published", i))
+	}
+
+	require.Eventually(t, func() bool {
+		info, err := js.StreamInfo("published")
+		return err == nil && info.State.Msgs == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	info, err := js.StreamInfo("published")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), info.Config.MaxMsgs)
+	assert.Equal(t, nats.MemoryStorage, info.Config.Storage)
+}
@@ -0,0 +1,271 @@
+package stream
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-micro.dev/v4/events"
+
+	"github.com/go-micro/plugins/v4/events/deadletter"
+)
+
+func newTestStream(t *testing.T, opts ...Option) (*redisStream, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+
+	timeout := readGroupTimeout
+	readGroupTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { readGroupTimeout = timeout })
+
+	s, err := NewStream(append([]Option{Address(mr.Addr())}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.(*redisStream).Close() })
+
+	return s.(*redisStream), mr
+}
+
+// receive skips the empty events sent while the stream is idle.
+func receive(t *testing.T, ch <-chan events.Event) events.Event {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			require.True(t, ok, "channel closed")
+			if len(ev.ID) == 0 {
+				continue
+			}
+			return ev
+		case <-timeout:
+			t.Fatal("no event received")
+		}
+	}
+}
+
+func closed(t *testing.T, ch <-chan events.Event) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			assert.Empty(t, ev.ID, "unexpected event")
+		case <-timeout:
+			t.Fatal("channel not closed")
+		}
+	}
+}
+
+func TestConsumeContext(t *testing.T) {
+	s, _ := newTestStream(t)
+
+	t.Run("Ephemeral", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := s.ConsumeContext(ctx, "ephemeral")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Publish("ephemeral", testObj{One: "one"}))
+		assert.Equal(t, "ephemeral", receive(t, ch).Topic)
+
+		cancel()
+		closed(t, ch)
+
+		// the generated group is destroyed with the consumer
+		groups, err := s.redisClient.XInfoGroups(context.Background(), "stream-ephemeral").Result()
+		require.NoError(t, err)
+		assert.Len(t, groups, 0)
+	})
+
+	t.Run("InFlight", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := s.ConsumeContext(ctx, "inflight", events.WithGroup("workers"), events.WithAutoAck(false, time.Minute))
+		require.NoError(t, err)
+
+		require.NoError(t, s.Publish("inflight", testObj{One: "released"}))
+
+		// wait for the message to be in flight, but don't take it off
+		require.Eventually(t, func() bool {
+			pending, err := s.redisClient.XPending(context.Background(), "stream-inflight", "workers").Result()
+			return err == nil && pending.Count == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		cancel()
+		closed(t, ch)
+
+		// the named group is kept and the message left pending, released
+		// for its consumers to claim
+		pending, err := s.redisClient.XPending(context.Background(), "stream-inflight", "workers").Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), pending.Count)
+		assert.Len(t, pending.Consumers, 1)
+		for name := range pending.Consumers {
+			assert.True(t, strings.HasSuffix(name, releasedSuffix), "expected a released consumer, got %s", name)
+		}
+
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+		ch, err = s.ConsumeContext(ctx, "inflight", events.WithGroup("workers"), events.WithAutoAck(false, time.Minute))
+		require.NoError(t, err)
+
+		ev := receive(t, ch)
+		assert.Equal(t, `{"One":"released","Two":0}`, string(ev.Payload))
+		assert.NoError(t, ev.Ack())
+
+		// the message isn't added to the stream again
+		n, err := s.redisClient.XLen(context.Background(), "stream-inflight").Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		// the released consumer is deleted once its message is claimed
+		require.Eventually(t, func() bool {
+			for _, name := range consumerNames(t, s, "stream-inflight", "workers") {
+				if strings.HasSuffix(name, releasedSuffix) {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Prompt", func(t *testing.T) {
+		timeout := readGroupTimeout
+		readGroupTimeout = 10 * time.Second
+		defer func() { readGroupTimeout = timeout }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := s.ConsumeContext(ctx, "prompt", events.WithGroup("workers"))
+		require.NoError(t, err)
+
+		// the consumer is blocked reading the stream
+		time.Sleep(100 * time.Millisecond)
+		start := time.Now()
+		cancel()
+		closed(t, ch)
+		assert.Less(t, int64(time.Since(start)), int64(time.Second), "expected the read to be interrupted")
+	})
+}
+
+// consumerNames returns the consumers of the group, go-redis expects the idle
+// times miniredis doesn't return.
+func consumerNames(t *testing.T, s *redisStream, topic, group string) []string {
+	res, err := s.redisClient.Do(context.Background(), "XINFO", "CONSUMERS", topic, group).Slice()
+	require.NoError(t, err)
+
+	var names []string
+	for _, c := range res {
+		fields := c.([]interface{})
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i] == "name" {
+				names = append(names, fields[i+1].(string))
+			}
+		}
+	}
+	return names
+}
+
+func TestReleaseGroups(t *testing.T) {
+	s, _ := newTestStream(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a, err := s.ConsumeContext(ctx, "orders", events.WithGroup("a"), events.WithAutoAck(false, time.Minute))
+	require.NoError(t, err)
+	b, err := s.Consume("orders", events.WithGroup("b"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Publish("orders", testObj{One: "once"}))
+	receive(t, a)
+	receive(t, b)
+
+	// the consumers of a restart a few times without acking the message
+	for i := 0; i < 3; i++ {
+		cancel()
+		closed(t, a)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		a, err = s.ConsumeContext(ctx, "orders", events.WithGroup("a"), events.WithAutoAck(false, time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, `{"One":"once","Two":0}`, string(receive(t, a).Payload))
+	}
+	defer cancel()
+
+	// the other group doesn't get the message again
+	timeout := time.After(500 * time.Millisecond)
+	for {
+		select {
+		case ev := <-b:
+			assert.Empty(t, ev.ID, "unexpected duplicate %s", ev.Payload)
+			continue
+		case <-timeout:
+		}
+		break
+	}
+}
+
+func TestClose(t *testing.T) {
+	s, _ := newTestStream(t)
+
+	one, err := s.Consume("one")
+	require.NoError(t, err)
+	two, err := s.ConsumeContext(context.Background(), "two", events.WithGroup("workers"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Close())
+	closed(t, one)
+	closed(t, two)
+
+	_, err = s.Consume("one")
+	assert.Equal(t, ErrClosed, err)
+
+	// closing again is a noop
+	assert.NoError(t, s.Close())
+}
+
+func TestDeadLetter(t *testing.T) {
+	s, mr := newTestStream(t, DeadLetter("-dlq"))
+
+	ch, err := s.Consume("orders", events.WithGroup("workers"), events.WithAutoAck(false, time.Minute), events.WithRetryLimit(1))
+	require.NoError(t, err)
+	dlq, err := s.Consume("orders-dlq", events.WithGroup("workers"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Publish("orders", testObj{One: "failing"}, events.WithMetadata(map[string]string{"meta": "bar"})))
+
+	// the message is retried once before being dead lettered
+	for i := 0; i < 2; i++ {
+		ev := receive(t, ch)
+		assert.Equal(t, `{"One":"failing","Two":0}`, string(ev.Payload))
+		require.NoError(t, ev.Nack())
+	}
+
+	ev := receive(t, dlq)
+	assert.Equal(t, "orders-dlq", ev.Topic)
+	assert.Equal(t, `{"One":"failing","Two":0}`, string(ev.Payload))
+	assert.Equal(t, "bar", ev.Metadata["meta"])
+	assert.Equal(t, "orders", ev.Metadata[deadletter.MetadataTopic])
+	assert.Equal(t, "2", ev.Metadata[deadletter.MetadataAttempts])
+	assert.Equal(t, "retry limit of 1 exceeded", ev.Metadata[deadletter.MetadataError])
+	assert.NotEmpty(t, ev.Metadata[deadletter.MetadataTimestamp])
+
+	// redriven to the original topic
+	require.NoError(t, deadletter.Redrive(s, ev))
+	ev = receive(t, ch)
+	assert.Equal(t, "orders", ev.Topic)
+	assert.Equal(t, `{"One":"failing","Two":0}`, string(ev.Payload))
+	assert.Equal(t, map[string]string{"meta": "bar"}, ev.Metadata)
+	require.NoError(t, ev.Ack())
+
+	assert.Equal(t, deadletter.ErrNotDeadLetter, deadletter.Redrive(s, ev))
+
+	// messages that can't be decoded are dead lettered too
+	_, err = mr.XAdd("stream-orders", "*", []string{"event", "{", "attempt", "1"})
+	require.NoError(t, err)
+
+	ev = receive(t, dlq)
+	assert.Equal(t, "{", string(ev.Payload))
+	assert.Equal(t, "orders", ev.Metadata[deadletter.MetadataTopic])
+	assert.NotEmpty(t, ev.Metadata[deadletter.MetadataError])
+}
@@ -0,0 +1,17 @@
+package stream
+
+import (
+	"go-micro.dev/v4/events"
+
+	"github.com/go-micro/plugins/v4/events/deadletter"
+)
+
+// deadLetter adds the event to the dead letter topic of its topic, it's a
+// noop without one.
+func (r *redisStream) deadLetter(ev events.Event, attempts int, reason error) error {
+	if len(r.opts.DeadLetterSuffix) == 0 {
+		return nil
+	}
+
+	return addEvent(r.redisClient, r.opts, deadletter.New(ev, r.opts.DeadLetterSuffix, attempts, reason), 1)
+}
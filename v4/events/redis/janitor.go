@@ -23,7 +23,11 @@ func (r *redisStream) runJanitor() {
 			if err := r.cleanupConsumers(); err != nil {
 				logger.Errorf("Error cleaning up consumers")
 			}
-			time.Sleep(janitorFrequency)
+			select {
+			case <-time.After(janitorFrequency):
+			case <-r.done:
+				return
+			}
 		}
 	}()
 }
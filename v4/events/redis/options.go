@@ -2,6 +2,7 @@ package stream
 
 import (
 	"crypto/tls"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -13,6 +14,13 @@ type Options struct {
 	Password  string
 	TLSConfig *tls.Config
 
+	// DeadLetterSuffix names the dead letter topic of a topic, see DeadLetter.
+	DeadLetterSuffix string
+
+	// MaxLen and MaxAge bound the streams, they're trimmed when adding events.
+	MaxLen int64
+	MaxAge time.Duration
+
 	RedisOptions *redis.UniversalOptions
 }
 
@@ -48,6 +56,32 @@ func TLSConfig(tlsConfig *tls.Config) Option {
 	}
 }
 
+// DeadLetter routes the messages exceeding the retry limit of a consumer,
+// or that can't be decoded, to a dead letter topic instead of discarding
+// them. The dead letter topic is the topic consumed with the suffix.
+func DeadLetter(suffix string) Option {
+	return func(o *Options) {
+		o.DeadLetterSuffix = suffix
+	}
+}
+
+// MaxLen trims the stream of a topic to about the number of events when
+// adding one. The trimming is approximate, redis trims whole nodes of the
+// stream only, so the stream may be a little longer.
+func MaxLen(n int64) Option {
+	return func(o *Options) {
+		o.MaxLen = n
+	}
+}
+
+// MaxAge trims the events older than the duration from the stream of a
+// topic when adding one, approximately like MaxLen. It needs redis 6.2.
+func MaxAge(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxAge = d
+	}
+}
+
 // WithRedisOptions sets advanced options for redis.
 func WithRedisOptions(options *redis.UniversalOptions) Option {
 	return func(o *Options) {
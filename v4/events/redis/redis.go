@@ -15,6 +15,8 @@ import (
 	"github.com/pkg/errors"
 	"go-micro.dev/v4/events"
 	"go-micro.dev/v4/logger"
+
+	"github.com/go-micro/plugins/v4/events/deadletter"
 )
 
 var (
@@ -25,12 +27,37 @@ var (
 
 const (
 	errMsgPoolTimeout = "redis: connection pool timeout"
+	// the messages pending for a stopped consumer are released to the
+	// consumer of its name with the suffix
+	releasedSuffix = ":released"
+)
+
+var (
+	// ErrClosed is returned when consuming from a closed stream.
+	ErrClosed = errors.New("stream closed")
 )
 
+// ContextConsumer is implemented by the streams of NewStream, consuming from
+// a topic until the context is done:
+//
+//	ch, err := stream.(stream.ContextConsumer).ConsumeContext(ctx, "topic")
+type ContextConsumer interface {
+	ConsumeContext(ctx context.Context, topic string, opts ...events.ConsumeOption) (<-chan events.Event, error)
+}
+
+var _ ContextConsumer = (*redisStream)(nil)
+
 type redisStream struct {
 	sync.RWMutex
+	opts        Options
 	redisClient redis.UniversalClient
 	attempts    map[string]int
+
+	// consumers in flight, stopped on close
+	mtx       sync.Mutex
+	closed    bool
+	done      chan struct{}
+	consumers sync.WaitGroup
 }
 
 func NewStream(opts ...Option) (events.Stream, error) {
@@ -41,8 +68,10 @@ func NewStream(opts ...Option) (events.Stream, error) {
 
 	rc := options.newUniversalClient()
 	rs := &redisStream{
+		opts:        options,
 		redisClient: rc,
 		attempts:    map[string]int{},
+		done:        make(chan struct{}),
 	}
 	rs.runJanitor()
 	return rs, nil
@@ -81,19 +110,52 @@ func (r *redisStream) Publish(topic string, msg interface{}, opts ...events.Publ
 		Payload:   payload,
 	}
 
+	return addEvent(r.redisClient, r.opts, event, 1)
+}
+
+// addEvent adds the event to the stream of its topic, trimming the stream
+// to the retention of the options.
+func addEvent(rc redis.UniversalClient, options Options, event *events.Event, attempt int) error {
 	// serialize the event to bytes
 	bytes, err := json.Marshal(event)
 	if err != nil {
 		return errors.Wrap(err, "Error encoding event")
 	}
 
-	return r.redisClient.XAdd(context.Background(), &redis.XAddArgs{
+	ctx := context.Background()
+	args := &redis.XAddArgs{
 		Stream: fmt.Sprintf("stream-%s", event.Topic),
-		Values: map[string]interface{}{"event": string(bytes), "attempt": 1},
-	}).Err()
+		Values: map[string]interface{}{"event": string(bytes), "attempt": attempt},
+	}
+	if options.MaxLen > 0 {
+		args.MaxLen = options.MaxLen
+		args.Approx = true
+	}
+
+	if options.MaxAge <= 0 {
+		return rc.XAdd(ctx, args).Err()
+	}
+
+	// XADD trims by one of MAXLEN and MINID only
+	minID := fmt.Sprintf("%d", time.Now().Add(-options.MaxAge).UnixNano()/int64(time.Millisecond))
+	_, err = rc.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.XAdd(ctx, args)
+		p.XTrimMinIDApprox(ctx, args.Stream, minID, 0)
+		return nil
+	})
+	return err
 }
 
 func (r *redisStream) Consume(topic string, opts ...events.ConsumeOption) (<-chan events.Event, error) {
+	return r.ConsumeContext(context.Background(), topic, opts...)
+}
+
+// ConsumeContext consumes from a topic until the context is done or the
+// stream is closed, the consumer stops once the blocking read in flight
+// returns. The channel is closed once the consumer stopped. The group
+// generated for the call is destroyed, the messages in flight of a named
+// group are added back to the stream for the other consumers.
+func (r *redisStream) ConsumeContext(ctx context.Context, topic string, opts ...events.ConsumeOption) (<-chan events.Event, error) {
 	if len(topic) == 0 {
 		return nil, events.ErrMissingTopic
 	}
@@ -103,13 +165,38 @@ func (r *redisStream) Consume(topic string, opts ...events.ConsumeOption) (<-cha
 		o(&options)
 	}
 	group := options.Group
-	if len(group) == 0 {
+	ephemeral := len(group) == 0
+	if ephemeral {
 		group = uuid.New().String()
 	}
-	return r.consumeWithGroup(topic, group, options)
+	return r.consumeWithGroup(ctx, topic, group, ephemeral, options)
+}
+
+// Close stops all the consumers and closes the client.
+func (r *redisStream) Close() error {
+	r.mtx.Lock()
+	if r.closed {
+		r.mtx.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.done)
+	r.mtx.Unlock()
+
+	r.consumers.Wait()
+
+	return r.redisClient.Close()
 }
 
-func (r *redisStream) consumeWithGroup(topic, group string, options events.ConsumeOptions) (<-chan events.Event, error) {
+func (r *redisStream) consumeWithGroup(ctx context.Context, topic, group string, ephemeral bool, options events.ConsumeOptions) (<-chan events.Event, error) {
+	r.mtx.Lock()
+	if r.closed {
+		r.mtx.Unlock()
+		return nil, ErrClosed
+	}
+	r.consumers.Add(1)
+	r.mtx.Unlock()
+
 	topic = fmt.Sprintf("stream-%s", topic)
 	lastRead := "$"
 	if !options.Offset.IsZero() {
@@ -119,83 +206,61 @@ func (r *redisStream) consumeWithGroup(topic, group string, options events.Consu
 		return r.redisClient.XGroupCreateMkStream(context.Background(), topic, group, lastRead).Err()
 	}, 2); err != nil {
 		if !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			r.consumers.Done()
 			return nil, err
 		}
 	}
 	consumerName := uuid.New().String()
 	ch := make(chan events.Event)
+
+	// stop on close too
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-r.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	go func() {
 		defer func() {
-			logger.Infof("Deleting consumer %s %s %s", topic, group, consumerName)
-			// try to clean up the consumer
-			if err := callWithRetry(func() error {
-				return r.redisClient.XGroupDelConsumer(context.Background(), topic, group, consumerName).Err()
-			}, 2); err != nil {
-				logger.Errorf("Error deleting consumer %s", err)
+			cancel()
+			if ephemeral {
+				r.destroyGroup(topic, group)
+			} else {
+				if r.releasePending(topic, group, consumerName) {
+					r.deleteConsumer(topic, group, consumerName)
+				}
 			}
 			close(ch)
+			r.consumers.Done()
 		}()
 
-		start := "-"
+		var lastClaim time.Time
 		for {
-			// sweep up any old pending messages
-			var pendingCmd *redis.XPendingExtCmd
-			err := callWithRetry(func() error {
-				pendingCmd = r.redisClient.XPendingExt(context.Background(), &redis.XPendingExtArgs{
-					Stream: topic,
-					Group:  group,
-					Start:  start,
-					End:    "+",
-					Count:  50,
-				})
-				return pendingCmd.Err()
-			}, 2)
-			if err != nil && err != redis.Nil {
-				logger.Errorf("Error finding pending messages %s", err)
-				return
-			}
-			pend := pendingCmd.Val()
-			if len(pend) == 0 {
-				break
-			}
-			pendingIDs := make([]string, len(pend))
-			for i, p := range pend {
-				pendingIDs[i] = p.ID
-			}
-			var claimCmd *redis.XMessageSliceCmd
-			err = callWithRetry(func() error {
-				claimCmd = r.redisClient.XClaim(context.Background(), &redis.XClaimArgs{
-					Stream:   topic,
-					Group:    group,
-					Consumer: consumerName,
-					MinIdle:  pendingIdleTime,
-					Messages: pendingIDs,
-				})
-				return claimCmd.Err()
-			}, 2)
-			if err != nil {
-				logger.Errorf("Error claiming message %s", err)
+			if ctx.Err() != nil {
 				return
 			}
-			msgs := claimCmd.Val()
-			if err := r.processMessages(msgs, ch, topic, group, options.AutoAck, options.RetryLimit); err != nil {
-				logger.Errorf("Error reprocessing message %s", err)
-				return
-			}
-
-			if len(pendingIDs) < 50 {
-				break
+			// sweep up any old pending messages, the ones released by the
+			// stopped consumers of the group too
+			if time.Since(lastClaim) >= readGroupTimeout {
+				if err := r.claimPending(ctx, ch, topic, group, consumerName, options); err != nil {
+					return
+				}
+				lastClaim = time.Now()
 			}
-			start = incrementID(pendingIDs[49])
-		}
-		for {
-			res := r.redisClient.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+			res := r.redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
 				Group:    group,
 				Consumer: consumerName,
 				Streams:  []string{topic, ">"},
 				Block:    readGroupTimeout,
 			})
 			sl, err := res.Result()
+			if ctx.Err() != nil {
+				// the messages read are released with the pending ones
+				return
+			}
 			if err != nil && err != redis.Nil {
 				logger.Errorf("Error reading from stream %s", err)
 				if !isTimeoutError(err) {
@@ -208,6 +273,8 @@ func (r *redisStream) consumeWithGroup(topic, group string, options events.Consu
 				// test the channel is still being read from
 				select {
 				case ch <- events.Event{}:
+				case <-ctx.Done():
+					return
 				case <-time.After(consumerTimeout):
 					logger.Errorf("Timed out waiting for consumer")
 					return
@@ -215,7 +282,10 @@ func (r *redisStream) consumeWithGroup(topic, group string, options events.Consu
 				continue
 			}
 
-			if err := r.processMessages(sl[0].Messages, ch, topic, group, options.AutoAck, options.RetryLimit); err != nil {
+			if err := r.processMessages(ctx, sl[0].Messages, ch, topic, group, options.AutoAck, options.RetryLimit); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
 				logger.Errorf("Error processing message %s", err)
 				return
 			}
@@ -249,7 +319,7 @@ func isTimeoutError(err error) bool {
 	return err != nil && strings.Contains(err.Error(), errMsgPoolTimeout)
 }
 
-func (r *redisStream) processMessages(msgs []redis.XMessage, ch chan events.Event, topic, group string, autoAck bool, retryLimit int) error {
+func (r *redisStream) processMessages(ctx context.Context, msgs []redis.XMessage, ch chan events.Event, topic, group string, autoAck bool, retryLimit int) error {
 	for _, v := range msgs {
 		vid := v.ID
 		evBytes := v.Values["event"]
@@ -262,6 +332,17 @@ func (r *redisStream) processMessages(msgs []redis.XMessage, ch chan events.Even
 		}
 		if err := json.Unmarshal([]byte(bStr), &ev); err != nil {
 			logger.Warnf("Failed to unmarshal event, discarding %s %s", err, vid)
+			ev := events.Event{
+				ID:        vid,
+				Topic:     strings.TrimPrefix(topic, "stream-"),
+				Timestamp: time.Now(),
+				Payload:   []byte(bStr),
+			}
+			if err := r.deadLetter(ev, 1, err); err != nil {
+				// leave it pending, it's claimed again
+				logger.Errorf("Error dead lettering message %s %s", err, vid)
+				continue
+			}
 			r.redisClient.XAck(context.Background(), topic, group, vid)
 			continue
 		}
@@ -279,32 +360,31 @@ func (r *redisStream) processMessages(msgs []redis.XMessage, ch chan events.Even
 				return err
 			})
 			ev.SetNackFunc(func() error {
-				// no way to nack a message. Best you can do is to ack and readd
-				if err := r.redisClient.XAck(context.Background(), topic, group, vid).Err(); err != nil {
-					return err
-				}
 				r.RLock()
 				attempt := r.attempts[attemptsKey]
 				r.RUnlock()
 				if retryLimit > 0 && attempt > retryLimit {
-					// don't readd
+					// don't readd, the message stays pending if it can't be dead lettered
+					if err := r.deadLetter(ev, attempt, deadletter.RetryLimitError(retryLimit)); err != nil {
+						return err
+					}
 					r.Lock()
 					delete(r.attempts, attemptsKey)
 					r.Unlock()
-					return nil
+					return r.redisClient.XAck(context.Background(), topic, group, vid).Err()
 				}
-				bytes, err := json.Marshal(ev)
-				if err != nil {
-					return errors.Wrap(err, "Error encoding event")
+				// no way to nack a message. Best you can do is to ack and readd
+				if err := r.redisClient.XAck(context.Background(), topic, group, vid).Err(); err != nil {
+					return err
 				}
-				return r.redisClient.XAdd(context.Background(), &redis.XAddArgs{
-					Stream: fmt.Sprintf("stream-%s", ev.Topic),
-					Values: map[string]interface{}{"event": string(bytes), "attempt": attempt + 1},
-				}).Err()
+				return addEvent(r.redisClient, r.opts, &ev, attempt+1)
 			})
 		}
 		select {
 		case ch <- ev:
+		case <-ctx.Done():
+			// stopped, the message is released with the pending ones
+			return ctx.Err()
 		case <-time.After(consumerTimeout):
 			// If event is not consumed from channel after 10 secs we assume that something is
 			// wrong with the consumer so we bomb out
@@ -320,6 +400,180 @@ func (r *redisStream) processMessages(msgs []redis.XMessage, ch chan events.Even
 	return nil
 }
 
+// claimPending claims the messages of the group pending for longer than
+// pendingIdleTime and processes them. The released consumers are deleted
+// once their messages are claimed.
+func (r *redisStream) claimPending(ctx context.Context, ch chan events.Event, topic, group, consumerName string, options events.ConsumeOptions) error {
+	released := make(map[string]bool)
+	defer func() {
+		for name := range released {
+			if ctx.Err() == nil && r.pendingCount(ctx, topic, group, name) == 0 {
+				r.deleteConsumer(topic, group, name)
+			}
+		}
+	}()
+
+	start := "-"
+	for {
+		var pendingCmd *redis.XPendingExtCmd
+		err := callWithRetry(func() error {
+			pendingCmd = r.redisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream: topic,
+				Group:  group,
+				Start:  start,
+				End:    "+",
+				Count:  50,
+			})
+			return pendingCmd.Err()
+		}, 2)
+		if err != nil && err != redis.Nil {
+			if ctx.Err() == nil {
+				logger.Errorf("Error finding pending messages %s", err)
+			}
+			return err
+		}
+		pend := pendingCmd.Val()
+		if len(pend) == 0 {
+			return nil
+		}
+		var pendingIDs []string
+		for _, p := range pend {
+			// XCLAIM checks it too, the messages in flight aren't claimed
+			if p.Idle < pendingIdleTime {
+				continue
+			}
+			pendingIDs = append(pendingIDs, p.ID)
+			if strings.HasSuffix(p.Consumer, releasedSuffix) {
+				released[p.Consumer] = true
+			}
+		}
+		if len(pendingIDs) == 0 {
+			if len(pend) < 50 {
+				return nil
+			}
+			start = incrementID(pend[49].ID)
+			continue
+		}
+		var claimCmd *redis.XMessageSliceCmd
+		err = callWithRetry(func() error {
+			claimCmd = r.redisClient.XClaim(ctx, &redis.XClaimArgs{
+				Stream:   topic,
+				Group:    group,
+				Consumer: consumerName,
+				MinIdle:  pendingIdleTime,
+				Messages: pendingIDs,
+			})
+			return claimCmd.Err()
+		}, 2)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Errorf("Error claiming message %s", err)
+			}
+			return err
+		}
+		msgs := claimCmd.Val()
+		if err := r.processMessages(ctx, msgs, ch, topic, group, options.AutoAck, options.RetryLimit); err != nil {
+			if ctx.Err() == nil {
+				logger.Errorf("Error reprocessing message %s", err)
+			}
+			return err
+		}
+
+		if len(pend) < 50 {
+			return nil
+		}
+		start = incrementID(pend[49].ID)
+	}
+}
+
+// pendingCount returns the number of messages pending for the consumer, -1
+// if it can't be read.
+func (r *redisStream) pendingCount(ctx context.Context, topic, group, consumerName string) int {
+	pend, err := r.redisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   topic,
+		Group:    group,
+		Start:    "-",
+		End:      "+",
+		Count:    1,
+		Consumer: consumerName,
+	}).Result()
+	if err != nil && err != redis.Nil {
+		return -1
+	}
+	return len(pend)
+}
+
+// releasePending hands the messages pending for the stopped consumer over to
+// its released consumer, idle for pendingIdleTime so the consumers of the
+// group claim them right away. The messages aren't added to the stream again,
+// the other groups of the topic would get them twice. It returns whether the
+// consumer can be deleted without losing messages.
+func (r *redisStream) releasePending(topic, group, consumerName string) bool {
+	ctx := context.Background()
+	for {
+		var pendingCmd *redis.XPendingExtCmd
+		err := callWithRetry(func() error {
+			pendingCmd = r.redisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream:   topic,
+				Group:    group,
+				Start:    "-",
+				End:      "+",
+				Count:    50,
+				Consumer: consumerName,
+			})
+			return pendingCmd.Err()
+		}, 2)
+		if err != nil && err != redis.Nil {
+			logger.Errorf("Error finding pending messages %s", err)
+			return false
+		}
+		pend := pendingCmd.Val()
+		if len(pend) == 0 {
+			return true
+		}
+
+		// go-redis doesn't support the IDLE option of XCLAIM
+		args := []interface{}{"XCLAIM", topic, group, consumerName + releasedSuffix, 0}
+		for _, p := range pend {
+			args = append(args, p.ID)
+		}
+		args = append(args, "IDLE", pendingIdleTime.Milliseconds(), "JUSTID")
+
+		var claimed []interface{}
+		if err := callWithRetry(func() error {
+			var err error
+			claimed, err = r.redisClient.Do(ctx, args...).Slice()
+			return err
+		}, 2); err != nil {
+			logger.Errorf("Error releasing pending messages %s", err)
+			return false
+		}
+		if len(claimed) == 0 {
+			// the messages left can't be handed over
+			return false
+		}
+	}
+}
+
+func (r *redisStream) deleteConsumer(topic, group, consumerName string) {
+	logger.Infof("Deleting consumer %s %s %s", topic, group, consumerName)
+	// try to clean up the consumer
+	if err := callWithRetry(func() error {
+		return r.redisClient.XGroupDelConsumer(context.Background(), topic, group, consumerName).Err()
+	}, 2); err != nil {
+		logger.Errorf("Error deleting consumer %s", err)
+	}
+}
+
+func (r *redisStream) destroyGroup(topic, group string) {
+	logger.Infof("Destroying group %s %s", topic, group)
+	if err := callWithRetry(func() error {
+		return r.redisClient.XGroupDestroy(context.Background(), topic, group).Err()
+	}, 2); err != nil {
+		logger.Errorf("Error destroying group %s", err)
+	}
+}
+
 func incrementID(id string) string {
 	// id is of form 12345-0
 	parts := strings.Split(id, "-")
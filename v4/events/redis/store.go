@@ -0,0 +1,107 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"go-micro.dev/v4/events"
+	"go-micro.dev/v4/logger"
+)
+
+const (
+	defaultReadLimit = 250 // number of events read when no limit is set
+	readPageSize     = 100 // number of entries fetched from the stream at a time
+)
+
+type redisStore struct {
+	opts        Options
+	redisClient redis.UniversalClient
+}
+
+// NewStore returns an events store reading the history of the topic streams,
+// the same streams events are published to and consumed from. Events are
+// retained until the streams are trimmed, see MaxLen and MaxAge, the TTL
+// write option is ignored.
+func NewStore(opts ...Option) (events.Store, error) {
+	options := Options{}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &redisStore{opts: options, redisClient: options.newUniversalClient()}, nil
+}
+
+// Read the events of a topic, oldest first. A zero limit reads all of them.
+func (r *redisStore) Read(topic string, opts ...events.ReadOption) ([]*events.Event, error) {
+	// validate the topic
+	if len(topic) == 0 {
+		return nil, events.ErrMissingTopic
+	}
+
+	// parse the options
+	options := events.ReadOptions{
+		Limit: defaultReadLimit,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	stream := fmt.Sprintf("stream-%s", topic)
+	result := []*events.Event{}
+	skipped := uint(0)
+	start := "-"
+
+	for {
+		var rangeCmd *redis.XMessageSliceCmd
+		err := callWithRetry(func() error {
+			rangeCmd = r.redisClient.XRangeN(context.Background(), stream, start, "+", readPageSize)
+			return rangeCmd.Err()
+		}, 2)
+		if err != nil && err != redis.Nil {
+			return nil, errors.Wrap(err, "Error reading from stream")
+		}
+
+		msgs := rangeCmd.Val()
+		for _, v := range msgs {
+			if skipped < options.Offset {
+				skipped++
+				continue
+			}
+
+			bStr, ok := v.Values["event"].(string)
+			if !ok {
+				logger.Warnf("Failed to convert to bytes, skipping %s", v.ID)
+				continue
+			}
+			var ev events.Event
+			if err := json.Unmarshal([]byte(bStr), &ev); err != nil {
+				logger.Warnf("Failed to unmarshal event, skipping %s %s", err, v.ID)
+				continue
+			}
+
+			result = append(result, &ev)
+			if options.Limit > 0 && uint(len(result)) >= options.Limit {
+				return result, nil
+			}
+		}
+
+		if len(msgs) < readPageSize {
+			return result, nil
+		}
+		start = incrementID(msgs[len(msgs)-1].ID)
+	}
+}
+
+// Write an event to the stream of its topic, it's delivered to the consumers
+// of the topic.
+func (r *redisStore) Write(event *events.Event, opts ...events.WriteOption) error {
+	// validate the topic
+	if len(event.Topic) == 0 {
+		return events.ErrMissingTopic
+	}
+
+	return addEvent(r.redisClient, r.opts, event, 1)
+}
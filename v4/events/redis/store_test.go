@@ -0,0 +1,113 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-micro.dev/v4/events"
+)
+
+func readLimit(l uint) events.ReadOption {
+	return func(o *events.ReadOptions) {
+		o.Limit = l
+	}
+}
+
+func readOffset(l uint) events.ReadOption {
+	return func(o *events.ReadOptions) {
+		o.Offset = l
+	}
+}
+
+func TestStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	s, err := NewStore(Address(mr.Addr()))
+	require.NoError(t, err)
+
+	total := readPageSize + 20
+	for i := 0; i < total; i++ {
+		err := s.Write(&events.Event{
+			ID:        fmt.Sprintf("%d", i),
+			Topic:     "audit",
+			Timestamp: time.Now(),
+			Metadata:  map[string]string{"index": fmt.Sprintf("%d", i)},
+			Payload:   []byte(`{"name":"test"}`),
+		})
+		require.NoError(t, err)
+	}
+
+	// events published to the stream are read too
+	rc := (&Options{Address: mr.Addr()}).newUniversalClient()
+	stream := &redisStream{redisClient: rc, attempts: map[string]int{}}
+	require.NoError(t, stream.Publish("other", testObj{One: "one", Two: 2}))
+
+	evs, err := s.Read("audit", readLimit(0))
+	require.NoError(t, err)
+	require.Len(t, evs, total)
+	for i, ev := range evs {
+		assert.Equal(t, fmt.Sprintf("%d", i), ev.ID)
+		assert.Equal(t, "audit", ev.Topic)
+		assert.Equal(t, fmt.Sprintf("%d", i), ev.Metadata["index"])
+	}
+
+	evs, err = s.Read("audit")
+	require.NoError(t, err)
+	assert.Len(t, evs, total)
+
+	// the offset spans pages
+	evs, err = s.Read("audit", readOffset(readPageSize+5), readLimit(2))
+	require.NoError(t, err)
+	require.Len(t, evs, 2)
+	assert.Equal(t, fmt.Sprintf("%d", readPageSize+5), evs[0].ID)
+	assert.Equal(t, fmt.Sprintf("%d", readPageSize+6), evs[1].ID)
+
+	evs, err = s.Read("audit", readOffset(uint(total)))
+	require.NoError(t, err)
+	assert.Len(t, evs, 0)
+
+	evs, err = s.Read("other")
+	require.NoError(t, err)
+	require.Len(t, evs, 1)
+	assert.Equal(t, "other", evs[0].Topic)
+
+	evs, err = s.Read("missing")
+	require.NoError(t, err)
+	assert.Len(t, evs, 0)
+
+	_, err = s.Read("")
+	assert.Equal(t, events.ErrMissingTopic, err)
+	assert.Equal(t, events.ErrMissingTopic, s.Write(&events.Event{}))
+}
+
+func TestRetention(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	s, err := NewStore(Address(mr.Addr()), MaxLen(10))
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, s.Write(&events.Event{ID: fmt.Sprintf("%d", i), Topic: "len"}))
+	}
+
+	evs, err := s.Read("len")
+	require.NoError(t, err)
+	require.Len(t, evs, 10)
+	assert.Equal(t, "10", evs[0].ID)
+
+	s, err = NewStore(Address(mr.Addr()), MaxAge(time.Hour))
+	require.NoError(t, err)
+
+	_, err = mr.XAdd("stream-age", "1-0", []string{"event", `{"ID":"old","Topic":"age"}`, "attempt", "1"})
+	require.NoError(t, err)
+	require.NoError(t, s.Write(&events.Event{ID: "new", Topic: "age"}))
+
+	evs, err = s.Read("age")
+	require.NoError(t, err)
+	require.Len(t, evs, 1)
+	assert.Equal(t, "new", evs[0].ID)
+}
@@ -79,6 +79,20 @@ var tests = []testcase{
 		URI:    "/api/v1/namespaces/default/endpoints/baz",
 		Header: map[string]string{"foo": "bar"},
 	},
+	{
+		ReqFn: func(opts *Options) *Request {
+			return NewRequest(opts).Get().Group("discovery.k8s.io", "v1").Resource("endpointslices")
+		},
+		Method: "GET",
+		URI:    "/apis/discovery.k8s.io/v1/namespaces/default/endpointslices/",
+	},
+	{
+		ReqFn: func(opts *Options) *Request {
+			return NewRequest(opts).Get().Group("discovery.k8s.io", "v1").Namespace("").Resource("endpointslices")
+		},
+		Method: "GET",
+		URI:    "/apis/discovery.k8s.io/v1/endpointslices/",
+	},
 }
 
 var wrappedHandler = func(t *testing.T, test *testcase) http.HandlerFunc {
@@ -20,6 +20,8 @@ type Request struct {
 	method    string
 	host      string
 	namespace string
+	// the path of the API group, the core group by default
+	apiPath string
 
 	resource     string
 	resourceName *string
@@ -51,6 +53,7 @@ func NewRequest(opts *Options) *Request {
 		client:    opts.Client,
 		namespace: opts.Namespace,
 		host:      opts.Host,
+		apiPath:   "api/v1",
 	}
 
 	if opts.BearerToken != nil {
@@ -92,7 +95,15 @@ func (r *Request) Delete() *Request {
 	return r.verb("DELETE")
 }
 
-// Namespace is to set the namespace to operate on.
+// Group sets the API group and version of the resource, such as
+// "discovery.k8s.io" and "v1". The resources are in the core group by default.
+func (r *Request) Group(group, version string) *Request {
+	r.apiPath = "apis/" + group + "/" + version
+	return r
+}
+
+// Namespace is to set the namespace to operate on, an empty
+// namespace operates on the resources of all the namespaces.
 func (r *Request) Namespace(s string) *Request {
 	r.namespace = s
 	return r
@@ -150,7 +161,10 @@ func (r *Request) SetHeader(key, value string) *Request {
 
 // request builds the http.Request from the options.
 func (r *Request) request() (*http.Request, error) {
-	url := fmt.Sprintf("%s/api/v1/namespaces/%s/%s/", r.host, r.namespace, r.resource)
+	url := fmt.Sprintf("%s/%s/namespaces/%s/%s/", r.host, r.apiPath, r.namespace, r.resource)
+	if len(r.namespace) == 0 {
+		url = fmt.Sprintf("%s/%s/%s/", r.host, r.apiPath, r.resource)
+	}
 
 	// append resourceName if it is present
 	if r.resourceName != nil {
@@ -122,6 +122,34 @@ func (c *client) WatchPods(labels map[string]string) (watch.Watch, error) {
 	return api.NewRequest(c.opts).Get().Resource("pods").Params(&api.Params{LabelSelector: labels}).Watch()
 }
 
+// ListEndpointSlices ...
+func (c *client) ListEndpointSlices(namespace string, labels map[string]string) (*EndpointSliceList, error) {
+	var slices EndpointSliceList
+	err := c.endpointSlices(namespace).Params(&api.Params{LabelSelector: labels}).Do().Decode(&slices)
+
+	return &slices, err
+}
+
+// WatchEndpointSlices ...
+func (c *client) WatchEndpointSlices(namespace string, labels map[string]string) (watch.Watch, error) {
+	return c.endpointSlices(namespace).Params(&api.Params{LabelSelector: labels}).Watch()
+}
+
+// endpointSlices returns a request of the endpoint slices in the namespace.
+func (c *client) endpointSlices(namespace string) *api.Request {
+	req := api.NewRequest(c.opts).Get().Group("discovery.k8s.io", "v1").Resource("endpointslices")
+
+	switch namespace {
+	case "":
+	case AllNamespaces:
+		req.Namespace("")
+	default:
+		req.Namespace(namespace)
+	}
+
+	return req
+}
+
 func detectNamespace() (string, error) {
 	nsPath := path.Join(serviceAccountPath, "namespace")
 
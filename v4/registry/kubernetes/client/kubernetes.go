@@ -2,11 +2,17 @@ package client
 
 import "github.com/go-micro/plugins/v4/registry/kubernetes/client/watch"
 
+// AllNamespaces is the namespace to list and watch the resources of every
+// namespace in, the empty namespace is the namespace of the client.
+const AllNamespaces = "*"
+
 // Kubernetes ...
 type Kubernetes interface {
 	ListPods(labels map[string]string) (*PodList, error)
 	UpdatePod(podName string, pod *Pod) (*Pod, error)
 	WatchPods(labels map[string]string) (watch.Watch, error)
+	ListEndpointSlices(namespace string, labels map[string]string) (*EndpointSliceList, error)
+	WatchEndpointSlices(namespace string, labels map[string]string) (watch.Watch, error)
 }
 
 // PodList ...
@@ -23,6 +29,7 @@ type Pod struct {
 // Meta ...
 type Meta struct {
 	Name              string             `json:"name,omitempty"`
+	Namespace         string             `json:"namespace,omitempty"`
 	Labels            map[string]*string `json:"labels,omitempty"`
 	Annotations       map[string]*string `json:"annotations,omitempty"`
 	DeletionTimestamp string             `json:"deletionTimestamp,omitempty"`
@@ -33,3 +40,48 @@ type Status struct {
 	PodIP string `json:"podIP"`
 	Phase string `json:"phase"`
 }
+
+// EndpointSliceList ...
+type EndpointSliceList struct {
+	Items []EndpointSlice `json:"items"`
+}
+
+// EndpointSlice is a subset of the endpoints of a service.
+type EndpointSlice struct {
+	Metadata    *Meta          `json:"metadata"`
+	AddressType string         `json:"addressType"`
+	Endpoints   []Endpoint     `json:"endpoints"`
+	Ports       []EndpointPort `json:"ports"`
+}
+
+// Endpoint is a pod, or any other backend, of a service.
+type Endpoint struct {
+	Addresses  []string           `json:"addresses"`
+	Conditions EndpointConditions `json:"conditions"`
+	Hostname   string             `json:"hostname,omitempty"`
+	NodeName   string             `json:"nodeName,omitempty"`
+	Zone       string             `json:"zone,omitempty"`
+	TargetRef  *ObjectReference   `json:"targetRef,omitempty"`
+}
+
+// EndpointConditions are the states of an endpoint, unknown when nil.
+type EndpointConditions struct {
+	Ready       *bool `json:"ready,omitempty"`
+	Serving     *bool `json:"serving,omitempty"`
+	Terminating *bool `json:"terminating,omitempty"`
+}
+
+// EndpointPort is a port of the endpoints of a slice, a nil port
+// stands for all the ports.
+type EndpointPort struct {
+	Name     string `json:"name,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Port     *int   `json:"port,omitempty"`
+}
+
+// ObjectReference ...
+type ObjectReference struct {
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
@@ -12,19 +12,33 @@ import (
 	"github.com/go-micro/plugins/v4/registry/kubernetes/client/watch"
 )
 
+// the namespace of the client.
+const defaultNamespace = "default"
+
 // Client ...
 type Client struct {
 	sync.RWMutex
-	Pods     map[string]*client.Pod
-	events   chan watch.Event
-	watchers []*mockWatcher
+	Pods map[string]*client.Pod
+	// EndpointSlices by namespace and name, joined by a slash.
+	EndpointSlices map[string]*client.EndpointSlice
+	events         chan event
+	watchers       []*mockWatcher
+}
+
+// event of a resource in a namespace.
+type event struct {
+	watch.Event
+	resource  string
+	namespace string
+	labels    map[string]*string
 }
 
 // NewClient ...
 func NewClient() *Client {
 	c := &Client{
-		Pods:   make(map[string]*client.Pod),
-		events: make(chan watch.Event),
+		Pods:           make(map[string]*client.Pod),
+		EndpointSlices: make(map[string]*client.EndpointSlice),
+		events:         make(chan event),
 	}
 
 	// broadcast events to watchers
@@ -32,10 +46,14 @@ func NewClient() *Client {
 		for e := range c.events {
 			c.RLock()
 			for _, w := range c.watchers {
+				if !w.matches(e) {
+					continue
+				}
+
 				select {
 				case <-w.stop:
 				default:
-					w.results <- e
+					w.results <- e.Event
 				}
 			}
 			c.RUnlock()
@@ -63,9 +81,13 @@ func (c *Client) UpdatePod(podName string, pod *client.Pod) (*client.Pod, error)
 		return nil, err
 	}
 
-	c.events <- watch.Event{
-		Type:   watch.Modified,
-		Object: json.RawMessage(pstr),
+	c.events <- event{
+		Event: watch.Event{
+			Type:   watch.Modified,
+			Object: json.RawMessage(pstr),
+		},
+		resource:  "pods",
+		namespace: defaultNamespace,
 	}
 
 	//nolint:nilnil
@@ -91,12 +113,99 @@ func (c *Client) ListPods(labels map[string]string) (*client.PodList, error) {
 
 // WatchPods ...
 func (c *Client) WatchPods(labels map[string]string) (watch.Watch, error) {
+	return c.watch("pods", "", nil), nil
+}
+
+// ListEndpointSlices ...
+func (c *Client) ListEndpointSlices(namespace string, labels map[string]string) (*client.EndpointSliceList, error) {
+	var slices []client.EndpointSlice
+
+	for _, v := range c.EndpointSlices {
+		if namespaceMatch(namespace, v.Metadata.Namespace) && labelFilterMatch(v.Metadata.Labels, labels) {
+			slices = append(slices, *v)
+		}
+	}
+
+	return &client.EndpointSliceList{
+		Items: slices,
+	}, nil
+}
+
+// WatchEndpointSlices ...
+func (c *Client) WatchEndpointSlices(namespace string, labels map[string]string) (watch.Watch, error) {
+	return c.watch("endpointslices", namespace, labels), nil
+}
+
+// UpdateEndpointSlice adds the endpoint slice, or replaces the slice of
+// the same namespace and name.
+func (c *Client) UpdateEndpointSlice(slice *client.EndpointSlice) error {
+	if slice.Metadata == nil || slice.Metadata.Name == "" {
+		return errors.New("no endpoint slice name provided")
+	}
+
+	if slice.Metadata.Namespace == "" {
+		slice.Metadata.Namespace = defaultNamespace
+	}
+
+	key := slice.Metadata.Namespace + "/" + slice.Metadata.Name
+
+	eventType := watch.Added
+	if _, ok := c.EndpointSlices[key]; ok {
+		eventType = watch.Modified
+	}
+
+	c.EndpointSlices[key] = slice
+
+	return c.sliceEvent(eventType, slice)
+}
+
+// DeleteEndpointSlice ...
+func (c *Client) DeleteEndpointSlice(namespace, name string) error {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	key := namespace + "/" + name
+
+	slice, ok := c.EndpointSlices[key]
+	if !ok {
+		return api.ErrNotFound
+	}
+
+	delete(c.EndpointSlices, key)
+
+	return c.sliceEvent(watch.Deleted, slice)
+}
+
+func (c *Client) sliceEvent(eventType watch.EventType, slice *client.EndpointSlice) error {
+	b, err := json.Marshal(slice)
+	if err != nil {
+		return err
+	}
+
+	c.events <- event{
+		Event: watch.Event{
+			Type:   eventType,
+			Object: json.RawMessage(b),
+		},
+		resource:  "endpointslices",
+		namespace: slice.Metadata.Namespace,
+		labels:    slice.Metadata.Labels,
+	}
+
+	return nil
+}
+
+// watch returns a watcher of the events of a resource.
+func (c *Client) watch(resource, namespace string, labels map[string]string) *mockWatcher {
 	w := &mockWatcher{
-		results: make(chan watch.Event),
-		stop:    make(chan bool),
+		resource:  resource,
+		namespace: namespace,
+		labels:    labels,
+		results:   make(chan watch.Event),
+		stop:      make(chan bool),
 	}
 
-	i := len(c.watchers) // length of watchers is current index
 	c.Lock()
 	c.watchers = append(c.watchers, w)
 	c.Unlock()
@@ -105,11 +214,16 @@ func (c *Client) WatchPods(labels map[string]string) (watch.Watch, error) {
 		<-w.stop
 
 		c.Lock()
-		c.watchers = append(c.watchers[:i], c.watchers[i+1:]...)
+		for i, v := range c.watchers {
+			if v == w {
+				c.watchers = append(c.watchers[:i], c.watchers[i+1:]...)
+				break
+			}
+		}
 		c.Unlock()
 	}()
 
-	return w, nil
+	return w
 }
 
 // Teardown ...
@@ -118,11 +232,22 @@ func Teardown(c *Client) {
 		//nolint:errcheck
 		pstr, _ := json.Marshal(p)
 
-		c.events <- watch.Event{
-			Type:   watch.Deleted,
-			Object: json.RawMessage(pstr),
+		c.events <- event{
+			Event: watch.Event{
+				Type:   watch.Deleted,
+				Object: json.RawMessage(pstr),
+			},
+			resource:  "pods",
+			namespace: defaultNamespace,
 		}
 	}
 
 	c.Pods = make(map[string]*client.Pod)
+
+	for _, s := range c.EndpointSlices {
+		//nolint:errcheck
+		c.sliceEvent(watch.Deleted, s)
+	}
+
+	c.EndpointSlices = make(map[string]*client.EndpointSlice)
 }
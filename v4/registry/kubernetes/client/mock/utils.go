@@ -6,8 +6,18 @@ import (
 )
 
 type mockWatcher struct {
-	results chan watch.Event
-	stop    chan bool
+	resource  string
+	namespace string
+	labels    map[string]string
+	results   chan watch.Event
+	stop      chan bool
+}
+
+// matches returns whether the event is of the resources watched.
+func (w *mockWatcher) matches(e event) bool {
+	return w.resource == e.resource &&
+		namespaceMatch(w.namespace, e.namespace) &&
+		labelFilterMatch(e.labels, w.labels)
 }
 
 // Changes returns the results channel.
@@ -69,3 +79,14 @@ func labelFilterMatch(a map[string]*string, b map[string]string) bool {
 
 	return match
 }
+
+func namespaceMatch(watched, namespace string) bool {
+	switch watched {
+	case client.AllNamespaces:
+		return true
+	case "":
+		return namespace == defaultNamespace
+	default:
+		return namespace == watched
+	}
+}
@@ -0,0 +1,260 @@
+package kubernetes
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"go-micro.dev/v4/registry"
+
+	"github.com/go-micro/plugins/v4/registry/kubernetes/client"
+)
+
+var (
+	// labels set on the endpoint slices by kubernetes,
+	// the other labels are copied from the service.
+	labelServiceName = "kubernetes.io/service-name"
+	labelManagedBy   = "endpointslice.kubernetes.io/managed-by"
+	labelHeadless    = "service.kubernetes.io/headless"
+
+	// labels of the services setting the name and the version of
+	// the micro service, the name of the port it listens on and the
+	// protocol it serves.
+	labelPrefix      = "micro.mu/"
+	labelNameKey     = "micro.mu/name"
+	labelVersionKey  = "micro.mu/version"
+	labelPortKey     = "micro.mu/port"
+	labelProtocolKey = "micro.mu/protocol"
+
+	// defaultProtocol of the nodes without the protocol label, the
+	// protocol of the go-micro rpc server.
+	defaultProtocol = "mucp"
+)
+
+// getSliceService returns the versions of the service with ready endpoints.
+func (c *kregistry) getSliceService(name string) ([]*registry.Service, error) {
+	slices, err := c.listSlices()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*registry.Service
+
+	for _, svc := range sliceServices(slices) {
+		if svc.Name == name {
+			list = append(list, svc)
+		}
+	}
+
+	if len(list) == 0 {
+		return nil, registry.ErrNotFound
+	}
+
+	return list, nil
+}
+
+// listSliceServices returns the services with ready endpoints.
+func (c *kregistry) listSliceServices() ([]*registry.Service, error) {
+	slices, err := c.listSlices()
+	if err != nil {
+		return nil, err
+	}
+
+	return sliceServices(slices), nil
+}
+
+// listSlices lists the endpoint slices of the micro services in the namespaces.
+func (c *kregistry) listSlices() ([]client.EndpointSlice, error) {
+	var slices []client.EndpointSlice
+
+	for _, ns := range c.sliceNamespaces() {
+		list, err := c.client.ListEndpointSlices(ns, podSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		slices = append(slices, list.Items...)
+	}
+
+	return slices, nil
+}
+
+// sliceNamespaces returns the namespaces to discover the services in, the
+// namespace of the client by default.
+func (c *kregistry) sliceNamespaces() []string {
+	if len(c.namespaces) == 0 {
+		return []string{""}
+	}
+
+	seen := make(map[string]bool)
+	namespaces := make([]string, 0, len(c.namespaces))
+
+	for _, ns := range c.namespaces {
+		// the other namespaces are part of it
+		if ns == AllNamespaces {
+			return []string{AllNamespaces}
+		}
+
+		if seen[ns] {
+			continue
+		}
+
+		seen[ns] = true
+
+		namespaces = append(namespaces, ns)
+	}
+
+	return namespaces
+}
+
+// sliceServices builds the services of the endpoint slices, merging the
+// nodes of the slices of a service version.
+func sliceServices(slices []client.EndpointSlice) []*registry.Service {
+	// svcs mapped by name+version
+	svcs := make(map[string]*registry.Service)
+	list := make([]*registry.Service, 0, len(slices))
+
+	for i := range slices {
+		svc := sliceService(&slices[i])
+		if svc == nil || len(svc.Nodes) == 0 {
+			continue
+		}
+
+		s, ok := svcs[svc.Name+":"+svc.Version]
+		if !ok {
+			svcs[svc.Name+":"+svc.Version] = svc
+			list = append(list, svc)
+
+			continue
+		}
+
+		s.Nodes = append(s.Nodes, svc.Nodes...)
+	}
+
+	return list
+}
+
+// sliceService builds the service of an endpoint slice with a node per
+// ready endpoint, nil if the slice isn't the slice of a service.
+func sliceService(slice *client.EndpointSlice) *registry.Service {
+	if slice.Metadata == nil {
+		return nil
+	}
+
+	labels := slice.Metadata.Labels
+
+	name := labelValue(labels, labelNameKey)
+	if len(name) == 0 {
+		name = labelValue(labels, labelServiceName)
+	}
+
+	if len(name) == 0 {
+		return nil
+	}
+
+	svc := &registry.Service{
+		Name:    name,
+		Version: labelValue(labels, labelVersionKey),
+		Nodes:   []*registry.Node{},
+	}
+
+	port, ok := slicePort(slice, labelValue(labels, labelPortKey))
+	if !ok {
+		return svc
+	}
+
+	for _, ep := range slice.Endpoints {
+		if !endpointReady(ep) || len(ep.Addresses) == 0 {
+			continue
+		}
+
+		// the addresses of an endpoint are fungible
+		addr := net.JoinHostPort(ep.Addresses[0], strconv.Itoa(port))
+
+		svc.Nodes = append(svc.Nodes, &registry.Node{
+			Id:       name + "-" + addr,
+			Address:  addr,
+			Metadata: endpointMetadata(slice, ep),
+		})
+	}
+
+	return svc
+}
+
+// slicePort returns the port of the endpoints named, the first one if the
+// name is empty.
+func slicePort(slice *client.EndpointSlice, name string) (int, bool) {
+	for _, p := range slice.Ports {
+		if p.Port == nil {
+			continue
+		}
+
+		if len(name) == 0 || p.Name == name {
+			return *p.Port, true
+		}
+	}
+
+	return 0, false
+}
+
+// endpointReady returns whether the endpoint accepts requests, an unknown
+// readiness is ready.
+func endpointReady(ep client.Endpoint) bool {
+	return ep.Conditions.Ready == nil || *ep.Conditions.Ready
+}
+
+// endpointMetadata returns the metadata of the node of an endpoint: the
+// labels of the service, its protocol, namespace and ports, and the
+// location of the endpoint.
+func endpointMetadata(slice *client.EndpointSlice, ep client.Endpoint) map[string]string {
+	md := make(map[string]string)
+
+	for k, v := range slice.Metadata.Labels {
+		if v == nil || strings.HasPrefix(k, labelPrefix) {
+			continue
+		}
+
+		if k == labelServiceName || k == labelManagedBy || k == labelHeadless {
+			continue
+		}
+
+		md[k] = *v
+	}
+
+	md["protocol"] = labelValue(slice.Metadata.Labels, labelProtocolKey)
+	if len(md["protocol"]) == 0 {
+		md["protocol"] = defaultProtocol
+	}
+
+	if len(slice.Metadata.Namespace) > 0 {
+		md["namespace"] = slice.Metadata.Namespace
+	}
+
+	for _, p := range slice.Ports {
+		if p.Port != nil && len(p.Name) > 0 {
+			md["port."+p.Name] = strconv.Itoa(*p.Port)
+		}
+	}
+
+	if ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
+		md["pod"] = ep.TargetRef.Name
+	}
+
+	if len(ep.NodeName) > 0 {
+		md["node"] = ep.NodeName
+	}
+
+	if len(ep.Zone) > 0 {
+		md["zone"] = ep.Zone
+	}
+
+	return md
+}
+
+func labelValue(labels map[string]*string, key string) string {
+	if v := labels[key]; v != nil {
+		return *v
+	}
+
+	return ""
+}
@@ -0,0 +1,394 @@
+package kubernetes
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/registry"
+
+	"github.com/go-micro/plugins/v4/registry/kubernetes/client"
+	"github.com/go-micro/plugins/v4/registry/kubernetes/client/mock"
+)
+
+func TestEndpointSlicesGetService(t *testing.T) {
+	c := mock.NewClient()
+	r := setupSliceRegistry(c)
+
+	if _, err := r.GetService("foo"); !errors.Is(err, registry.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound got %v", err)
+	}
+
+	// the endpoints of a service are split in slices
+	updateSlice(t, c, testSlice("default", "foo-1", "foo", "10.0.0.1", "10.0.0.2"))
+	updateSlice(t, c, testSlice("default", "foo-2", "foo", "10.0.0.3"))
+	updateSlice(t, c, testSlice("default", "bar-1", "bar", "10.0.1.1"))
+
+	services, err := r.GetService("foo")
+	if err != nil {
+		t.Fatalf("did not expect GetService to fail %v", err)
+	}
+
+	if len(services) != 1 {
+		t.Fatalf("expected 1 service got %d", len(services))
+	}
+
+	svc := services[0]
+	if svc.Name != "foo" || svc.Version != "1.0.0" {
+		t.Fatalf("unexpected service %s:%s", svc.Name, svc.Version)
+	}
+
+	if addrs := nodeAddresses(svc.Nodes); !reflect.DeepEqual(addrs, []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080"}) {
+		t.Fatalf("unexpected nodes %v", addrs)
+	}
+
+	md := nodeByAddress(svc.Nodes, "10.0.0.1:8080").Metadata
+	expected := map[string]string{
+		"app":          "foo",
+		"protocol":     "mucp",
+		"namespace":    "default",
+		"port.grpc":    "8080",
+		"port.metrics": "9090",
+		"pod":          "foo-10.0.0.1",
+		"node":         "node-1",
+		"zone":         "zone-a",
+	}
+
+	if !reflect.DeepEqual(md, expected) {
+		t.Fatalf("expected metadata %v got %v", expected, md)
+	}
+}
+
+func TestEndpointSlicesReadiness(t *testing.T) {
+	c := mock.NewClient()
+	r := setupSliceRegistry(c)
+
+	slice := testSlice("default", "foo-1", "foo", "10.0.0.1", "10.0.0.2")
+	slice.Endpoints[1].Conditions.Ready = boolPtr(false)
+	updateSlice(t, c, slice)
+
+	services, err := r.GetService("foo")
+	if err != nil {
+		t.Fatalf("did not expect GetService to fail %v", err)
+	}
+
+	if addrs := nodeAddresses(services[0].Nodes); !reflect.DeepEqual(addrs, []string{"10.0.0.1:8080"}) {
+		t.Fatalf("expected the ready node only got %v", addrs)
+	}
+
+	// a service without ready endpoints isn't found
+	slice = testSlice("default", "foo-1", "foo", "10.0.0.1")
+	slice.Endpoints[0].Conditions.Ready = boolPtr(false)
+	updateSlice(t, c, slice)
+
+	if _, err := r.GetService("foo"); !errors.Is(err, registry.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound got %v", err)
+	}
+}
+
+func TestEndpointSlicesLabels(t *testing.T) {
+	c := mock.NewClient()
+	r := setupSliceRegistry(c)
+
+	// the labels of the service name the micro service and its port
+	slice := testSlice("default", "foo-1", "foo", "10.0.0.1")
+	slice.Metadata.Labels[labelNameKey] = strPtr("go.micro.foo")
+	slice.Metadata.Labels[labelVersionKey] = strPtr("2.0.0")
+	slice.Metadata.Labels[labelPortKey] = strPtr("metrics")
+	slice.Metadata.Labels[labelProtocolKey] = strPtr("grpc")
+	updateSlice(t, c, slice)
+
+	// not a micro service
+	other := testSlice("default", "other-1", "other", "10.0.2.1")
+	delete(other.Metadata.Labels, labelTypeKey)
+	updateSlice(t, c, other)
+
+	services, err := r.ListServices()
+	if err != nil {
+		t.Fatalf("did not expect ListServices to fail %v", err)
+	}
+
+	if len(services) != 1 {
+		t.Fatalf("expected 1 service got %d", len(services))
+	}
+
+	svc := services[0]
+	if svc.Name != "go.micro.foo" || svc.Version != "2.0.0" {
+		t.Fatalf("unexpected service %s:%s", svc.Name, svc.Version)
+	}
+
+	if svc.Nodes[0].Address != "10.0.0.1:9090" {
+		t.Fatalf("expected the metrics port got %s", svc.Nodes[0].Address)
+	}
+
+	if p := svc.Nodes[0].Metadata["protocol"]; p != "grpc" {
+		t.Fatalf("expected the grpc protocol got %s", p)
+	}
+
+	for k := range svc.Nodes[0].Metadata {
+		if k == labelNameKey || k == labelServiceName || k == labelManagedBy {
+			t.Fatalf("did not expect label %s in the metadata", k)
+		}
+	}
+}
+
+func TestEndpointSlicesNamespaces(t *testing.T) {
+	c := mock.NewClient()
+
+	updateSlice(t, c, testSlice("a", "foo-1", "foo", "10.0.0.1"))
+	updateSlice(t, c, testSlice("b", "foo-1", "foo", "10.0.1.1"))
+	updateSlice(t, c, testSlice("c", "bar-1", "bar", "10.0.2.1"))
+
+	r := setupSliceRegistry(c, "a", "b")
+
+	services, err := r.ListServices()
+	if err != nil {
+		t.Fatalf("did not expect ListServices to fail %v", err)
+	}
+
+	// the nodes of the namespaces are merged
+	if len(services) != 1 || services[0].Name != "foo" {
+		t.Fatalf("expected the foo service only got %v", services)
+	}
+
+	if addrs := nodeAddresses(services[0].Nodes); !reflect.DeepEqual(addrs, []string{"10.0.0.1:8080", "10.0.1.1:8080"}) {
+		t.Fatalf("unexpected nodes %v", addrs)
+	}
+
+	r = setupSliceRegistry(c, "a", AllNamespaces)
+
+	services, err = r.ListServices()
+	if err != nil {
+		t.Fatalf("did not expect ListServices to fail %v", err)
+	}
+
+	if len(services) != 2 {
+		t.Fatalf("expected 2 services got %d", len(services))
+	}
+}
+
+func TestEndpointSlicesOptions(t *testing.T) {
+	r := NewRegistry(
+		registry.Addrs("http://127.0.0.1:8001"),
+		EndpointSlices(),
+		Namespaces("a", "b"),
+	)
+
+	k, ok := r.(*kregistry)
+	if !ok {
+		t.Fatal("expected a kubernetes registry")
+	}
+
+	if !k.slices {
+		t.Fatal("expected the endpoint slices to be discovered")
+	}
+
+	if !reflect.DeepEqual(k.namespaces, []string{"a", "b"}) {
+		t.Fatalf("unexpected namespaces %v", k.namespaces)
+	}
+}
+
+func TestEndpointSlicesRegister(t *testing.T) {
+	c := mock.NewClient()
+	r := setupSliceRegistry(c)
+
+	// the pod isn't patched, and its name isn't needed
+	t.Setenv("HOSTNAME", "")
+
+	svc := &registry.Service{
+		Name:  "foo",
+		Nodes: []*registry.Node{{Id: "foo-1", Address: "10.0.0.1:8080"}},
+	}
+
+	if err := r.Register(svc); err != nil {
+		t.Fatalf("did not expect Register to fail %v", err)
+	}
+
+	if err := r.Deregister(svc); err != nil {
+		t.Fatalf("did not expect Deregister to fail %v", err)
+	}
+}
+
+func TestEndpointSlicesWatcher(t *testing.T) {
+	c := mock.NewClient()
+	defer mock.Teardown(c)
+
+	// known before watching, not sent
+	updateSlice(t, c, testSlice("default", "bar-1", "bar", "10.0.1.1"))
+
+	r := setupSliceRegistry(c)
+
+	w, err := r.Watch()
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+	defer w.Stop()
+
+	updateSlice(t, c, testSlice("default", "foo-1", "foo", "10.0.0.1"))
+	expectResult(t, w, "create", "foo", "10.0.0.1:8080")
+
+	// an endpoint is added
+	updateSlice(t, c, testSlice("default", "foo-1", "foo", "10.0.0.1", "10.0.0.2"))
+	expectResult(t, w, "update", "foo", "10.0.0.2:8080")
+
+	// an endpoint isn't ready anymore
+	slice := testSlice("default", "foo-1", "foo", "10.0.0.1", "10.0.0.2")
+	slice.Endpoints[0].Conditions.Ready = boolPtr(false)
+	updateSlice(t, c, slice)
+	expectResult(t, w, "delete", "foo", "10.0.0.1:8080")
+
+	if err := c.DeleteEndpointSlice("default", "foo-1"); err != nil {
+		t.Fatal(err)
+	}
+	expectResult(t, w, "delete", "foo", "10.0.0.2:8080")
+
+	if err := c.DeleteEndpointSlice("default", "bar-1"); err != nil {
+		t.Fatal(err)
+	}
+	expectResult(t, w, "delete", "bar", "10.0.1.1:8080")
+}
+
+func TestEndpointSlicesWatchService(t *testing.T) {
+	c := mock.NewClient()
+	defer mock.Teardown(c)
+
+	r := setupSliceRegistry(c, "a", "b")
+
+	w, err := r.Watch(registry.WatchService("foo"))
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+	defer w.Stop()
+
+	// other services and namespaces aren't sent
+	updateSlice(t, c, testSlice("a", "bar-1", "bar", "10.0.1.1"))
+	updateSlice(t, c, testSlice("c", "foo-1", "foo", "10.0.2.1"))
+
+	updateSlice(t, c, testSlice("b", "foo-1", "foo", "10.0.0.1"))
+	expectResult(t, w, "create", "foo", "10.0.0.1:8080")
+
+	w.Stop()
+
+	done := make(chan error)
+	go func() {
+		_, err := w.Next()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected Next to fail once stopped")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Next to return once stopped")
+	}
+}
+
+func expectResult(t *testing.T, w registry.Watcher, action, name, addr string) {
+	t.Helper()
+
+	res, err := w.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Action != action {
+		t.Fatalf("expected %s event got %s", action, res.Action)
+	}
+
+	if res.Service.Name != name {
+		t.Fatalf("expected service %s got %s", name, res.Service.Name)
+	}
+
+	if addrs := nodeAddresses(res.Service.Nodes); !reflect.DeepEqual(addrs, []string{addr}) {
+		t.Fatalf("expected node %s got %v", addr, addrs)
+	}
+}
+
+func setupSliceRegistry(c client.Kubernetes, namespaces ...string) registry.Registry {
+	return &kregistry{
+		client:     c,
+		timeout:    time.Second * 1,
+		slices:     true,
+		namespaces: namespaces,
+	}
+}
+
+func updateSlice(t *testing.T, c *mock.Client, slice *client.EndpointSlice) {
+	t.Helper()
+
+	if err := c.UpdateEndpointSlice(slice); err != nil {
+		t.Fatalf("did not expect UpdateEndpointSlice to fail: %v", err)
+	}
+}
+
+// testSlice returns the slice of a service labelled as a micro service,
+// with a ready endpoint per address.
+func testSlice(namespace, name, service string, addrs ...string) *client.EndpointSlice {
+	slice := &client.EndpointSlice{
+		Metadata: &client.Meta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]*string{
+				labelTypeKey:     strPtr(labelTypeValueService),
+				labelServiceName: strPtr(service),
+				labelManagedBy:   strPtr("endpointslice-controller.k8s.io"),
+				labelVersionKey:  strPtr("1.0.0"),
+				"app":            strPtr(service),
+			},
+		},
+		AddressType: "IPv4",
+		Ports: []client.EndpointPort{
+			{Name: "grpc", Protocol: "TCP", Port: intPtr(8080)},
+			{Name: "metrics", Protocol: "TCP", Port: intPtr(9090)},
+		},
+	}
+
+	for _, addr := range addrs {
+		slice.Endpoints = append(slice.Endpoints, client.Endpoint{
+			Addresses:  []string{addr},
+			Conditions: client.EndpointConditions{Ready: boolPtr(true)},
+			NodeName:   "node-1",
+			Zone:       "zone-a",
+			TargetRef:  &client.ObjectReference{Kind: "Pod", Namespace: namespace, Name: service + "-" + addr},
+		})
+	}
+
+	return slice
+}
+
+func nodeAddresses(nodes []*registry.Node) []string {
+	addrs := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		addrs = append(addrs, n.Address)
+	}
+
+	sort.Strings(addrs)
+
+	return addrs
+}
+
+func nodeByAddress(nodes []*registry.Node, addr string) *registry.Node {
+	for _, n := range nodes {
+		if n.Address == addr {
+			return n
+		}
+	}
+
+	return nil
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
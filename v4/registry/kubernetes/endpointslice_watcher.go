@@ -0,0 +1,239 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+
+	"github.com/go-micro/plugins/v4/registry/kubernetes/client"
+	"github.com/go-micro/plugins/v4/registry/kubernetes/client/watch"
+)
+
+type sliceWatcher struct {
+	registry *kregistry
+	service  string
+	watchers []watch.Watch
+	next     chan *registry.Result
+	exit     chan bool
+	once     sync.Once
+
+	sync.Mutex
+	// services of the endpoint slices by namespace and name
+	slices map[string]*registry.Service
+}
+
+// build a cache of the services of the slices when the watcher starts.
+func (k *sliceWatcher) updateCache() error {
+	slices, err := k.registry.listSlices()
+	if err != nil {
+		return err
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	for i := range slices {
+		if svc := k.sliceService(&slices[i]); svc != nil {
+			k.slices[sliceKey(&slices[i])] = svc
+		}
+	}
+
+	return nil
+}
+
+// sliceService returns the watched service of the slice, nil if it has no
+// nodes or it's another service.
+func (k *sliceWatcher) sliceService(slice *client.EndpointSlice) *registry.Service {
+	svc := sliceService(slice)
+	if svc == nil || len(svc.Nodes) == 0 {
+		return nil
+	}
+
+	if len(k.service) > 0 && svc.Name != k.service {
+		return nil
+	}
+
+	return svc
+}
+
+// handleEvent compares the endpoints of a slice from the k8s endpoint slices
+// API against the cache, and sends the nodes added, updated and removed.
+func (k *sliceWatcher) handleEvent(event watch.Event) {
+	var slice client.EndpointSlice
+	if err := json.Unmarshal([]byte(event.Object), &slice); err != nil {
+		logger.Error("K8s Watcher: Couldnt unmarshal event object from endpoint slice")
+		return
+	}
+
+	if slice.Metadata == nil {
+		return
+	}
+
+	var svc *registry.Service
+
+	//nolint:exhaustive
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		svc = k.sliceService(&slice)
+	case watch.Deleted:
+	default:
+		return
+	}
+
+	key := sliceKey(&slice)
+
+	k.Lock()
+	defer k.Unlock()
+
+	results := sliceResults(svc, k.slices[key])
+
+	if svc != nil {
+		k.slices[key] = svc
+	} else {
+		delete(k.slices, key)
+	}
+
+	for _, result := range results {
+		select {
+		case k.next <- result:
+		case <-k.exit:
+			return
+		}
+	}
+}
+
+// Next will block until a new result comes in.
+func (k *sliceWatcher) Next() (*registry.Result, error) {
+	select {
+	case r := <-k.next:
+		return r, nil
+	case <-k.exit:
+		return nil, errors.New("result chan closed")
+	}
+}
+
+// Stop will cancel the requests, and unblock Next.
+func (k *sliceWatcher) Stop() {
+	k.once.Do(func() {
+		close(k.exit)
+
+		for _, w := range k.watchers {
+			w.Stop()
+		}
+	})
+}
+
+func newSliceWatcher(kr *kregistry, opts ...registry.WatchOption) (registry.Watcher, error) {
+	var wo registry.WatchOptions
+	for _, o := range opts {
+		o(&wo)
+	}
+
+	k := &sliceWatcher{
+		registry: kr,
+		service:  wo.Service,
+		next:     make(chan *registry.Result),
+		exit:     make(chan bool),
+		slices:   make(map[string]*registry.Service),
+	}
+
+	// Create a watch request per namespace
+	for _, ns := range kr.sliceNamespaces() {
+		w, err := kr.client.WatchEndpointSlices(ns, podSelector)
+		if err != nil {
+			k.Stop()
+			return nil, err
+		}
+
+		k.watchers = append(k.watchers, w)
+	}
+
+	// update cache, but dont emit changes
+	if err := k.updateCache(); err != nil {
+		k.Stop()
+		return nil, err
+	}
+
+	// range over watch request changes, and invoke
+	// the update event
+	for _, w := range k.watchers {
+		go func(w watch.Watch) {
+			for event := range w.ResultChan() {
+				k.handleEvent(event)
+			}
+
+			k.Stop()
+		}(w)
+	}
+
+	return k, nil
+}
+
+func sliceKey(slice *client.EndpointSlice) string {
+	return slice.Metadata.Namespace + "/" + slice.Metadata.Name
+}
+
+// sliceResults returns the results changing the service of a slice from
+// the cached one: the nodes removed are deleted, then the nodes added or
+// changed are created or updated.
+func sliceResults(svc, cache *registry.Service) []*registry.Result {
+	var results []*registry.Result
+
+	// the slice moved to another service or version
+	if svc != nil && cache != nil && (svc.Name != cache.Name || svc.Version != cache.Version) {
+		results = append(results, &registry.Result{Action: deleteAction, Service: cache})
+		cache = nil
+	}
+
+	if cache != nil {
+		if removed := diffNodes(cache, svc); len(removed) > 0 {
+			results = append(results, &registry.Result{Action: deleteAction, Service: withNodes(cache, removed)})
+		}
+	}
+
+	if svc != nil {
+		action := "update"
+		if cache == nil {
+			action = "create"
+		}
+
+		if changed := diffNodes(svc, cache); len(changed) > 0 {
+			results = append(results, &registry.Result{Action: action, Service: withNodes(svc, changed)})
+		}
+	}
+
+	return results
+}
+
+// diffNodes returns the nodes of a not in b, or different in b.
+func diffNodes(a, b *registry.Service) []*registry.Node {
+	nodes := make(map[string]*registry.Node)
+	if b != nil {
+		for _, n := range b.Nodes {
+			nodes[n.Id] = n
+		}
+	}
+
+	var diff []*registry.Node
+
+	for _, n := range a.Nodes {
+		if bn, ok := nodes[n.Id]; ok && reflect.DeepEqual(n, bn) {
+			continue
+		}
+
+		diff = append(diff, n)
+	}
+
+	return diff
+}
+
+func withNodes(svc *registry.Service, nodes []*registry.Node) *registry.Service {
+	s := *svc
+	s.Nodes = nodes
+
+	return &s
+}
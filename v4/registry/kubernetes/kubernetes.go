@@ -21,6 +21,11 @@ type kregistry struct {
 	client  client.Kubernetes
 	timeout time.Duration
 	options registry.Options
+
+	// discover the services from the endpoint slices
+	// of the namespaces, see EndpointSlices.
+	slices     bool
+	namespaces []string
 }
 
 var (
@@ -84,6 +89,11 @@ func configure(k *kregistry, opts ...registry.Option) error {
 	k.client = c
 	k.timeout = k.options.Timeout
 
+	if ctx := k.options.Context; ctx != nil {
+		k.slices, _ = ctx.Value(endpointSlicesKey{}).(bool)
+		k.namespaces, _ = ctx.Value(namespacesKey{}).([]string)
+	}
+
 	return nil
 }
 
@@ -114,12 +124,17 @@ func (c *kregistry) Options() registry.Options {
 }
 
 // Register sets a service selector label and an annotation with a
-// serialized version of the service passed in.
+// serialized version of the service passed in. It does nothing when
+// discovering the services from the endpoint slices.
 func (c *kregistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
 	if len(s.Nodes) == 0 {
 		return ErrNoNodesFound
 	}
 
+	if c.slices {
+		return nil
+	}
+
 	svcName := s.Name
 
 	// TODO: grab podname from somewhere better than this.
@@ -161,6 +176,10 @@ func (c *kregistry) Deregister(s *registry.Service, opts ...registry.DeregisterO
 		return ErrNoNodesFound
 	}
 
+	if c.slices {
+		return nil
+	}
+
 	svcName := s.Name
 
 	// TODO: grab podname from somewhere better than env var.
@@ -190,6 +209,10 @@ func (c *kregistry) Deregister(s *registry.Service, opts ...registry.DeregisterO
 // GetService will get all the pods with the given service selector,
 // and build services from the annotations.
 func (c *kregistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	if c.slices {
+		return c.getSliceService(name)
+	}
+
 	pods, err := c.client.ListPods(map[string]string{
 		svcSelectorPrefix + serviceName(name): svcSelectorValue,
 	})
@@ -243,6 +266,10 @@ func (c *kregistry) GetService(name string, opts ...registry.GetOption) ([]*regi
 
 // ListServices will list all the service names.
 func (c *kregistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	if c.slices {
+		return c.listSliceServices()
+	}
+
 	pods, err := c.client.ListPods(podSelector)
 	if err != nil {
 		return nil, err
@@ -292,6 +319,10 @@ func (c *kregistry) ListServices(opts ...registry.ListOption) ([]*registry.Servi
 
 // Watch returns a kubernetes watcher.
 func (c *kregistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	if c.slices {
+		return newSliceWatcher(c, opts...)
+	}
+
 	return newWatcher(c, opts...)
 }
 
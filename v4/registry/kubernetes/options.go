@@ -0,0 +1,42 @@
+package kubernetes
+
+import (
+	"context"
+
+	"go-micro.dev/v4/registry"
+
+	"github.com/go-micro/plugins/v4/registry/kubernetes/client"
+)
+
+// AllNamespaces discovers the services of every namespace, see Namespaces.
+const AllNamespaces = client.AllNamespaces
+
+type endpointSlicesKey struct{}
+
+type namespacesKey struct{}
+
+// EndpointSlices discovers the services from the endpoint slices of the
+// Kubernetes services labelled micro.mu/type=service, instead of the
+// annotations patched onto the pods. Kubernetes keeps the endpoints of the
+// services up to date, so Register and Deregister do nothing and the pods
+// don't need the permission to patch themselves.
+func EndpointSlices() registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, endpointSlicesKey{}, true)
+	}
+}
+
+// Namespaces sets the namespaces the endpoint slices are discovered in, the
+// namespace of the pod by default. Use AllNamespaces to discover the
+// services of the whole cluster.
+func Namespaces(namespaces ...string) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, namespacesKey{}, namespaces)
+	}
+}
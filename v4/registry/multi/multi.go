@@ -2,16 +2,38 @@ package multi
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
 
 	log "go-micro.dev/v4/logger"
 	"go-micro.dev/v4/registry"
 )
 
+// Error is returned when every registry failed.
+type Error []error
+
+func (e Error) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type source struct {
+	r        registry.Registry
+	priority int
+}
+
 type multiRegistry struct {
-	r    []registry.Registry
+	// read registries by priority
+	r    []source
 	w    []registry.Registry
 	opts registry.Options
+
+	fallback     bool
+	errorHandler ErrorHandler
 }
 
 func (m *multiRegistry) Init(opts ...registry.Option) error {
@@ -86,7 +108,7 @@ func (m *multiRegistry) Deregister(s *registry.Service, opts ...registry.Deregis
 
 	for _, mw := range m.w {
 		go func(w registry.Registry) {
-			if err := w.Deregister(s); err != nil {
+			if err := w.Deregister(s, opts...); err != nil {
 				cerr <- err
 			} else {
 				wg.Done()
@@ -105,115 +127,163 @@ func (m *multiRegistry) Deregister(s *registry.Service, opts ...registry.Deregis
 }
 
 func (m *multiRegistry) GetService(n string, opts ...registry.GetOption) ([]*registry.Service, error) {
-	var wg sync.WaitGroup
-	var errs []error
-	var svcs []*registry.Service
-	var mu sync.Mutex
+	svcs, err := m.query(func(r registry.Registry) ([]*registry.Service, error) {
+		svc, err := r.GetService(n, opts...)
+		if err == registry.ErrNotFound {
+			return nil, nil
+		}
+		return svc, err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	done := make(chan bool)
-	cerr := make(chan error)
-	csvc := make(chan []*registry.Service)
+	if len(svcs) == 0 {
+		return nil, registry.ErrNotFound
+	}
 
-	wg.Add(len(m.r))
+	return svcs, nil
+}
 
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case err := <-cerr:
-				errs = append(errs, err)
-				wg.Done()
-			case svc := <-csvc:
-				mu.Lock()
-				svcs = append(svcs, svc...)
-				mu.Unlock()
-				wg.Done()
+func (m *multiRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	return m.query(func(r registry.Registry) ([]*registry.Service, error) {
+		return r.ListServices(opts...)
+	})
+}
+
+// query the read registries and merge their services. In fallback mode
+// the registries are queried by priority until services are found. An
+// error is only returned when every registry failed, the failures of the
+// others are passed to the error handler.
+func (m *multiRegistry) query(fn func(registry.Registry) ([]*registry.Service, error)) ([]*registry.Service, error) {
+	tiers := [][]source{m.r}
+	if m.fallback {
+		tiers = nil
+		for i := 0; i < len(m.r); {
+			// the registries of the same priority
+			j := i + 1
+			for j < len(m.r) && m.r[j].priority == m.r[i].priority {
+				j++
 			}
+			tiers = append(tiers, m.r[i:j])
+			i = j
 		}
-	}()
+	}
 
-	for _, mr := range m.r {
-		go func(r registry.Registry) {
-			svc, err := r.GetService(n)
-			if err != nil && err != registry.ErrNotFound {
-				cerr <- err
-			} else {
-				csvc <- svc
+	var (
+		svcs    []*registry.Service
+		failed  []source
+		errs    Error
+		queried int
+	)
+
+	for _, tier := range tiers {
+		res, terrs := queryAll(tier, fn)
+		queried += len(tier)
+
+		for i, err := range terrs {
+			if err != nil {
+				failed = append(failed, tier[i])
+				errs = append(errs, err)
 			}
-		}(mr)
-	}
+		}
 
-	wg.Wait()
-	defer close(done)
+		if svcs = merge(res); len(svcs) > 0 {
+			break
+		}
+	}
 
-	if len(errs) > 0 {
-		return nil, errs[0]
+	if len(errs) > 0 && len(errs) == queried {
+		return nil, errs
 	}
 
-	mu.Lock()
-	if len(svcs) == 0 {
-		return nil, registry.ErrNotFound
+	for i, src := range failed {
+		m.errorHandler(src.r, errs[i])
 	}
-	mu.Unlock()
 
 	return svcs, nil
 }
 
-func (m *multiRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+// queryAll queries the registries concurrently, returning the services
+// and error of each registry in order.
+func queryAll(srcs []source, fn func(registry.Registry) ([]*registry.Service, error)) ([][]*registry.Service, []error) {
 	var wg sync.WaitGroup
-	var errs []error
-	var svcs []*registry.Service
-	var mu sync.Mutex
 
-	done := make(chan bool)
-	cerr := make(chan error)
-	csvc := make(chan []*registry.Service)
+	res := make([][]*registry.Service, len(srcs))
+	errs := make([]error, len(srcs))
 
-	wg.Add(len(m.r))
+	wg.Add(len(srcs))
 
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case err := <-cerr:
-				errs = append(errs, err)
-				wg.Done()
-			case svc := <-csvc:
-				mu.Lock()
-				svcs = append(svcs, svc...)
-				mu.Unlock()
-				wg.Done()
+	for i, src := range srcs {
+		go func(i int, r registry.Registry) {
+			defer wg.Done()
+			res[i], errs[i] = fn(r)
+		}(i, src.r)
+	}
+
+	wg.Wait()
+
+	return res, errs
+}
+
+// merge the services of registries in priority order. Services are merged
+// by name and version and their nodes by id, the first registry wins.
+func merge(res [][]*registry.Service) []*registry.Service {
+	var svcs []*registry.Service
+	seen := make(map[string]*registry.Service)
+	nodes := make(map[string]map[string]bool)
+
+	for _, rsvcs := range res {
+		for _, svc := range rsvcs {
+			if svc == nil {
+				continue
 			}
-		}
-	}()
 
-	for _, mr := range m.r {
-		go func(r registry.Registry) {
-			if svc, err := r.ListServices(); err != nil {
-				cerr <- err
-			} else {
-				csvc <- svc
+			k := svc.Name + ":" + svc.Version
+
+			s, ok := seen[k]
+			if !ok {
+				s = &registry.Service{
+					Name:      svc.Name,
+					Version:   svc.Version,
+					Metadata:  make(map[string]string),
+					Endpoints: svc.Endpoints,
+				}
+				seen[k] = s
+				nodes[k] = make(map[string]bool)
+				svcs = append(svcs, s)
 			}
-		}(mr)
-	}
 
-	wg.Wait()
-	defer close(done)
+			if len(s.Endpoints) == 0 {
+				s.Endpoints = svc.Endpoints
+			}
 
-	if len(errs) > 0 {
-		return nil, errs[0]
+			for mk, mv := range svc.Metadata {
+				if _, ok := s.Metadata[mk]; !ok {
+					s.Metadata[mk] = mv
+				}
+			}
+
+			for _, n := range svc.Nodes {
+				if n == nil || nodes[k][n.Id] {
+					continue
+				}
+				nodes[k][n.Id] = true
+				s.Nodes = append(s.Nodes, n)
+			}
+		}
 	}
 
-	mu.Lock()
-	ret := svcs
-	mu.Unlock()
-	return ret, nil
+	return svcs
 }
 
 func (m *multiRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
-	return newMultiWatcher(m.r, opts...)
+	r := make([]registry.Registry, 0, len(m.r))
+	for _, src := range m.r {
+		r = append(r, src.r)
+	}
+
+	return newMultiWatcher(r, opts...)
 }
 
 func (m *multiRegistry) String() string {
@@ -244,10 +314,55 @@ func configure(m *multiRegistry, opts ...registry.Option) error {
 		m.w = w
 	}
 
-	m.r = m.w
+	var srcs []source
+	for _, w := range m.w {
+		srcs = append(srcs, source{r: w})
+	}
 
 	if r, ok := m.opts.Context.Value(readKey{}).([]registry.Registry); ok && r != nil {
-		m.r = append(m.r, r...)
+		for _, rr := range r {
+			srcs = append(srcs, source{r: rr})
+		}
 	}
+
+	if p, ok := m.opts.Context.Value(priorityKey{}).([]priorityRegistries); ok {
+		for _, pr := range p {
+			for _, rr := range pr.registries {
+				srcs = append(srcs, source{r: rr, priority: pr.priority})
+			}
+		}
+	}
+
+	// a registry added more than once is queried once with its highest priority
+	m.r = nil
+	for _, src := range srcs {
+		dup := false
+		for i, s := range m.r {
+			if s.r == src.r {
+				if src.priority > s.priority {
+					m.r[i].priority = src.priority
+				}
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			m.r = append(m.r, src)
+		}
+	}
+
+	sort.SliceStable(m.r, func(i, j int) bool {
+		return m.r[i].priority > m.r[j].priority
+	})
+
+	m.fallback, _ = m.opts.Context.Value(fallbackKey{}).(bool)
+
+	m.errorHandler, _ = m.opts.Context.Value(errorHandlerKey{}).(ErrorHandler)
+	if m.errorHandler == nil {
+		m.errorHandler = func(r registry.Registry, err error) {
+			log.Warnf("[multi] Error querying %s registry: %v", r.String(), err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,255 @@
+package multi
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"go-micro.dev/v4/registry"
+)
+
+var errDown = errors.New("registry down")
+
+// failingRegistry fails every read.
+type failingRegistry struct {
+	registry.Registry
+}
+
+func (f *failingRegistry) GetService(string, ...registry.GetOption) ([]*registry.Service, error) {
+	return nil, errDown
+}
+
+func (f *failingRegistry) ListServices(...registry.ListOption) ([]*registry.Service, error) {
+	return nil, errDown
+}
+
+func (f *failingRegistry) String() string {
+	return "failing"
+}
+
+func newRegistry(t *testing.T, svcs ...*registry.Service) registry.Registry {
+	r := registry.NewMemoryRegistry()
+	for _, s := range svcs {
+		if err := r.Register(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func service(name, version string, metadata map[string]string, nodes ...string) *registry.Service {
+	s := &registry.Service{Name: name, Version: version, Metadata: metadata}
+	for _, n := range nodes {
+		s.Nodes = append(s.Nodes, &registry.Node{Id: n, Address: n + ":8080"})
+	}
+	return s
+}
+
+func nodeIDs(s *registry.Service) []string {
+	var ids []string
+	for _, n := range s.Nodes {
+		ids = append(ids, n.Id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestGetServiceMerge(t *testing.T) {
+	low := newRegistry(t,
+		service("foo", "1.0", map[string]string{"region": "low", "zone": "a"}, "n1", "n2"),
+		service("foo", "2.0", nil, "n4"),
+	)
+	high := newRegistry(t,
+		service("foo", "1.0", map[string]string{"region": "high"}, "n2", "n3"),
+	)
+
+	m := NewRegistry(ReadRegistry(low), PriorityRegistry(10, high))
+
+	svcs, err := m.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(svcs))
+	}
+
+	var v1 *registry.Service
+	for _, s := range svcs {
+		if s.Version == "1.0" {
+			v1 = s
+		}
+	}
+	if v1 == nil {
+		t.Fatal("version 1.0 not found")
+	}
+
+	if ids := nodeIDs(v1); len(ids) != 3 || ids[0] != "n1" || ids[1] != "n2" || ids[2] != "n3" {
+		t.Fatalf("expected nodes n1, n2 and n3, got %v", ids)
+	}
+	if v1.Metadata["region"] != "high" {
+		t.Fatalf("expected metadata of the high priority registry, got %v", v1.Metadata["region"])
+	}
+	if v1.Metadata["zone"] != "a" {
+		t.Fatalf("expected metadata missing from the high priority registry to be merged, got %v", v1.Metadata)
+	}
+
+	if _, err := m.GetService("bar"); err != registry.ErrNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestListServicesDedup(t *testing.T) {
+	a := newRegistry(t, service("foo", "1.0", nil, "n1"), service("bar", "1.0", nil, "n2"))
+	b := newRegistry(t, service("foo", "1.0", nil, "n1"))
+
+	// a registry added as writer and reader is only queried once
+	m := NewRegistry(WriteRegistry(a), ReadRegistry(a, b))
+
+	svcs, err := m.ListServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, s := range svcs {
+		names = append(names, s.Name+":"+s.Version)
+	}
+	sort.Strings(names)
+
+	if len(names) != 2 || names[0] != "bar:1.0" || names[1] != "foo:1.0" {
+		t.Fatalf("expected bar:1.0 and foo:1.0, got %v", names)
+	}
+}
+
+func TestPartialFailure(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		failed []error
+	)
+
+	ok := newRegistry(t, service("foo", "1.0", nil, "n1"))
+	down := &failingRegistry{}
+
+	m := NewRegistry(
+		ReadRegistry(ok, down),
+		WithErrorHandler(func(r registry.Registry, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, err)
+		}),
+	)
+
+	svcs, err := m.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs) != 1 || len(svcs[0].Nodes) != 1 {
+		t.Fatalf("unexpected services %v", svcs)
+	}
+	if len(failed) != 1 || failed[0] != errDown {
+		t.Fatalf("expected the failure to be reported, got %v", failed)
+	}
+
+	// every registry failing is an error
+	m = NewRegistry(ReadRegistry(down))
+	if _, err := m.ListServices(); err == nil {
+		t.Fatal("expected an error")
+	} else if e, ok := err.(Error); !ok || len(e) != 1 {
+		t.Fatalf("expected the registry errors, got %v", err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	primary := newRegistry(t, service("foo", "1.0", nil, "n1"))
+	secondary := newRegistry(t, service("foo", "1.0", nil, "n2"), service("bar", "1.0", nil, "n3"))
+
+	var reported []registry.Registry
+
+	m := NewRegistry(
+		PriorityRegistry(1, primary),
+		PriorityRegistry(0, secondary),
+		Fallback(),
+		WithErrorHandler(func(r registry.Registry, err error) {
+			reported = append(reported, r)
+		}),
+	)
+
+	// found in the primary, the secondary isn't merged
+	svcs, err := m.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := nodeIDs(svcs[0]); len(ids) != 1 || ids[0] != "n1" {
+		t.Fatalf("expected the primary nodes, got %v", ids)
+	}
+
+	// falls back to the secondary
+	svcs, err = m.GetService("bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := nodeIDs(svcs[0]); len(ids) != 1 || ids[0] != "n3" {
+		t.Fatalf("expected the secondary nodes, got %v", ids)
+	}
+
+	// falls back when the primary fails
+	down := &failingRegistry{}
+	m = NewRegistry(
+		PriorityRegistry(1, down),
+		PriorityRegistry(0, secondary),
+		Fallback(),
+		WithErrorHandler(func(r registry.Registry, err error) {
+			reported = append(reported, r)
+		}),
+	)
+
+	svcs, err = m.GetService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := nodeIDs(svcs[0]); len(ids) != 1 || ids[0] != "n2" {
+		t.Fatalf("expected the secondary nodes, got %v", ids)
+	}
+	if len(reported) != 1 || reported[0] != down {
+		t.Fatalf("expected the primary failure to be reported, got %v", reported)
+	}
+}
+
+// recordingRegistry records the options of the reads.
+type recordingRegistry struct {
+	registry.Registry
+	get  []registry.GetOption
+	list []registry.ListOption
+}
+
+func (r *recordingRegistry) GetService(n string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	r.get = opts
+	return r.Registry.GetService(n, opts...)
+}
+
+func (r *recordingRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	r.list = opts
+	return r.Registry.ListServices(opts...)
+}
+
+func TestOptionsPassedThrough(t *testing.T) {
+	r := &recordingRegistry{Registry: newRegistry(t, service("foo", "1.0", nil, "n1"))}
+	m := NewRegistry(ReadRegistry(r))
+
+	get := func(o *registry.GetOptions) {}
+	if _, err := m.GetService("foo", get); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.get) != 1 {
+		t.Fatalf("expected the get option to be passed, got %d", len(r.get))
+	}
+
+	list := func(o *registry.ListOptions) {}
+	if _, err := m.ListServices(list); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.list) != 1 {
+		t.Fatalf("expected the list option to be passed, got %d", len(r.list))
+	}
+}
@@ -28,3 +28,42 @@ func WriteRegistry(w ...registry.Registry) registry.Option {
 func ReadRegistry(r ...registry.Registry) registry.Option {
 	return setRegistryOption(readKey{}, r)
 }
+
+type priorityKey struct{}
+type fallbackKey struct{}
+type errorHandlerKey struct{}
+
+// ErrorHandler is called with the error of a registry that failed while
+// the others succeeded.
+type ErrorHandler func(r registry.Registry, err error)
+
+type priorityRegistries struct {
+	priority   int
+	registries []registry.Registry
+}
+
+// PriorityRegistry adds read registries with a priority, registries of a
+// higher priority are preferred when merging and queried first in fallback
+// mode. Registries added with WriteRegistry and ReadRegistry have priority 0.
+func PriorityRegistry(priority int, r ...registry.Registry) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		p, _ := o.Context.Value(priorityKey{}).([]priorityRegistries)
+		p = append(p, priorityRegistries{priority, r})
+		o.Context = context.WithValue(o.Context, priorityKey{}, p)
+	}
+}
+
+// Fallback only queries registries of a lower priority when the registries
+// of a higher priority fail or don't have the service.
+func Fallback() registry.Option {
+	return setRegistryOption(fallbackKey{}, true)
+}
+
+// WithErrorHandler sets the handler of registries failing while others
+// succeeded, by default the error is logged.
+func WithErrorHandler(h ErrorHandler) registry.Option {
+	return setRegistryOption(errorHandlerKey{}, h)
+}
@@ -2,27 +2,55 @@
 package dns
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
 	"net"
 	"strconv"
+	"sync"
+	"time"
 
 	"go-micro.dev/v4/registry"
 	"go-micro.dev/v4/selector"
 )
 
 type dnsSelector struct {
-	options selector.Options
-	domain  string
+	options  selector.Options
+	domain   string
+	resolver Resolver
+	hostOnly bool
+	port     int
+	eject    time.Duration
+
+	sync.RWMutex
+	// cached nodes by service
+	cache map[string]*record
+	// ejected nodes by service and node id
+	ejected map[string]map[string]time.Time
+}
+
+type record struct {
+	nodes   []*registry.Node
+	expires time.Time
 }
 
 var (
 	DefaultDomain = "local"
+	// DefaultHostPort is the port of the nodes in host only mode.
+	DefaultHostPort = 8080
+	// DefaultTTL is how long records without a ttl are cached.
+	DefaultTTL = 30 * time.Second
+	// DefaultEjectTimeout is how long a failing node isn't selected.
+	DefaultEjectTimeout = 30 * time.Second
+	// DefaultLookupTimeout bounds a lookup.
+	DefaultLookupTimeout = 5 * time.Second
 )
 
 func (d *dnsSelector) Init(opts ...selector.Option) error {
 	for _, o := range opts {
 		o(&d.options)
 	}
+	d.configure()
 	return nil
 }
 
@@ -31,27 +59,137 @@ func (d *dnsSelector) Options() selector.Options {
 }
 
 func (d *dnsSelector) Select(service string, opts ...selector.SelectOption) (selector.Next, error) {
+	nodes, err := d.nodes(service)
+	if err != nil {
+		return nil, err
+	}
+
+	services := []*registry.Service{
+		{
+			Name:  service,
+			Nodes: d.available(service, nodes),
+		},
+	}
+
+	sopts := selector.SelectOptions{
+		Strategy: d.options.Strategy,
+	}
+
+	for _, opt := range opts {
+		opt(&sopts)
+	}
+
+	// apply the filters
+	for _, filter := range sopts.Filters {
+		services = filter(services)
+	}
+
+	// if there's nothing left, return
+	if len(services) == 0 {
+		return nil, selector.ErrNoneAvailable
+	}
+
+	return sopts.Strategy(services), nil
+}
+
+// Mark ejects a failing node for the eject timeout, marking it without an
+// error makes it available again.
+func (d *dnsSelector) Mark(service string, node *registry.Node, err error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if err == nil {
+		delete(d.ejected[service], node.Id)
+		return
+	}
+
+	if d.ejected[service] == nil {
+		d.ejected[service] = make(map[string]time.Time)
+	}
+	d.ejected[service][node.Id] = time.Now().Add(d.eject)
+}
+
+// Reset drops the cached records and ejected nodes of the service.
+func (d *dnsSelector) Reset(service string) {
+	d.Lock()
+	defer d.Unlock()
+
+	delete(d.cache, service)
+	delete(d.ejected, service)
+}
+
+func (d *dnsSelector) Close() error {
+	return nil
+}
+
+func (d *dnsSelector) String() string {
+	return "dns"
+}
+
+// nodes returns the cached nodes of the service, looking them up when the
+// records expired. Expired records are used when the lookup fails.
+func (d *dnsSelector) nodes(service string) ([]*registry.Node, error) {
+	d.RLock()
+	rec, ok := d.cache[service]
+	d.RUnlock()
+
+	if ok && time.Now().Before(rec.expires) {
+		return rec.nodes, nil
+	}
+
+	nodes, ttl, err := d.lookup(service)
+	if err != nil {
+		if ok {
+			return rec.nodes, nil
+		}
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	d.Lock()
+	d.cache[service] = &record{
+		nodes:   nodes,
+		expires: time.Now().Add(ttl),
+	}
+	d.Unlock()
+
+	return nodes, nil
+}
+
+func (d *dnsSelector) lookup(service string) ([]*registry.Node, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultLookupTimeout)
+	defer cancel()
+
 	var srv []*net.SRV
+	var ttl time.Duration
 
 	// check if its host:port
 	host, port, err := net.SplitHostPort(service)
 	// not host:port
-	if err != nil {
+	if err != nil && !d.hostOnly {
 		// lookup the SRV record
-		_, srvs, err := net.LookupSRV(service, "tcp", d.domain)
+		srvs, t, err := d.resolver.LookupSRV(ctx, service, "tcp", d.domain)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		// set SRV records
-		srv = srvs
-		// got host:port
+		srv, ttl = srvs, t
+		// got host:port or host
 	} else {
-		p, _ := strconv.Atoi(port)
+		p := d.port
+		if err == nil {
+			p, _ = strconv.Atoi(port)
+		} else {
+			host = service
+		}
 
-		// lookup the A record
-		ips, err := net.LookupHost(host)
+		// lookup the A and AAAA records
+		ips, t, err := d.resolver.LookupHost(ctx, host)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
 		// create SRV records
@@ -61,64 +199,154 @@ func (d *dnsSelector) Select(service string, opts ...selector.SelectOption) (sel
 				Port:   uint16(p),
 			})
 		}
+		ttl = t
 	}
 
 	nodes := make([]*registry.Node, 0, len(srv))
 	for _, node := range srv {
+		address := net.JoinHostPort(node.Target, fmt.Sprint(node.Port))
 		nodes = append(nodes, &registry.Node{
-			Id:      node.Target,
-			Address: net.JoinHostPort(node.Target, fmt.Sprint(node.Port)),
+			Id:      address,
+			Address: address,
+			Metadata: map[string]string{
+				"priority": fmt.Sprint(node.Priority),
+				"weight":   fmt.Sprint(node.Weight),
+			},
 		})
 	}
 
-	services := []*registry.Service{
-		{
-			Name:  service,
-			Nodes: nodes,
-		},
+	return nodes, ttl, nil
+}
+
+// available returns the nodes which aren't ejected, or all of them when
+// every node is.
+func (d *dnsSelector) available(service string, nodes []*registry.Node) []*registry.Node {
+	d.Lock()
+	defer d.Unlock()
+
+	ejected := d.ejected[service]
+	if len(ejected) == 0 {
+		return nodes
 	}
 
-	sopts := selector.SelectOptions{
-		Strategy: d.options.Strategy,
+	now := time.Now()
+	res := make([]*registry.Node, 0, len(nodes))
+
+	for _, node := range nodes {
+		if until, ok := ejected[node.Id]; ok {
+			if now.Before(until) {
+				continue
+			}
+			delete(ejected, node.Id)
+		}
+		res = append(res, node)
 	}
 
-	for _, opt := range opts {
-		opt(&sopts)
+	if len(res) == 0 {
+		return nodes
 	}
 
-	// apply the filters
-	for _, filter := range sopts.Filters {
-		services = filter(services)
+	return res
+}
+
+// Weighted is a strategy choosing nodes of the lowest SRV priority, in
+// proportion to their weight.
+func Weighted(services []*registry.Service) selector.Next {
+	var nodes []*registry.Node
+	best := -1
+
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			p := metadataInt(node, "priority")
+			switch {
+			case best < 0 || p < best:
+				best = p
+				nodes = []*registry.Node{node}
+			case p == best:
+				nodes = append(nodes, node)
+			}
+		}
 	}
 
-	// if there's nothing left, return
-	if len(services) == 0 {
-		return nil, selector.ErrNoneAvailable
+	var total int
+	for _, node := range nodes {
+		total += metadataInt(node, "weight")
 	}
 
-	return sopts.Strategy(services), nil
-}
+	return func() (*registry.Node, error) {
+		if len(nodes) == 0 {
+			return nil, selector.ErrNoneAvailable
+		}
 
-func (d *dnsSelector) Mark(service string, node *registry.Node, err error) {}
+		// zero weights are chosen at random
+		if total == 0 {
+			return nodes[rand.Intn(len(nodes))], nil
+		}
 
-func (d *dnsSelector) Reset(service string) {}
+		n := rand.Intn(total)
+		for _, node := range nodes {
+			n -= metadataInt(node, "weight")
+			if n < 0 {
+				return node, nil
+			}
+		}
 
-func (d *dnsSelector) Close() error {
-	return nil
+		return nodes[len(nodes)-1], nil
+	}
 }
 
-func (d *dnsSelector) String() string {
-	return "dns"
+func metadataInt(node *registry.Node, key string) int {
+	v, _ := strconv.Atoi(node.Metadata[key])
+	return v
+}
+
+func (d *dnsSelector) configure() {
+	d.domain = DefaultDomain
+	d.port = DefaultHostPort
+	d.eject = DefaultEjectTimeout
+
+	ctx := d.options.Context
+	if ctx == nil {
+		return
+	}
+
+	if v, ok := ctx.Value(domainKey{}).(string); ok {
+		d.domain = v
+	}
+	if v, ok := ctx.Value(resolverKey{}).(Resolver); ok {
+		d.resolver = v
+	}
+	if v, ok := ctx.Value(hostKey{}).(bool); ok {
+		d.hostOnly = v
+	}
+	if v, ok := ctx.Value(portKey{}).(int); ok {
+		d.port = v
+	}
+	if v, ok := ctx.Value(ejectKey{}).(time.Duration); ok {
+		d.eject = v
+	}
 }
 
 func NewSelector(opts ...selector.Option) selector.Selector {
 	options := selector.Options{
-		Strategy: selector.Random,
+		Context:  context.Background(),
+		Strategy: Weighted,
 	}
 
 	for _, o := range opts {
 		o(&options)
 	}
 
-	return &dnsSelector{options: options, domain: DefaultDomain}
+	d := &dnsSelector{
+		options: options,
+		cache:   make(map[string]*record),
+		ejected: make(map[string]map[string]time.Time),
+	}
+	d.configure()
+
+	if d.resolver == nil {
+		d.resolver = NewResolver()
+	}
+
+	return d
 }
@@ -0,0 +1,344 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"go-micro.dev/v4/registry"
+	"go-micro.dev/v4/selector"
+)
+
+// testServer is an in-process DNS server answering from its records.
+type testServer struct {
+	addr string
+	srv  *dns.Server
+
+	sync.Mutex
+	records map[string][]dns.RR
+	queries map[string]int
+	// truncates the answers over UDP
+	truncate bool
+}
+
+func newTestServer(t *testing.T) *testServer {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		addr:    pc.LocalAddr().String(),
+		records: make(map[string][]dns.RR),
+		queries: make(map[string]int),
+	}
+
+	started := make(chan struct{})
+	s.srv = &dns.Server{
+		PacketConn:        pc,
+		Handler:           dns.HandlerFunc(s.serve),
+		NotifyStartedFunc: func() { close(started) },
+	}
+
+	go s.srv.ActivateAndServe()
+	<-started
+
+	// truncated answers are retried over TCP on the same port
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started = make(chan struct{})
+	tcpSrv := &dns.Server{
+		Listener:          l,
+		Handler:           dns.HandlerFunc(s.serve),
+		NotifyStartedFunc: func() { close(started) },
+	}
+
+	go tcpSrv.ActivateAndServe()
+	<-started
+
+	t.Cleanup(func() {
+		s.srv.Shutdown()
+		tcpSrv.Shutdown()
+	})
+
+	return s
+}
+
+func (s *testServer) add(t *testing.T, records ...string) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, r := range records {
+		rr, err := dns.NewRR(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k := rr.Header().Name + dns.TypeToString[rr.Header().Rrtype]
+		s.records[k] = append(s.records[k], rr)
+	}
+}
+
+func (s *testServer) reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.records = make(map[string][]dns.RR)
+}
+
+func (s *testServer) count(name string, qtype uint16) int {
+	s.Lock()
+	defer s.Unlock()
+	return s.queries[name+dns.TypeToString[qtype]]
+}
+
+func (s *testServer) serve(w dns.ResponseWriter, r *dns.Msg) {
+	q := r.Question[0]
+	k := q.Name + dns.TypeToString[q.Qtype]
+
+	s.Lock()
+	s.queries[k]++
+	answer := s.records[k]
+	truncate := s.truncate && w.RemoteAddr().Network() == "udp"
+	s.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = answer
+	if truncate {
+		m.Answer = nil
+		m.Truncated = true
+	}
+	w.WriteMsg(m)
+}
+
+func TestSelectSRV(t *testing.T) {
+	s := newTestServer(t)
+	s.add(t,
+		"_foo._tcp.local. 60 IN SRV 10 1 8080 a.local.",
+		"_foo._tcp.local. 60 IN SRV 10 3 8081 b.local.",
+		"_foo._tcp.local. 60 IN SRV 20 100 8082 c.local.",
+	)
+
+	sel := NewSelector(WithResolver(NewResolver(s.addr)))
+
+	counts := make(map[string]int)
+
+	for i := 0; i < 400; i++ {
+		next, err := sel.Select("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		node, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[node.Address]++
+	}
+
+	if counts["c.local.:8082"] > 0 {
+		t.Fatal("selected a node of a lower priority")
+	}
+	if counts["a.local.:8080"] == 0 || counts["b.local.:8081"] <= counts["a.local.:8080"] {
+		t.Fatalf("expected selection in proportion to weight, got %v", counts)
+	}
+
+	// the records are cached
+	if n := s.count("_foo._tcp.local.", dns.TypeSRV); n != 1 {
+		t.Fatalf("expected 1 query, got %d", n)
+	}
+}
+
+func TestSelectTTL(t *testing.T) {
+	s := newTestServer(t)
+	s.add(t, "_foo._tcp.local. 1 IN SRV 10 1 8080 a.local.")
+
+	sel := NewSelector(WithResolver(NewResolver(s.addr)))
+
+	if _, err := sel.Select("foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.reset()
+	s.add(t, "_foo._tcp.local. 1 IN SRV 10 1 9090 b.local.")
+
+	time.Sleep(1100 * time.Millisecond)
+
+	next, err := sel.Select("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Address != "b.local.:9090" {
+		t.Fatalf("expected the records to be looked up again, got %s", node.Address)
+	}
+	if n := s.count("_foo._tcp.local.", dns.TypeSRV); n != 2 {
+		t.Fatalf("expected 2 queries, got %d", n)
+	}
+
+	// expired records are used when the lookup fails
+	s.reset()
+	time.Sleep(1100 * time.Millisecond)
+
+	next, err = sel.Select("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node, err := next(); err != nil || node.Address != "b.local.:9090" {
+		t.Fatalf("expected the expired records, got %v %v", node, err)
+	}
+
+	// until they're reset
+	sel.Reset("foo")
+	if _, err := sel.Select("foo"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestMark(t *testing.T) {
+	s := newTestServer(t)
+	s.add(t,
+		"_foo._tcp.local. 60 IN SRV 10 1 8080 a.local.",
+		"_foo._tcp.local. 60 IN SRV 10 1 8081 b.local.",
+	)
+
+	sel := NewSelector(WithResolver(NewResolver(s.addr)), EjectTimeout(time.Minute))
+
+	failed := &registry.Node{Id: "a.local.:8080"}
+	sel.Mark("foo", failed, errors.New("failed"))
+
+	for i := 0; i < 50; i++ {
+		next, err := sel.Select("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		node, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.Id == failed.Id {
+			t.Fatal("selected an ejected node")
+		}
+	}
+
+	// every node ejected falls back to all of them
+	sel.Mark("foo", &registry.Node{Id: "b.local.:8081"}, errors.New("failed"))
+	if next, err := sel.Select("foo"); err != nil {
+		t.Fatal(err)
+	} else if _, err := next(); err != nil {
+		t.Fatal(err)
+	}
+
+	// marking without an error makes it available
+	sel.Mark("foo", &registry.Node{Id: "b.local.:8081"}, nil)
+	next, err := sel.Select("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := next(); node.Id != "b.local.:8081" {
+		t.Fatalf("expected the available node, got %s", node.Id)
+	}
+}
+
+func TestSelectHostOnly(t *testing.T) {
+	s := newTestServer(t)
+	s.add(t,
+		"foo.example. 60 IN A 10.0.0.1",
+		"foo.example. 60 IN AAAA ::1",
+	)
+
+	sel := NewSelector(WithResolver(NewResolver(s.addr)), HostOnly(), DefaultPort(9000))
+
+	next, err := sel.Select("foo.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		node, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[node.Address] = true
+	}
+
+	if !seen["10.0.0.1:9000"] || !seen["[::1]:9000"] {
+		t.Fatalf("expected the A and AAAA records with the default port, got %v", seen)
+	}
+
+	// host:port keeps its port
+	next, err = sel.Select("foo.example:7000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := next(); node.Address != "10.0.0.1:7000" && node.Address != "[::1]:7000" {
+		t.Fatalf("unexpected address %s", node.Address)
+	}
+}
+
+func TestSelectNotFound(t *testing.T) {
+	s := newTestServer(t)
+	sel := NewSelector(WithResolver(NewResolver(s.addr)))
+
+	if _, err := sel.Select("missing"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	next := Weighted([]*registry.Service{{Name: "foo"}})
+	if _, err := next(); err != selector.ErrNoneAvailable {
+		t.Fatalf("expected none available, got %v", err)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	s := newTestServer(t)
+	s.truncate = true
+	s.add(t, "foo.local. 60 IN A 10.0.0.1")
+
+	addrs, _, err := NewResolver(s.addr).LookupHost(context.Background(), "foo.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+		t.Fatalf("unexpected addresses %v", addrs)
+	}
+}
+
+func TestResolverFallback(t *testing.T) {
+	if _, err := net.LookupHost("localhost"); err != nil {
+		t.Skip("localhost isn't resolved by the system resolver")
+	}
+
+	s := newTestServer(t)
+
+	// the names of /etc/hosts aren't known to the servers
+	r := newDNSResolver([]string{s.addr}, nil)
+	if _, _, err := r.LookupHost(context.Background(), "localhost"); err == nil {
+		t.Fatal("expected the server not to resolve localhost")
+	}
+
+	r.fallback = &netResolver{}
+	addrs, _, err := r.LookupHost(context.Background(), "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) == 0 {
+		t.Fatal("expected the addresses of localhost")
+	}
+
+	// the default resolver is the system resolver
+	if _, ok := NewResolver().(*netResolver); !ok {
+		t.Fatal("expected the system resolver by default")
+	}
+}
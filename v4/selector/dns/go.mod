@@ -2,11 +2,13 @@ module github.com/go-micro/plugins/v4/selector/dns
 
 go 1.17
 
-require go-micro.dev/v4 v4.9.0
+require (
+	github.com/miekg/dns v1.1.43
+	go-micro.dev/v4 v4.9.0
+)
 
 require (
 	github.com/google/uuid v1.2.0 // indirect
-	github.com/miekg/dns v1.1.43 // indirect
 	golang.org/x/net v0.0.0-20210510120150-4163338589ed // indirect
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
 	golang.org/x/sys v0.0.0-20210502180810-71e4cd670f79 // indirect
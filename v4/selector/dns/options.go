@@ -0,0 +1,52 @@
+package dns
+
+import (
+	"context"
+	"time"
+
+	"go-micro.dev/v4/selector"
+)
+
+type domainKey struct{}
+type resolverKey struct{}
+type hostKey struct{}
+type portKey struct{}
+type ejectKey struct{}
+
+func setSelectorOption(k, v interface{}) selector.Option {
+	return func(o *selector.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// Domain sets the domain SRV records are looked up in, the default is
+// DefaultDomain.
+func Domain(d string) selector.Option {
+	return setSelectorOption(domainKey{}, d)
+}
+
+// WithResolver sets the resolver records are looked up with.
+func WithResolver(r Resolver) selector.Option {
+	return setSelectorOption(resolverKey{}, r)
+}
+
+// HostOnly looks up the A and AAAA records of the service rather than its
+// SRV records. The default port is used unless the service is host:port.
+func HostOnly() selector.Option {
+	return setSelectorOption(hostKey{}, true)
+}
+
+// DefaultPort sets the port of the nodes in host only mode, the default
+// is DefaultHostPort.
+func DefaultPort(p int) selector.Option {
+	return setSelectorOption(portKey{}, p)
+}
+
+// EjectTimeout sets how long a node marked as failing isn't selected, the
+// default is DefaultEjectTimeout.
+func EjectTimeout(d time.Duration) selector.Option {
+	return setSelectorOption(ejectKey{}, d)
+}
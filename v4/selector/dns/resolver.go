@@ -0,0 +1,227 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Resolver looks up DNS records. The ttl returned is the time the records
+// may be cached for, zero if unknown.
+type Resolver interface {
+	// LookupSRV looks up the _service._proto.name SRV records
+	LookupSRV(ctx context.Context, service, proto, name string) ([]*net.SRV, time.Duration, error)
+	// LookupHost looks up the A and AAAA records of the host
+	LookupHost(ctx context.Context, host string) ([]string, time.Duration, error)
+}
+
+// resolvConf is the system resolver config read by NewResolvConfResolver.
+var resolvConf = "/etc/resolv.conf"
+
+// NewResolver returns a resolver querying the DNS servers as host:port
+// directly, so that the records are cached for their ttl. Without servers
+// it's the system resolver, which reads /etc/hosts but doesn't return ttls.
+func NewResolver(servers ...string) Resolver {
+	if len(servers) == 0 {
+		return &netResolver{}
+	}
+
+	return newDNSResolver(servers, nil)
+}
+
+// NewResolvConfResolver returns a resolver querying the servers of
+// /etc/resolv.conf directly with its search list, so that the records are
+// cached for their ttl. The names it fails to look up, such as the names of
+// /etc/hosts, are looked up with the system resolver.
+func NewResolvConfResolver() Resolver {
+	conf, err := dns.ClientConfigFromFile(resolvConf)
+	if err != nil || len(conf.Servers) == 0 {
+		return &netResolver{}
+	}
+
+	servers := make([]string, 0, len(conf.Servers))
+	for _, s := range conf.Servers {
+		servers = append(servers, net.JoinHostPort(s, conf.Port))
+	}
+
+	r := newDNSResolver(servers, conf)
+	r.fallback = &netResolver{}
+
+	return r
+}
+
+func newDNSResolver(servers []string, conf *dns.ClientConfig) *dnsResolver {
+	return &dnsResolver{
+		client:    &dns.Client{},
+		tcpClient: &dns.Client{Net: "tcp"},
+		servers:   servers,
+		conf:      conf,
+	}
+}
+
+// dnsResolver queries the servers directly for the record ttls.
+type dnsResolver struct {
+	client *dns.Client
+	// retries the truncated answers
+	tcpClient *dns.Client
+	servers   []string
+	// search list of the system config
+	conf *dns.ClientConfig
+	// looks up the names the servers fail to
+	fallback Resolver
+}
+
+func (r *dnsResolver) LookupSRV(ctx context.Context, service, proto, name string) ([]*net.SRV, time.Duration, error) {
+	srvs, ttl, err := r.lookupSRV(ctx, service, proto, name)
+	if err != nil && r.fallback != nil {
+		return r.fallback.LookupSRV(ctx, service, proto, name)
+	}
+	return srvs, ttl, err
+}
+
+func (r *dnsResolver) lookupSRV(ctx context.Context, service, proto, name string) ([]*net.SRV, time.Duration, error) {
+	target := "_" + service + "._" + proto + "." + name
+
+	rrs, err := r.exchange(ctx, dns.Fqdn(target), dns.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var srvs []*net.SRV
+	var ttl uint32
+
+	for _, rr := range rrs {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		srvs = append(srvs, &net.SRV{
+			Target:   srv.Target,
+			Port:     srv.Port,
+			Priority: srv.Priority,
+			Weight:   srv.Weight,
+		})
+		ttl = minTTL(ttl, srv.Hdr.Ttl)
+	}
+
+	if len(srvs) == 0 {
+		return nil, 0, notFound(target)
+	}
+
+	return srvs, time.Duration(ttl) * time.Second, nil
+}
+
+func (r *dnsResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	addrs, ttl, err := r.lookupHost(ctx, host)
+	if err != nil && r.fallback != nil {
+		return r.fallback.LookupHost(ctx, host)
+	}
+	return addrs, ttl, err
+}
+
+func (r *dnsResolver) lookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{host}, 0, nil
+	}
+
+	names := []string{dns.Fqdn(host)}
+	if r.conf != nil {
+		names = r.conf.NameList(host)
+	}
+
+	var err error
+
+	for _, name := range names {
+		var addrs []string
+		var ttl uint32
+
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			rrs, qerr := r.exchange(ctx, name, qtype)
+			if qerr != nil {
+				err = qerr
+				continue
+			}
+
+			for _, rr := range rrs {
+				switch rr := rr.(type) {
+				case *dns.A:
+					addrs = append(addrs, rr.A.String())
+					ttl = minTTL(ttl, rr.Hdr.Ttl)
+				case *dns.AAAA:
+					addrs = append(addrs, rr.AAAA.String())
+					ttl = minTTL(ttl, rr.Hdr.Ttl)
+				}
+			}
+		}
+
+		if len(addrs) > 0 {
+			return addrs, time.Duration(ttl) * time.Second, nil
+		}
+	}
+
+	if err == nil {
+		err = notFound(host)
+	}
+
+	return nil, 0, err
+}
+
+// exchange queries the servers in order until one answers, over TCP when
+// the answer is truncated.
+func (r *dnsResolver) exchange(ctx context.Context, name string, qtype uint16) ([]dns.RR, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.SetEdns0(4096, false)
+
+	var err error
+
+	for _, server := range r.servers {
+		in, _, xerr := r.client.ExchangeContext(ctx, m, server)
+		if xerr == nil && in.Truncated {
+			in, _, xerr = r.tcpClient.ExchangeContext(ctx, m, server)
+		}
+		if xerr != nil {
+			err = xerr
+			continue
+		}
+
+		switch in.Rcode {
+		case dns.RcodeSuccess:
+			return in.Answer, nil
+		case dns.RcodeNameError:
+			return nil, notFound(strings.TrimSuffix(name, "."))
+		default:
+			err = fmt.Errorf("dns: lookup %s: %s", name, dns.RcodeToString[in.Rcode])
+		}
+	}
+
+	return nil, err
+}
+
+// netResolver is the system resolver, it doesn't know the record ttls.
+type netResolver struct{}
+
+func (r *netResolver) LookupSRV(ctx context.Context, service, proto, name string) ([]*net.SRV, time.Duration, error) {
+	_, srvs, err := net.DefaultResolver.LookupSRV(ctx, service, proto, name)
+	return srvs, 0, err
+}
+
+func (r *netResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	return addrs, 0, err
+}
+
+func minTTL(ttl, t uint32) uint32 {
+	if ttl == 0 || t < ttl {
+		return t
+	}
+	return ttl
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
@@ -2,14 +2,16 @@
 package file
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
 	"sync"
 	"time"
 
+	"go-micro.dev/v4/logger"
 	"go-micro.dev/v4/store"
 	"go-micro.dev/v4/util/cmd"
 	bolt "go.etcd.io/bbolt"
@@ -23,9 +25,24 @@ var (
 	DefaultTable = "micro"
 	// DefaultDir is the default directory for bbolt files.
 	DefaultDir = filepath.Join(os.TempDir(), "micro", "store")
+	// DefaultSweepInterval is how often expired records are deleted.
+	DefaultSweepInterval = time.Minute
 
 	// bucket used for data storage.
 	dataBucket = "data"
+	// bucket indexing the keys by expiry, the index keys are the big endian
+	// unix nano expiry followed by the key.
+	expiryBucket = "expiry"
+
+	// ErrNotFileStore is returned when compacting another store.
+	ErrNotFileStore = errors.New("not a file store")
+)
+
+const (
+	// records deleted per transaction by a sweep
+	sweepBatchSize = 1000
+	// size of the transactions copying a database when compacting
+	compactTxMaxSize = 1 << 20
 )
 
 func init() {
@@ -42,17 +59,193 @@ func NewStore(opts ...store.Option) store.Store {
 }
 
 type fileStore struct {
-	options store.Options
-	dir     string
+	options       store.Options
+	dir           string
+	sweepInterval time.Duration
 
 	// the database handle
 	sync.RWMutex
 	handles map[string]*fileHandle
+
+	// stops the sweeper
+	done    chan struct{}
+	sweeper sync.WaitGroup
 }
 
 type fileHandle struct {
 	key string
-	db  *bolt.DB
+
+	// held exclusively while the database is swapped by a compaction
+	sync.RWMutex
+	db *bolt.DB
+}
+
+func (fd *fileHandle) view(fn func(*bolt.Tx) error) error {
+	fd.RLock()
+	defer fd.RUnlock()
+	return fd.db.View(fn)
+}
+
+func (fd *fileHandle) update(fn func(*bolt.Tx) error) error {
+	fd.RLock()
+	defer fd.RUnlock()
+	return fd.db.Update(fn)
+}
+
+// sweep deletes the expired records in batches, so writes aren't held up by
+// a long transaction.
+func (fd *fileHandle) sweep() (int, error) {
+	var total int
+
+	for {
+		var expired [][]byte
+		err := fd.update(func(tx *bolt.Tx) error {
+			idx := tx.Bucket([]byte(expiryBucket))
+			if idx == nil {
+				return nil
+			}
+
+			now := uint64(time.Now().UnixNano())
+			c := idx.Cursor()
+			for k, _ := c.First(); k != nil && len(expired) < sweepBatchSize; k, _ = c.Next() {
+				if binary.BigEndian.Uint64(k) > now {
+					break
+				}
+				// the key is only valid during the transaction
+				expired = append(expired, append([]byte(nil), k...))
+			}
+
+			data := tx.Bucket([]byte(dataBucket))
+			for _, k := range expired {
+				if data != nil {
+					if err := data.Delete(k[8:]); err != nil {
+						return err
+					}
+				}
+				if err := idx.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += len(expired)
+		if len(expired) < sweepBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// compact copies the database to a new file without the free pages, and
+// swaps it for the current one. It returns whether the database is left
+// closed, when it couldn't be reopened.
+func (fd *fileHandle) compact() (bool, error) {
+	fd.Lock()
+	defer fd.Unlock()
+
+	path := fd.db.Path()
+	tmp := path + ".compact"
+	os.Remove(tmp)
+
+	dst, err := bolt.Open(tmp, 0700, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return false, err
+	}
+	if err := bolt.Compact(dst, fd.db, compactTxMaxSize); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return false, err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+
+	if err := fd.db.Close(); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	// on failure the current file is reopened
+	renameErr := os.Rename(tmp, path)
+
+	db, err := bolt.Open(path, 0700, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return true, err
+	}
+	fd.db = db
+
+	return false, renameErr
+}
+
+// expiryKey is the key of a record in the expiry index, the expiry time
+// first. The index is the same as the one of the file cache, they're kept
+// in sync.
+func expiryKey(expiresAt time.Time, key []byte) []byte {
+	k := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(expiresAt.UnixNano()))
+	copy(k[8:], key)
+	return k
+}
+
+// expiredKeys returns the keys of the records expired but not swept yet.
+func expiredKeys(tx *bolt.Tx) map[string]bool {
+	expired := map[string]bool{}
+
+	idx := tx.Bucket([]byte(expiryBucket))
+	if idx == nil {
+		return expired
+	}
+
+	now := uint64(time.Now().UnixNano())
+	c := idx.Cursor()
+	for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= now; k, _ = c.Next() {
+		expired[string(k[8:])] = true
+	}
+
+	return expired
+}
+
+// indexExpiry builds the expiry index of databases written before it existed.
+func indexExpiry(tx *bolt.Tx) error {
+	if tx.Bucket([]byte(expiryBucket)) != nil {
+		return nil
+	}
+	idx, err := tx.CreateBucket([]byte(expiryBucket))
+	if err != nil {
+		return err
+	}
+
+	data := tx.Bucket([]byte(dataBucket))
+	if data == nil {
+		return nil
+	}
+	return data.ForEach(func(k, v []byte) error {
+		// a record that can't be decoded doesn't stop the database from
+		// opening, it's left out of the index
+		var stored record
+		if err := json.Unmarshal(v, &stored); err != nil || stored.ExpiresAt.IsZero() {
+			return nil
+		}
+		return idx.Put(expiryKey(stored.ExpiresAt, k), nil)
+	})
+}
+
+// unindexExpiry removes the current record of the key from the expiry index.
+func unindexExpiry(tx *bolt.Tx, key []byte) error {
+	data := tx.Bucket([]byte(dataBucket))
+	idx := tx.Bucket([]byte(expiryBucket))
+	if data == nil || idx == nil {
+		return nil
+	}
+
+	var stored record
+	if err := json.Unmarshal(data.Get(key), &stored); err != nil || stored.ExpiresAt.IsZero() {
+		return nil
+	}
+	return idx.Delete(expiryKey(stored.ExpiresAt, key))
 }
 
 // record stored by us.
@@ -68,11 +261,14 @@ func key(database, table string) string {
 }
 
 func (m *fileStore) delete(fd *fileHandle, key string) error {
-	return fd.db.Update(func(tx *bolt.Tx) error {
+	return fd.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(dataBucket))
 		if b == nil {
 			return nil
 		}
+		if err := unindexExpiry(tx, []byte(key)); err != nil {
+			return err
+		}
 		return b.Delete([]byte(key))
 	})
 }
@@ -91,10 +287,14 @@ func (m *fileStore) init(opts ...store.Option) error {
 		m.options.Table = DefaultTable
 	}
 
+	m.sweepInterval = DefaultSweepInterval
 	if m.options.Context != nil {
 		if dir, ok := m.options.Context.Value(dirOptionKey{}).(string); ok {
 			m.dir = dir
 		}
+		if interval, ok := m.options.Context.Value(sweepIntervalKey{}).(time.Duration); ok {
+			m.sweepInterval = interval
+		}
 	}
 
 	// create default directory
@@ -150,80 +350,101 @@ func (f *fileStore) getDB(database, table string) (*fileHandle, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := db.Update(indexExpiry); err != nil {
+		db.Close()
+		return nil, err
+	}
 	fd = &fileHandle{
 		key: k,
 		db:  db,
 	}
 	f.handles[k] = fd
 
+	// sweep the open databases until the store is closed
+	if f.done == nil && f.sweepInterval > 0 {
+		f.done = make(chan struct{})
+		f.sweeper.Add(1)
+		go f.sweep(f.done, f.sweepInterval)
+	}
+
 	return fd, nil
 }
 
-func (m *fileStore) list(fd *fileHandle, limit, offset uint) []string {
-	var allItems []string
+func (f *fileStore) sweep(done chan struct{}, interval time.Duration) {
+	defer f.sweeper.Done()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+
+		f.RLock()
+		handles := make([]*fileHandle, 0, len(f.handles))
+		for _, fd := range f.handles {
+			handles = append(handles, fd)
+		}
+		f.RUnlock()
 
-	fd.db.View(func(tx *bolt.Tx) error {
+		for _, fd := range handles {
+			if _, err := fd.sweep(); err != nil {
+				logger.Errorf("Error sweeping expired records of %s: %v", fd.key, err)
+			}
+		}
+	}
+}
+
+// list the keys of the records not expired, in order. The expired ones are
+// skipped with the expiry index, the records aren't decoded.
+func (m *fileStore) list(fd *fileHandle, prefix, suffix string, limit, offset uint) ([]string, error) {
+	var keys []string
+
+	err := fd.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(dataBucket))
 		// nothing to read
 		if b == nil {
 			return nil
 		}
 
-		// @todo very inefficient
-		if err := b.ForEach(func(k, v []byte) error {
-			storedRecord := &record{}
-
-			if err := json.Unmarshal(v, storedRecord); err != nil {
-				return err
+		expired := expiredKeys(tx)
+		c := b.Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Next() {
+			if expired[string(k)] || !bytes.HasSuffix(k, []byte(suffix)) {
+				continue
 			}
-
-			if !storedRecord.ExpiresAt.IsZero() {
-				if storedRecord.ExpiresAt.Before(time.Now()) {
-					return nil
-				}
+			if offset > 0 {
+				offset--
+				continue
 			}
 
-			allItems = append(allItems, string(k))
-
-			return nil
-		}); err != nil {
-			return err
+			keys = append(keys, string(k))
+			if limit > 0 && uint(len(keys)) >= limit {
+				return nil
+			}
 		}
 
 		return nil
 	})
 
-	allKeys := make([]string, len(allItems))
-
-	for i, k := range allItems {
-		allKeys[i] = k
-	}
-
-	if limit != 0 || offset != 0 {
-		sort.Slice(allKeys, func(i, j int) bool { return allKeys[i] < allKeys[j] })
-		min := func(i, j uint) uint {
-			if i < j {
-				return i
-			}
-			return j
-		}
-		return allKeys[offset:min(limit, uint(len(allKeys)))]
-	}
-
-	return allKeys
+	return keys, err
 }
 
 func (m *fileStore) get(fd *fileHandle, k string) (*store.Record, error) {
 	var value []byte
 
-	fd.db.View(func(tx *bolt.Tx) error {
+	fd.view(func(tx *bolt.Tx) error {
 		// @todo this is still very experimental...
 		b := tx.Bucket([]byte(dataBucket))
 		if b == nil {
 			return nil
 		}
 
-		value = b.Get([]byte(k))
+		// the value is only valid during the transaction
+		value = append(value, b.Get([]byte(k))...)
 		return nil
 	})
 
@@ -275,7 +496,7 @@ func (m *fileStore) set(fd *fileHandle, r *store.Record) error {
 	// marshal the data
 	data, _ := json.Marshal(item)
 
-	return fd.db.Update(func(tx *bolt.Tx) error {
+	return fd.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(dataBucket))
 		if b == nil {
 			var err error
@@ -284,20 +505,78 @@ func (m *fileStore) set(fd *fileHandle, r *store.Record) error {
 				return err
 			}
 		}
+		idx, err := tx.CreateBucketIfNotExists([]byte(expiryBucket))
+		if err != nil {
+			return err
+		}
+
+		if err := unindexExpiry(tx, []byte(r.Key)); err != nil {
+			return err
+		}
+		if !item.ExpiresAt.IsZero() {
+			if err := idx.Put(expiryKey(item.ExpiresAt, []byte(r.Key)), nil); err != nil {
+				return err
+			}
+		}
 		return b.Put([]byte(r.Key), data)
 	})
 }
 
+// Close stops the sweeper and closes the databases, they're reopened if the
+// store is used again.
 func (f *fileStore) Close() error {
 	f.Lock()
-	defer f.Unlock()
-	for k, v := range f.handles {
+	if f.done != nil {
+		close(f.done)
+		f.done = nil
+	}
+	handles := f.handles
+	f.handles = make(map[string]*fileHandle)
+	f.Unlock()
+
+	// wait for a sweep in progress before closing the databases
+	f.sweeper.Wait()
+
+	// a compaction in progress swaps the database
+	for _, v := range handles {
+		v.Lock()
 		v.db.Close()
-		delete(f.handles, k)
+		v.Unlock()
 	}
 	return nil
 }
 
+// Compact deletes the expired records of a file store's table and rewrites
+// its file without the free pages, shrinking it on disk. Reads and writes of
+// the table wait for the compaction. The default database and table are
+// compacted when empty.
+func Compact(s store.Store, database, table string) error {
+	f, ok := s.(*fileStore)
+	if !ok {
+		return ErrNotFileStore
+	}
+
+	fd, err := f.getDB(database, table)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fd.sweep(); err != nil {
+		return err
+	}
+
+	closed, err := fd.compact()
+	if closed {
+		// the table is reopened when it's next used
+		f.Lock()
+		if f.handles[fd.key] == fd {
+			delete(f.handles, fd.key)
+		}
+		f.Unlock()
+	}
+	return err
+}
+
 func (f *fileStore) Init(opts ...store.Option) error {
 	return f.init(opts...)
 }
@@ -330,20 +609,19 @@ func (m *fileStore) Read(key string, opts ...store.ReadOption) ([]*store.Record,
 	var keys []string
 
 	// Handle Prefix / suffix
-	// TODO: do range scan here rather than listing all keys
 	if readOpts.Prefix || readOpts.Suffix {
-		// list the keys
-		k := m.list(fd, readOpts.Limit, readOpts.Offset)
+		var prefix, suffix string
+		if readOpts.Prefix {
+			prefix = key
+		}
+		if readOpts.Suffix {
+			suffix = key
+		}
 
-		// check for prefix and suffix
-		for _, v := range k {
-			if readOpts.Prefix && !strings.HasPrefix(v, key) {
-				continue
-			}
-			if readOpts.Suffix && !strings.HasSuffix(v, key) {
-				continue
-			}
-			keys = append(keys, v)
+		// list the keys
+		keys, err = m.list(fd, prefix, suffix, readOpts.Limit, readOpts.Offset)
+		if err != nil {
+			return nil, err
 		}
 	} else {
 		keys = []string{key}
@@ -414,30 +692,7 @@ func (m *fileStore) List(opts ...store.ListOption) ([]string, error) {
 		return nil, err
 	}
 
-	// TODO apply prefix/suffix in range query
-	allKeys := m.list(fd, listOptions.Limit, listOptions.Offset)
-
-	if len(listOptions.Prefix) > 0 {
-		var prefixKeys []string
-		for _, k := range allKeys {
-			if strings.HasPrefix(k, listOptions.Prefix) {
-				prefixKeys = append(prefixKeys, k)
-			}
-		}
-		allKeys = prefixKeys
-	}
-
-	if len(listOptions.Suffix) > 0 {
-		var suffixKeys []string
-		for _, k := range allKeys {
-			if strings.HasSuffix(k, listOptions.Suffix) {
-				suffixKeys = append(suffixKeys, k)
-			}
-		}
-		allKeys = suffixKeys
-	}
-
-	return allKeys, nil
+	return m.list(fd, listOptions.Prefix, listOptions.Suffix, listOptions.Limit, listOptions.Offset)
 }
 
 func (m *fileStore) String() string {
@@ -1,16 +1,19 @@
 package file
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/kr/pretty"
 	"go-micro.dev/v4/store"
+	bolt "go.etcd.io/bbolt"
 )
 
 func cleanup(db string, s store.Store) {
@@ -264,3 +267,220 @@ func fileTest(s store.Store, t *testing.T) {
 		}
 	}
 }
+
+func countRecords(t *testing.T, s store.Store, bucket string) int {
+	fd, err := s.(*fileStore).getDB("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	fd.view(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			n = b.Stats().KeyN
+		}
+		return nil
+	})
+	return n
+}
+
+func TestFileStoreSweep(t *testing.T) {
+	s := NewStore(DirOption(t.TempDir()), SweepInterval(10*time.Millisecond))
+	defer s.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := s.Write(&store.Record{Key: fmt.Sprintf("expiring%d", i), Expiry: 200 * time.Millisecond}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Write(&store.Record{Key: "kept"}); err != nil {
+		t.Fatal(err)
+	}
+	// rewriting a record moves it in the index
+	if err := s.Write(&store.Record{Key: "rewritten", Expiry: 200 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(&store.Record{Key: "rewritten", Expiry: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRecords(t, s, expiryBucket); n != 11 {
+		t.Fatalf("Expected 11 indexed records, got %d", n)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for countRecords(t, s, dataBucket) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the expired records to be swept, got %d records", countRecords(t, s, dataBucket))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := countRecords(t, s, expiryBucket); n != 1 {
+		t.Fatalf("Expected 1 indexed record, got %d", n)
+	}
+
+	keys, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "kept" || keys[1] != "rewritten" {
+		t.Fatalf("Expected the records not expired, got %v", keys)
+	}
+
+	if err := s.Delete("rewritten"); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRecords(t, s, expiryBucket); n != 0 {
+		t.Fatalf("Expected no indexed records, got %d", n)
+	}
+}
+
+func TestFileStoreClose(t *testing.T) {
+	s := NewStore(DirOption(t.TempDir()), SweepInterval(time.Millisecond))
+
+	if err := s.Write(&store.Record{Key: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close didn't stop the sweeper")
+	}
+
+	// the store is reopened with its sweeper
+	if _, err := s.Read("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if s.(*fileStore).done == nil {
+		t.Fatal("Expected the sweeper to be restarted")
+	}
+	s.Close()
+}
+
+func TestFileStoreIndexExisting(t *testing.T) {
+	dir := t.TempDir()
+
+	// a database written before the expiry index
+	if err := os.MkdirAll(filepath.Join(dir, DefaultDatabase), 0700); err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(dir, DefaultDatabase, DefaultTable+".db"), 0700, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(dataBucket))
+		if err != nil {
+			return err
+		}
+		for k, expiresAt := range map[string]time.Time{"expired": time.Now().Add(-time.Minute), "kept": {}} {
+			data, _ := json.Marshal(&record{Key: k, ExpiresAt: expiresAt})
+			if err := b.Put([]byte(k), data); err != nil {
+				return err
+			}
+		}
+		// a corrupt record doesn't stop the store from opening
+		return b.Put([]byte("corrupt"), []byte("{"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s := NewStore(DirOption(dir), SweepInterval(0))
+	defer s.Close()
+
+	if n := countRecords(t, s, expiryBucket); n != 1 {
+		t.Fatalf("Expected 1 indexed record, got %d", n)
+	}
+	if keys, err := s.List(); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 2 || keys[0] != "corrupt" || keys[1] != "kept" {
+		t.Fatalf("Expected the records not expired, got %v", keys)
+	}
+}
+
+func TestFileStoreCompact(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(DirOption(dir), SweepInterval(0))
+	defer s.Close()
+
+	value := make([]byte, 1024)
+	for i := 0; i < 1000; i++ {
+		if err := s.Write(&store.Record{Key: fmt.Sprintf("key%d", i), Value: value, Expiry: 10 * time.Millisecond}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Write(&store.Record{Key: "kept", Value: []byte("bar")}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, DefaultDatabase, DefaultTable+".db")
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := Compact(s, "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Fatalf("Expected the file to shrink from %d bytes, got %d", before.Size(), after.Size())
+	}
+
+	if r, err := s.Read("kept"); err != nil {
+		t.Fatal(err)
+	} else if string(r[0].Value) != "bar" {
+		t.Fatalf("Expected bar, got %s", r[0].Value)
+	}
+	if n := countRecords(t, s, dataBucket); n != 1 {
+		t.Fatalf("Expected 1 record, got %d", n)
+	}
+
+	if err := Compact(nil, "", ""); err != ErrNotFileStore {
+		t.Fatalf("Expected %v, got %v", ErrNotFileStore, err)
+	}
+}
+
+func TestFileStoreCompactClose(t *testing.T) {
+	s := NewStore(DirOption(t.TempDir()), SweepInterval(0))
+	defer s.Close()
+
+	if err := s.Write(&store.Record{Key: "kept", Value: []byte("bar")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the databases are closed while compacted, the compactions reopen them
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			Compact(s, "", "")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			s.Close()
+		}
+	}()
+	wg.Wait()
+
+	if r, err := s.Read("kept"); err != nil {
+		t.Fatal(err)
+	} else if string(r[0].Value) != "bar" {
+		t.Fatalf("Expected bar, got %s", r[0].Value)
+	}
+}
@@ -2,6 +2,7 @@ package file
 
 import (
 	"context"
+	"time"
 
 	"go-micro.dev/v4/store"
 )
@@ -17,3 +18,17 @@ func DirOption(dir string) store.Option {
 		o.Context = context.WithValue(o.Context, dirOptionKey{}, dir)
 	}
 }
+
+type sweepIntervalKey struct{}
+
+// SweepInterval sets how often the expired records are deleted from the open
+// databases, DefaultSweepInterval by default. Zero disables the sweeper, the
+// expired records are then only deleted by Compact.
+func SweepInterval(d time.Duration) store.Option {
+	return func(o *store.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, sweepIntervalKey{}, d)
+	}
+}
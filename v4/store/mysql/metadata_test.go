@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"testing"
+)
+
+func TestMetadataScan(t *testing.T) {
+	testCases := []struct {
+		src  interface{}
+		want map[string]interface{}
+	}{
+		{nil, map[string]interface{}{}},
+		{[]byte(`null`), map[string]interface{}{}},
+		{[]byte(`{"foo":"bar"}`), map[string]interface{}{"foo": "bar"}},
+		{`{"foo":1}`, map[string]interface{}{"foo": float64(1)}},
+	}
+
+	for _, c := range testCases {
+		md := make(Metadata)
+		if err := md.Scan(c.src); err != nil {
+			t.Fatalf("failed to scan %v: %v", c.src, err)
+		}
+		if len(md) != len(c.want) {
+			t.Fatalf("expected %v got %v", c.want, md)
+		}
+		for k, v := range c.want {
+			if md[k] != v {
+				t.Fatalf("expected %v got %v", c.want, md)
+			}
+		}
+	}
+
+	md := make(Metadata)
+	if err := md.Scan([]byte(`[1]`)); err == nil {
+		t.Fatal("expected error scanning non object")
+	}
+}
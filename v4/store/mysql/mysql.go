@@ -1,10 +1,13 @@
+// Package mysql implements the mysql store
 package mysql
 
 import (
 	"database/sql"
 	"fmt"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
-	"unicode"
 
 	"github.com/pkg/errors"
 	log "go-micro.dev/v4/logger"
@@ -19,15 +22,33 @@ var (
 	DefaultTable = "micro"
 )
 
+var (
+	re = regexp.MustCompile("[^a-zA-Z0-9]+")
+
+	// escapes the LIKE wildcards in keys
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+	statements = map[string]string{
+		"list":       "SELECT `key` FROM `%s`.`%s` WHERE `key` LIKE ? AND (expiry IS NULL OR expiry > ?) ORDER BY `key`;",
+		"listOffset": "SELECT `key` FROM `%s`.`%s` WHERE `key` LIKE ? AND (expiry IS NULL OR expiry > ?) ORDER BY `key` LIMIT ? OFFSET ?;",
+		"read":       "SELECT `key`, value, metadata, expiry FROM `%s`.`%s` WHERE `key` = ?;",
+		"readMany":   "SELECT `key`, value, metadata, expiry FROM `%s`.`%s` WHERE `key` LIKE ? AND (expiry IS NULL OR expiry > ?) ORDER BY `key`;",
+		"readOffset": "SELECT `key`, value, metadata, expiry FROM `%s`.`%s` WHERE `key` LIKE ? AND (expiry IS NULL OR expiry > ?) ORDER BY `key` LIMIT ? OFFSET ?;",
+		"write":      "INSERT INTO `%s`.`%s` (`key`, value, metadata, expiry) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value), metadata = VALUES(metadata), expiry = VALUES(expiry);",
+		"delete":     "DELETE FROM `%s`.`%s` WHERE `key` = ?;",
+	}
+)
+
 type sqlStore struct {
 	db *sql.DB
 
-	database string
-	table    string
-
 	options store.Options
 
-	readPrepare, writePrepare, deletePrepare *sql.Stmt
+	sync.RWMutex
+	// known databases
+	databases map[string]bool
+	// prepared statements by database:table:query
+	stmts map[string]*sql.Stmt
 }
 
 func init() {
@@ -47,12 +68,52 @@ func (s *sqlStore) Options() store.Options {
 }
 
 func (s *sqlStore) Close() error {
-	return s.db.Close()
+	s.Lock()
+	defer s.Unlock()
+
+	s.closeStmts()
+
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
 }
 
-// List all the known records.
+// List all the known keys.
 func (s *sqlStore) List(opts ...store.ListOption) ([]string, error) {
-	rows, err := s.db.Query(fmt.Sprintf("SELECT `key`, value, expiry FROM %s.%s;", s.database, s.table))
+	var options store.ListOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// create the db if not exists
+	if err := s.createDB(options.Database, options.Table); err != nil {
+		return nil, err
+	}
+
+	pattern := likeEscaper.Replace(options.Prefix) + "%"
+	if len(options.Suffix) > 0 {
+		pattern = pattern + likeEscaper.Replace(options.Suffix)
+	}
+
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	if options.Limit != 0 {
+		st, serr := s.prepare(options.Database, options.Table, "listOffset")
+		if serr != nil {
+			return nil, serr
+		}
+		rows, err = st.Query(pattern, time.Now(), options.Limit, options.Offset)
+	} else {
+		st, serr := s.prepare(options.Database, options.Table, "list")
+		if serr != nil {
+			return nil, serr
+		}
+		rows, err = st.Query(pattern, time.Now())
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -61,68 +122,181 @@ func (s *sqlStore) List(opts ...store.ListOption) ([]string, error) {
 	}
 	defer rows.Close()
 
-	var records []string
-	var cachedTime time.Time
+	var keys []string
 
 	for rows.Next() {
-		record := &store.Record{}
-		if err := rows.Scan(&record.Key, &record.Value, &cachedTime); err != nil {
-			return nil, err
-		}
-
-		if cachedTime.Before(time.Now()) {
-			// record has expired
-			go s.Delete(record.Key)
-		} else {
-			records = append(records, record.Key)
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return keys, err
 		}
+		keys = append(keys, key)
 	}
 	rowErr := rows.Close()
 	if rowErr != nil {
 		// transaction rollback or something
-		return records, rowErr
+		return keys, rowErr
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return keys, err
 	}
-	return records, nil
+	return keys, nil
 }
 
-// Read all records with keys.
+// Read a single key, or many records when the prefix or suffix option is set.
 func (s *sqlStore) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
 	var options store.ReadOptions
 	for _, o := range opts {
 		o(&options)
 	}
 
-	// TODO: make use of options.Prefix using WHERE key LIKE = ?
+	// create the db if not exists
+	if err := s.createDB(options.Database, options.Table); err != nil {
+		return nil, err
+	}
+
+	if options.Prefix || options.Suffix {
+		return s.read(key, options)
+	}
 
 	var records []*store.Record
-	row := s.readPrepare.QueryRow(key)
+
+	st, err := s.prepare(options.Database, options.Table, "read")
+	if err != nil {
+		return nil, err
+	}
+
+	row := st.QueryRow(key)
 	record := &store.Record{}
-	var cachedTime time.Time
+	metadata := make(Metadata)
+	var expiry sql.NullTime
 
-	if err := row.Scan(&record.Key, &record.Value, &cachedTime); err != nil {
+	if err := row.Scan(&record.Key, &record.Value, &metadata, &expiry); err != nil {
 		if err == sql.ErrNoRows {
 			return records, store.ErrNotFound
 		}
 		return records, err
 	}
-	if cachedTime.Before(time.Now()) {
-		// record has expired
-		go s.Delete(key)
-		return records, store.ErrNotFound
+
+	// set the metadata
+	record.Metadata = toMetadata(&metadata)
+
+	if expiry.Valid {
+		if expiry.Time.Before(time.Now()) {
+			// record has expired
+			go s.Delete(key, store.DeleteFrom(options.Database, options.Table))
+			return records, store.ErrNotFound
+		}
+		record.Expiry = time.Until(expiry.Time)
 	}
-	record.Expiry = time.Until(cachedTime)
 	records = append(records, record)
 
 	return records, nil
 }
 
+// Read many records.
+func (s *sqlStore) read(key string, options store.ReadOptions) ([]*store.Record, error) {
+	key = likeEscaper.Replace(key)
+
+	pattern := "%"
+	if options.Prefix {
+		pattern = key + pattern
+	}
+	if options.Suffix {
+		pattern = pattern + key
+	}
+
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	if options.Limit != 0 {
+		st, serr := s.prepare(options.Database, options.Table, "readOffset")
+		if serr != nil {
+			return nil, serr
+		}
+		rows, err = st.Query(pattern, time.Now(), options.Limit, options.Offset)
+	} else {
+		st, serr := s.prepare(options.Database, options.Table, "readMany")
+		if serr != nil {
+			return nil, serr
+		}
+		rows, err = st.Query(pattern, time.Now())
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return []*store.Record{}, nil
+		}
+		return []*store.Record{}, errors.Wrap(err, "sqlStore.read failed")
+	}
+	defer rows.Close()
+
+	var records []*store.Record
+
+	for rows.Next() {
+		record := &store.Record{}
+		metadata := make(Metadata)
+		var expiry sql.NullTime
+
+		if err := rows.Scan(&record.Key, &record.Value, &metadata, &expiry); err != nil {
+			return records, err
+		}
+
+		// set the metadata
+		record.Metadata = toMetadata(&metadata)
+
+		if expiry.Valid {
+			record.Expiry = time.Until(expiry.Time)
+		}
+		records = append(records, record)
+	}
+	rowErr := rows.Close()
+	if rowErr != nil {
+		// transaction rollback or something
+		return records, rowErr
+	}
+	if err := rows.Err(); err != nil {
+		return records, err
+	}
+
+	return records, nil
+}
+
 // Write records.
 func (s *sqlStore) Write(r *store.Record, opts ...store.WriteOption) error {
-	timeCached := time.Now().Add(r.Expiry)
-	_, err := s.writePrepare.Exec(r.Key, r.Value, timeCached, r.Value, timeCached)
+	var options store.WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// create the db if not exists
+	if err := s.createDB(options.Database, options.Table); err != nil {
+		return err
+	}
+
+	st, err := s.prepare(options.Database, options.Table, "write")
+	if err != nil {
+		return err
+	}
+
+	metadata := make(Metadata)
+	for k, v := range r.Metadata {
+		metadata[k] = v
+	}
+
+	expiry := r.Expiry
+	if !options.Expiry.IsZero() {
+		expiry = time.Until(options.Expiry)
+	}
+	if options.TTL != 0 {
+		expiry = options.TTL
+	}
+
+	if expiry != 0 {
+		_, err = st.Exec(r.Key, r.Value, metadata, time.Now().Add(expiry))
+	} else {
+		_, err = st.Exec(r.Key, r.Value, metadata, nil)
+	}
 	if err != nil {
 		return errors.Wrap(err, "Couldn't insert record "+r.Key)
 	}
@@ -132,7 +306,22 @@ func (s *sqlStore) Write(r *store.Record, opts ...store.WriteOption) error {
 
 // Delete records with keys.
 func (s *sqlStore) Delete(key string, opts ...store.DeleteOption) error {
-	result, err := s.deletePrepare.Exec(key)
+	var options store.DeleteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// create the db if not exists
+	if err := s.createDB(options.Database, options.Table); err != nil {
+		return err
+	}
+
+	st, err := s.prepare(options.Database, options.Table, "delete")
+	if err != nil {
+		return err
+	}
+
+	result, err := st.Exec(key)
 	if err != nil {
 		return err
 	}
@@ -144,53 +333,138 @@ func (s *sqlStore) Delete(key string, opts ...store.DeleteOption) error {
 	return nil
 }
 
-func (s *sqlStore) initDB() error {
-	// Create the namespace's database
-	_, err := s.db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s ;", s.database))
-	if err != nil {
+func (s *sqlStore) getDB(database, table string) (string, string) {
+	if len(database) == 0 {
+		if len(s.options.Database) > 0 {
+			database = s.options.Database
+		} else {
+			database = DefaultDatabase
+		}
+	}
+
+	if len(table) == 0 {
+		if len(s.options.Table) > 0 {
+			table = s.options.Table
+		} else {
+			table = DefaultTable
+		}
+	}
+
+	// store.namespace must only contain letters, numbers and underscores
+	database = re.ReplaceAllString(database, "_")
+	table = re.ReplaceAllString(table, "_")
+
+	return database, table
+}
+
+func (s *sqlStore) createDB(database, table string) error {
+	database, table = s.getDB(database, table)
+
+	s.RLock()
+	ok := s.databases[database+":"+table]
+	s.RUnlock()
+	if ok {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.databases[database+":"+table] {
+		return nil
+	}
+
+	if err := s.initDB(database, table); err != nil {
 		return err
 	}
 
-	_, err = s.db.Exec(fmt.Sprintf("USE %s ;", s.database))
+	s.databases[database+":"+table] = true
+	return nil
+}
+
+func (s *sqlStore) initDB(database, table string) error {
+	if s.db == nil {
+		return errors.New("Database connection not initialized")
+	}
+
+	// Create the namespace's database
+	_, err := s.db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`;", database))
 	if err != nil {
-		return errors.Wrap(err, "Couldn't use database")
+		return err
 	}
 
 	// Create a table for the namespace's prefix
-	createSQL := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (`key` varchar(255) primary key, value blob null, expiry timestamp not null);", s.table)
+	createSQL := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`.`%s` (`key` varchar(255) primary key, value blob null, metadata json null, expiry timestamp null default null);", database, table)
 	_, err = s.db.Exec(createSQL)
 	if err != nil {
 		return errors.Wrap(err, "Couldn't create table")
 	}
 
-	// prepare
-	s.readPrepare, _ = s.db.Prepare(fmt.Sprintf("SELECT `key`, value, expiry FROM %s.%s WHERE `key` = ?;", s.database, s.table))
-	s.writePrepare, _ = s.db.Prepare(fmt.Sprintf("INSERT INTO %s.%s (`key`, value, expiry) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE `value`= ?, `expiry` = ?", s.database, s.table))
-	s.deletePrepare, _ = s.db.Prepare(fmt.Sprintf("DELETE FROM %s.%s WHERE `key` = ?;", s.database, s.table))
+	return s.migrateDB(database, table)
+}
+
+// migrateDB upgrades tables created by earlier versions of the store,
+// which had no metadata column and a non-nullable expiry.
+func (s *sqlStore) migrateDB(database, table string) error {
+	var count int
+	row := s.db.QueryRow("SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND COLUMN_NAME = 'metadata';", database, table)
+	if err := row.Scan(&count); err != nil {
+		return errors.Wrap(err, "Couldn't inspect table")
+	}
+	if count > 0 {
+		return nil
+	}
 
+	_, err := s.db.Exec(fmt.Sprintf("ALTER TABLE `%s`.`%s` ADD COLUMN metadata json null, MODIFY expiry timestamp null default null;", database, table))
+	if err != nil {
+		return errors.Wrap(err, "Couldn't migrate table")
+	}
 	return nil
 }
 
-func (s *sqlStore) configure() error {
-	nodes := s.options.Nodes
-	if len(nodes) == 0 {
-		nodes = []string{"localhost:3306"}
+func (s *sqlStore) prepare(database, table, query string) (*sql.Stmt, error) {
+	st, ok := statements[query]
+	if !ok {
+		return nil, errors.New("unsupported statement")
 	}
 
-	database := s.options.Database
-	if len(database) == 0 {
-		database = DefaultDatabase
+	// get DB
+	database, table = s.getDB(database, table)
+	k := database + ":" + table + ":" + query
+
+	s.RLock()
+	stmt, ok := s.stmts[k]
+	s.RUnlock()
+	if ok {
+		return stmt, nil
 	}
 
-	table := s.options.Table
-	if len(table) == 0 {
-		table = DefaultTable
+	s.Lock()
+	defer s.Unlock()
+
+	if stmt, ok := s.stmts[k]; ok {
+		return stmt, nil
 	}
 
-	for _, r := range database {
-		if !unicode.IsLetter(r) {
-			return errors.New("store.namespace must only contain letters")
-		}
+	stmt, err := s.db.Prepare(fmt.Sprintf(st, database, table))
+	if err != nil {
+		return nil, err
+	}
+	s.stmts[k] = stmt
+	return stmt, nil
+}
+
+func (s *sqlStore) closeStmts() {
+	for k, stmt := range s.stmts {
+		stmt.Close()
+		delete(s.stmts, k)
+	}
+}
+
+func (s *sqlStore) configure() error {
+	nodes := s.options.Nodes
+	if len(nodes) == 0 {
+		nodes = []string{"localhost:3306"}
 	}
 
 	source := nodes[0]
@@ -204,24 +478,26 @@ func (s *sqlStore) configure() error {
 		return err
 	}
 
+	s.Lock()
+	s.closeStmts()
 	if s.db != nil {
 		s.db.Close()
 	}
 
 	// save the values
 	s.db = db
-	s.database = database
-	s.table = table
+	s.databases = make(map[string]bool)
+	s.Unlock()
 
 	// initialize the database
-	return s.initDB()
+	return s.createDB(s.options.Database, s.options.Table)
 }
 
 func (s *sqlStore) String() string {
 	return "mysql"
 }
 
-// New returns a new micro Store backed by sql.
+// NewStore returns a new micro Store backed by sql.
 func NewStore(opts ...store.Option) store.Store {
 	var options store.Options
 	for _, o := range opts {
@@ -232,6 +508,10 @@ func NewStore(opts ...store.Option) store.Store {
 	s := new(sqlStore)
 	// set the options
 	s.options = options
+	// mark known databases
+	s.databases = make(map[string]bool)
+	// prepared statements
+	s.stmts = make(map[string]*sql.Stmt)
 
 	// configure the store
 	if err := s.configure(); err != nil {
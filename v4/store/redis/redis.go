@@ -2,7 +2,13 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	log "go-micro.dev/v4/logger"
@@ -10,6 +16,37 @@ import (
 	"go-micro.dev/v4/util/cmd"
 )
 
+const (
+	// hash fields a record is stored in
+	valueField    = "value"
+	metadataField = "metadata"
+	// fencing token of the records written with WriteFenced
+	fenceField = "fence"
+)
+
+var (
+	// ScanCount is the number of keys requested per SCAN iteration.
+	ScanCount int64 = 100
+
+	// escapes the glob characters of a SCAN pattern
+	globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+	// replaces the record unless its fencing token is greater than the
+	// token written, the tokens are compared as decimal strings as lua
+	// numbers lose the precision of large tokens
+	writeFencedScript = redis.NewScript(`
+local latest = redis.call("HGET", KEYS[1], "` + fenceField + `")
+if latest and (#latest > #ARGV[1] or (#latest == #ARGV[1] and latest > ARGV[1])) then
+	return 0
+end
+redis.call("DEL", KEYS[1])
+redis.call("HSET", KEYS[1], "` + fenceField + `", ARGV[1], unpack(ARGV, 3))
+if tonumber(ARGV[2]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 1`)
+)
+
 type rkv struct {
 	ctx     context.Context
 	options store.Options
@@ -34,54 +71,205 @@ func (r *rkv) Close() error {
 
 func (r *rkv) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
 	options := store.ReadOptions{}
-	options.Table = r.options.Table
 
 	for _, o := range opts {
 		o(&options)
 	}
 
-	var keys []string
+	prefix := r.prefix(options.Database, options.Table)
+	legacy, hasLegacy := r.legacyPrefix(options.Database, options.Table)
 
-	rkey := fmt.Sprintf("%s%s", options.Table, key)
-	// Handle Prefix
-	// TODO suffix
-	if options.Prefix {
-		prefixKey := fmt.Sprintf("%s*", rkey)
-		fkeys, err := r.Client.Keys(r.ctx, prefixKey).Result()
+	if !options.Prefix && !options.Suffix {
+		records, err := r.get(prefix, []string{prefix + key})
 		if err != nil {
 			return nil, err
 		}
-		// TODO Limit Offset
+		if len(records) == 0 && hasLegacy {
+			records, err = r.readLegacy(prefix, legacy, key)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(records) == 0 {
+			return nil, store.ErrNotFound
+		}
+		return records, nil
+	}
 
-		keys = append(keys, fkeys...)
-	} else {
-		keys = []string{rkey}
+	var match string
+	if options.Prefix {
+		match += globEscaper.Replace(key)
+	}
+	match += "*"
+	if options.Suffix {
+		match += globEscaper.Replace(key)
 	}
 
-	records := make([]*store.Record, 0, len(keys))
+	keys, legacyKeys, err := r.scanNamespace(prefix, legacy, hasLegacy, match, options.Limit, options.Offset)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, rkey = range keys {
-		val, err := r.Client.Get(r.ctx, rkey).Bytes()
+	records, err := r.get(prefix, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(legacyKeys) == 0 {
+		return records, nil
+	}
 
-		if err != nil && err == redis.Nil {
-			return nil, store.ErrNotFound
+	legacyRecords, err := r.get(legacy, legacyKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(records, legacyRecords...), nil
+}
+
+// readLegacy reads a record written as a string at the legacy prefix by
+// earlier versions of the store, and moves it into the namespace.
+func (r *rkv) readLegacy(prefix, legacy, key string) ([]*store.Record, error) {
+	var (
+		value *redis.StringCmd
+		ttl   *redis.DurationCmd
+	)
+
+	_, err := r.Client.Pipelined(r.ctx, func(p redis.Pipeliner) error {
+		value = p.Get(r.ctx, legacy+key)
+		ttl = p.PTTL(r.ctx, legacy+key)
+		return nil
+	})
+	// the key isn't a record of an earlier version
+	if err == redis.Nil || isWrongType(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	record := &store.Record{
+		Key:      key,
+		Value:    []byte(value.Val()),
+		Metadata: make(map[string]interface{}),
+		Expiry:   expiry(ttl.Val()),
+	}
+
+	if err := r.migrate(record, prefix+key, legacy+key); err != nil {
+		log.Warnf("failed to migrate %s to %s: %v", legacy+key, prefix+key, err)
+	}
+
+	return []*store.Record{record}, nil
+}
+
+// migrate writes the record read at the legacy key to the key, unless the
+// key was written since, then deletes the legacy key.
+func (r *rkv) migrate(record *store.Record, key, legacyKey string) error {
+	err := r.Client.Watch(r.ctx, func(tx *redis.Tx) error {
+		n, err := tx.Exists(r.ctx, key).Result()
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = tx.TxPipelined(r.ctx, func(p redis.Pipeliner) error {
+			p.HSet(r.ctx, key, valueField, record.Value)
+			if record.Expiry > 0 {
+				p.PExpire(r.ctx, key, record.Expiry)
+			}
+			return nil
+		})
+		return err
+	}, key)
+	// the key was written meanwhile, which supersedes the legacy record
+	if err != nil && err != redis.TxFailedErr {
+		return err
+	}
+
+	return r.Client.Del(r.ctx, legacyKey).Err()
+}
+
+// get reads the records stored at keys in a single pipeline. Keys that
+// no longer exist are skipped.
+func (r *rkv) get(prefix string, keys []string) ([]*store.Record, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	hashes := make([]*redis.StringStringMapCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+
+	_, err := r.Client.Pipelined(r.ctx, func(p redis.Pipeliner) error {
+		for i, k := range keys {
+			hashes[i] = p.HGetAll(r.ctx, k)
+			ttls[i] = p.PTTL(r.ctx, k)
+		}
+		return nil
+	})
+	if err != nil && !isWrongType(err) {
+		return nil, err
+	}
+
+	records := make([]*store.Record, 0, len(keys))
+	// keys written as plain strings by earlier versions of the store
+	var legacy []int
+
+	for i, k := range keys {
+		fields, err := hashes[i].Result()
+		if isWrongType(err) {
+			legacy = append(legacy, i)
+			continue
 		} else if err != nil {
 			return nil, err
 		}
 
-		if val == nil {
-			return nil, store.ErrNotFound
+		// HGETALL of a missing key returns an empty hash
+		if len(fields) == 0 {
+			continue
 		}
 
-		d, err := r.Client.TTL(r.ctx, rkey).Result()
-		if err != nil {
+		record := &store.Record{
+			Key:      strings.TrimPrefix(k, prefix),
+			Value:    []byte(fields[valueField]),
+			Metadata: make(map[string]interface{}),
+			Expiry:   expiry(ttls[i].Val()),
+		}
+
+		if md, ok := fields[metadataField]; ok && len(md) > 0 {
+			if err := json.Unmarshal([]byte(md), &record.Metadata); err != nil {
+				return nil, err
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	if len(legacy) == 0 {
+		return records, nil
+	}
+
+	values := make([]*redis.StringCmd, len(legacy))
+
+	_, err = r.Client.Pipelined(r.ctx, func(p redis.Pipeliner) error {
+		for i, idx := range legacy {
+			values[i] = p.Get(r.ctx, keys[idx])
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i, idx := range legacy {
+		val, err := values[i].Bytes()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
 			return nil, err
 		}
 
 		records = append(records, &store.Record{
-			Key:    key,
-			Value:  val,
-			Expiry: d,
+			Key:      strings.TrimPrefix(keys[idx], prefix),
+			Value:    val,
+			Metadata: make(map[string]interface{}),
+			Expiry:   expiry(ttls[idx].Val()),
 		})
 	}
 
@@ -90,44 +278,303 @@ func (r *rkv) Read(key string, opts ...store.ReadOption) ([]*store.Record, error
 
 func (r *rkv) Delete(key string, opts ...store.DeleteOption) error {
 	options := store.DeleteOptions{}
-	options.Table = r.options.Table
 
 	for _, o := range opts {
 		o(&options)
 	}
 
-	rkey := fmt.Sprintf("%s%s", options.Table, key)
-	return r.Client.Del(r.ctx, rkey).Err()
+	rkey := r.prefix(options.Database, options.Table) + key
+
+	legacy, ok := r.legacyPrefix(options.Database, options.Table)
+	if !ok {
+		return r.Client.Del(r.ctx, rkey).Err()
+	}
+
+	// the record may not have been migrated yet, the keys are
+	// deleted one by one as they may be in different cluster slots
+	_, err := r.Client.Pipelined(r.ctx, func(p redis.Pipeliner) error {
+		p.Del(r.ctx, rkey)
+		p.Del(r.ctx, legacy+key)
+		return nil
+	})
+
+	return err
 }
 
 func (r *rkv) Write(record *store.Record, opts ...store.WriteOption) error {
+	rkey, fields, ttl, err := r.fields(record, opts...)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Client.TxPipelined(r.ctx, func(p redis.Pipeliner) error {
+		p.Del(r.ctx, rkey)
+		p.HSet(r.ctx, rkey, fields...)
+		if ttl > 0 {
+			p.PExpire(r.ctx, rkey, ttl)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// WriteFenced writes the record unless the record stored was written with
+// a greater fencing token, comparing the tokens and writing atomically.
+// It returns whether the record was written, see the fencing sync plugin.
+func (r *rkv) WriteFenced(record *store.Record, token uint64, opts ...store.WriteOption) (bool, error) {
+	rkey, fields, ttl, err := r.fields(record, opts...)
+	if err != nil {
+		return false, err
+	}
+
+	args := append([]interface{}{strconv.FormatUint(token, 10), ttl.Milliseconds()}, fields...)
+
+	written, err := writeFencedScript.Run(r.ctx, r.Client, []string{rkey}, args...).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return written == 1, nil
+}
+
+// fields returns the key, the hash fields and the ttl a record is written with.
+func (r *rkv) fields(record *store.Record, opts ...store.WriteOption) (string, []interface{}, time.Duration, error) {
 	options := store.WriteOptions{}
-	options.Table = r.options.Table
 
 	for _, o := range opts {
 		o(&options)
 	}
 
-	rkey := fmt.Sprintf("%s%s", options.Table, record.Key)
-	return r.Client.Set(r.ctx, rkey, record.Value, record.Expiry).Err()
+	rkey := r.prefix(options.Database, options.Table) + record.Key
+
+	ttl := record.Expiry
+	if !options.Expiry.IsZero() {
+		ttl = time.Until(options.Expiry)
+	}
+	if options.TTL != 0 {
+		ttl = options.TTL
+	}
+
+	fields := []interface{}{valueField, record.Value}
+	if len(record.Metadata) > 0 {
+		md, err := json.Marshal(record.Metadata)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		fields = append(fields, metadataField, md)
+	}
+
+	return rkey, fields, ttl, nil
 }
 
 func (r *rkv) List(opts ...store.ListOption) ([]string, error) {
 	options := store.ListOptions{}
-	options.Table = r.options.Table
 
 	for _, o := range opts {
 		o(&options)
 	}
 
-	keys, err := r.Client.Keys(r.ctx, "*").Result()
+	prefix := r.prefix(options.Database, options.Table)
+	legacy, hasLegacy := r.legacyPrefix(options.Database, options.Table)
+	match := globEscaper.Replace(options.Prefix) + "*" + globEscaper.Replace(options.Suffix)
+
+	keys, legacyKeys, err := r.scanNamespace(prefix, legacy, hasLegacy, match, options.Limit, options.Offset)
 	if err != nil {
 		return nil, err
 	}
 
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, prefix)
+	}
+	for _, k := range legacyKeys {
+		keys = append(keys, strings.TrimPrefix(k, legacy))
+	}
+
 	return keys, nil
 }
 
+// scanNamespace returns the keys of the namespace at prefix matching the
+// pattern, and the keys matching it at the legacy prefix which earlier
+// versions of the store wrote and weren't written in the namespace since.
+func (r *rkv) scanNamespace(prefix, legacy string, hasLegacy bool, match string, limit, offset uint) ([]string, []string, error) {
+	pattern := globEscaper.Replace(prefix) + match
+	if !hasLegacy {
+		keys, err := r.scan(pattern, "", limit, offset)
+		return keys, nil, err
+	}
+
+	// limit and offset apply to the keys of both layouts
+	keys, err := r.scan(pattern, "", 0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the records of earlier versions are strings, the records are hashes
+	legacyKeys, err := r.scan(globEscaper.Replace(legacy)+match, "string", 0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// keys without their prefix, mapped to whether they are legacy keys
+	names := make(map[string]bool, len(keys)+len(legacyKeys))
+	for _, k := range keys {
+		names[strings.TrimPrefix(k, prefix)] = false
+	}
+	for _, k := range legacyKeys {
+		name := strings.TrimPrefix(k, legacy)
+		if _, ok := names[name]; !ok {
+			names[name] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	if offset >= uint(len(sorted)) {
+		return nil, nil, nil
+	}
+	sorted = sorted[offset:]
+	if limit > 0 && limit < uint(len(sorted)) {
+		sorted = sorted[:limit]
+	}
+
+	keys, legacyKeys = keys[:0], legacyKeys[:0]
+	for _, name := range sorted {
+		if names[name] {
+			legacyKeys = append(legacyKeys, legacy+name)
+		} else {
+			keys = append(keys, prefix+name)
+		}
+	}
+
+	return keys, legacyKeys, nil
+}
+
+// scan iterates the keys matching pattern with SCAN, skipping the first
+// offset keys and stopping once limit keys are found. A key type only
+// returns the keys of that type.
+func (r *rkv) scan(pattern, keyType string, limit, offset uint) ([]string, error) {
+	// a cluster has to be scanned node by node, so the keys of
+	// every master are gathered before applying limit and offset
+	if c, ok := r.Client.(*redis.ClusterClient); ok {
+		var (
+			mtx  sync.Mutex
+			keys []string
+		)
+
+		err := c.ForEachMaster(r.ctx, func(ctx context.Context, client *redis.Client) error {
+			k, err := scanClient(ctx, client, pattern, keyType, 0, 0)
+			if err != nil {
+				return err
+			}
+			mtx.Lock()
+			keys = append(keys, k...)
+			mtx.Unlock()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(keys)
+
+		if offset >= uint(len(keys)) {
+			return nil, nil
+		}
+		keys = keys[offset:]
+		if limit > 0 && limit < uint(len(keys)) {
+			keys = keys[:limit]
+		}
+		return keys, nil
+	}
+
+	return scanClient(r.ctx, r.Client, pattern, keyType, limit, offset)
+}
+
+func scanClient(ctx context.Context, client redis.Cmdable, pattern, keyType string, limit, offset uint) ([]string, error) {
+	var (
+		cursor  uint64
+		skipped uint
+		keys    []string
+		// SCAN may return a key more than once
+		seen = make(map[string]bool)
+	)
+
+	for {
+		ks, next, err := client.ScanType(ctx, cursor, pattern, ScanCount, keyType).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, k := range ks {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+
+			if skipped < offset {
+				skipped++
+				continue
+			}
+
+			keys = append(keys, k)
+
+			if limit > 0 && uint(len(keys)) >= limit {
+				return keys, nil
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
+
+// prefix returns the namespace of the database and table, falling back to
+// the store options. Without either, keys are not namespaced.
+func (r *rkv) prefix(database, table string) string {
+	if len(database) == 0 {
+		database = r.options.Database
+	}
+	if len(table) == 0 {
+		table = r.options.Table
+	}
+
+	if len(database) == 0 && len(table) == 0 {
+		return ""
+	}
+
+	// an empty database or table keeps its separator, so that
+	// "db::" and ":table:" don't collide
+	return fmt.Sprintf("%s:%s:", database, table)
+}
+
+// legacyPrefix returns the prefix earlier versions of the store wrote the
+// records of the database and table at: the table of the store options,
+// whatever the database and table passed. It's only read for the database
+// and table of the store options, the records of the other namespaces were
+// written there too and can't be told apart.
+func (r *rkv) legacyPrefix(database, table string) (string, bool) {
+	if len(database) > 0 && database != r.options.Database {
+		return "", false
+	}
+	if len(table) > 0 && table != r.options.Table {
+		return "", false
+	}
+
+	// without namespace the layouts only differ by the type of
+	// the keys, which get reads either way
+	if len(r.options.Database) == 0 && len(r.options.Table) == 0 {
+		return "", false
+	}
+
+	return r.options.Table, true
+}
+
 func (r *rkv) Options() store.Options {
 	return r.options
 }
@@ -162,3 +609,16 @@ func (r *rkv) configure() error {
 
 	return nil
 }
+
+// expiry converts a PTTL result, which is negative for keys without
+// expiry, to a record expiry.
+func expiry(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func isWrongType(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
@@ -2,11 +2,12 @@ package redis
 
 import (
 	"context"
-	"os"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 	"go-micro.dev/v4/store"
 )
@@ -176,14 +177,11 @@ func Test_rkv_configure_cluster(t *testing.T) {
 }
 
 func Test_Store(t *testing.T) {
-	if tr := os.Getenv("TRAVIS"); len(tr) > 0 {
-		t.Skip()
-	}
-	r := new(rkv)
+	s := miniredis.RunT(t)
 
-	// r.options = store.Options{Nodes: []string{"redis://:password@127.0.0.1:6379"}}
-	//r.options = store.Options{Nodes: []string{"127.0.0.1:6379"}}
-	r.options = store.Options{Nodes: []string{"redis://127.0.0.1:6379"}}
+	r := new(rkv)
+	r.ctx = context.Background()
+	r.options = store.Options{Nodes: []string{"redis://" + s.Addr()}}
 
 	if err := r.configure(); err != nil {
 		t.Error(err)
@@ -214,3 +212,310 @@ func Test_Store(t *testing.T) {
 		t.Errorf("listing error %v\n", err)
 	}
 }
+
+func Test_StoreNamespaces(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	r := NewStore(store.Nodes("redis://"+s.Addr()), store.Table("t1"))
+
+	for _, k := range []string{"foo/a", "foo/b", "foo/c", "bar/a"} {
+		if err := r.Write(&store.Record{Key: k, Value: []byte(k)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Write(&store.Record{Key: "foo/a", Value: []byte("other")}, store.WriteTo("db", "t2")); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"bar/a", "foo/a", "foo/b", "foo/c"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	keys, err = r.List(store.ListPrefix("foo/"), store.ListSuffix("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"foo/b"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	keys, err = r.List(store.ListLimit(2), store.ListOffset(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 key got %v", keys)
+	}
+
+	keys, err = r.List(store.ListFrom("db", "t2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"foo/a"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	recs, err := r.Read("foo/", store.ReadPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 records got %d", len(recs))
+	}
+
+	recs, err = r.Read("/a", store.ReadSuffix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 records got %d", len(recs))
+	}
+
+	recs, err = r.Read("foo/", store.ReadPrefix(), store.ReadLimit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 records got %d", len(recs))
+	}
+
+	recs, err = r.Read("foo/a", store.ReadFrom("db", "t2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recs[0].Value) != "other" || recs[0].Key != "foo/a" {
+		t.Fatalf("unexpected record %v", recs[0])
+	}
+
+	if _, err := r.Read("missing"); err != store.ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+}
+
+func Test_StoreMetadataAndExpiry(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	r := NewStore(store.Nodes("redis://" + s.Addr()))
+
+	err := r.Write(&store.Record{
+		Key:      "meta",
+		Value:    []byte("bar"),
+		Metadata: map[string]interface{}{"foo": "bar"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := r.Read("meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recs[0].Metadata["foo"] != "bar" {
+		t.Fatalf("expected metadata got %v", recs[0].Metadata)
+	}
+	if recs[0].Expiry != 0 {
+		t.Fatalf("expected no expiry got %v", recs[0].Expiry)
+	}
+
+	if err := r.Write(&store.Record{Key: "ttl", Value: []byte("bar")}, store.WriteTTL(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	recs, err = r.Read("ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recs[0].Expiry <= 0 || recs[0].Expiry > time.Minute {
+		t.Fatalf("unexpected expiry %v", recs[0].Expiry)
+	}
+
+	s.FastForward(2 * time.Minute)
+	if _, err := r.Read("ttl"); err != store.ErrNotFound {
+		t.Fatalf("expected expired record got %v", err)
+	}
+
+	// values written by earlier versions of the store are plain strings
+	if err := s.Set("legacy", "value"); err != nil {
+		t.Fatal(err)
+	}
+	recs, err = r.Read("", store.ReadPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 records got %d", len(recs))
+	}
+}
+
+func Test_StoreLegacyLayout(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	// earlier versions wrote the records at the table and key, as strings
+	if err := s.Set("t1foo", "foo"); err != nil {
+		t.Fatal(err)
+	}
+	s.SetTTL("t1foo", time.Minute)
+	if err := s.Set("t1bar", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("t1baz", "baz"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewStore(store.Nodes("redis://"+s.Addr()), store.Database("db"), store.Table("t1"))
+
+	// written since, supersedes the legacy record
+	if err := r.Write(&store.Record{Key: "bar", Value: []byte("new")}); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"bar", "baz", "foo"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	keys, err = r.List(store.ListLimit(1), store.ListOffset(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"baz"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	recs, err := r.Read("ba", store.ReadPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]string)
+	for _, rec := range recs {
+		values[rec.Key] = string(rec.Value)
+	}
+	if !reflect.DeepEqual(values, map[string]string{"bar": "new", "baz": "baz"}) {
+		t.Fatalf("unexpected records %v", values)
+	}
+
+	// reading a legacy record moves it into the namespace
+	recs, err = r.Read("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recs[0].Value) != "foo" || recs[0].Expiry <= 0 {
+		t.Fatalf("unexpected record %v", recs[0])
+	}
+	if s.Exists("t1foo") {
+		t.Fatal("expected the legacy key to be deleted")
+	}
+	if ttl := s.TTL("db:t1:foo"); ttl <= 0 {
+		t.Fatalf("expected the expiry to be kept got %v", ttl)
+	}
+
+	// deleting a record not migrated yet deletes the legacy key
+	if err := r.Delete("baz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read("baz"); err != store.ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+
+	// the other namespaces don't read the legacy records
+	if _, err := r.Read("bar", store.ReadFrom("db", "t2")); err != store.ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+}
+
+func Test_StorePrefixes(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	r := NewStore(store.Nodes("redis://" + s.Addr()))
+
+	// a database and a table of the same name don't collide
+	if err := r.Write(&store.Record{Key: "k", Value: []byte("db")}, store.WriteTo("x", "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Write(&store.Record{Key: "k", Value: []byte("table")}, store.WriteTo("", "x")); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := r.Read("k", store.ReadFrom("x", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recs[0].Value) != "db" {
+		t.Fatalf("unexpected record %s", recs[0].Value)
+	}
+
+	recs, err = r.Read("k", store.ReadFrom("", "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recs[0].Value) != "table" {
+		t.Fatalf("unexpected record %s", recs[0].Value)
+	}
+
+	// glob characters of a table match themselves only
+	for _, table := range []string{"t*", "tx"} {
+		if err := r.Write(&store.Record{Key: "k", Value: []byte(table)}, store.WriteTo("", table)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := r.List(store.ListFrom("", "t*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"k"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	recs, err = r.Read("", store.ReadFrom("", "t*"), store.ReadPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || string(recs[0].Value) != "t*" {
+		t.Fatalf("unexpected records %v", recs)
+	}
+}
+
+func Test_StoreWriteFenced(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	r := NewStore(store.Nodes("redis://" + s.Addr())).(*rkv)
+
+	write := func(value string, token uint64) bool {
+		written, err := r.WriteFenced(&store.Record{Key: "foo", Value: []byte(value)}, token, store.WriteTTL(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return written
+	}
+
+	// the tokens are compared as decimal numbers, not strings
+	if !write("first", 9) || !write("second", 10) || !write("again", 10) {
+		t.Fatal("expected the newer tokens written")
+	}
+	if write("stale", 9) {
+		t.Fatal("expected the stale token rejected")
+	}
+	if !write("large", 18446744073709551615) {
+		t.Fatal("expected the largest token written")
+	}
+	if write("stale", 18446744073709551614) {
+		t.Fatal("expected the stale large token rejected")
+	}
+
+	recs, err := r.Read("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recs[0].Value) != "large" || recs[0].Expiry <= 0 {
+		t.Fatalf("unexpected record %+v", recs[0])
+	}
+}
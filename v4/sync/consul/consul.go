@@ -138,6 +138,13 @@ func (c *consulSync) Options() sync.Options {
 }
 
 func (c *consulSync) Lock(id string, opts ...sync.LockOption) error {
+	_, err := c.LockWithToken(id, opts...)
+	return err
+}
+
+// LockWithToken acquires a lock returning its fencing token, the modify
+// index of the lock key.
+func (c *consulSync) LockWithToken(id string, opts ...sync.LockOption) (uint64, error) {
 	var options sync.LockOptions
 	for _, o := range opts {
 		o(&options)
@@ -161,19 +168,29 @@ func (c *consulSync) Lock(id string, opts ...sync.LockOption) error {
 	})
 
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	_, err = l.Lock(nil)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	// the key is modified every time the lock is acquired
+	kv, _, err := c.c.KV().Get(key, nil)
+	if err != nil || kv == nil {
+		l.Unlock()
+		if err == nil {
+			err = errors.New("lock not found")
+		}
+		return 0, err
 	}
 
 	c.mtx.Lock()
 	c.locks[id] = l
 	c.mtx.Unlock()
 
-	return nil
+	return kv.ModifyIndex, nil
 }
 
 func (c *consulSync) Unlock(id string) error {
@@ -95,6 +95,13 @@ func (e *etcdSync) Options() sync.Options {
 }
 
 func (e *etcdSync) Lock(id string, opts ...sync.LockOption) error {
+	_, err := e.LockWithToken(id, opts...)
+	return err
+}
+
+// LockWithToken acquires a lock returning its fencing token, the revision
+// the lock was acquired at.
+func (e *etcdSync) LockWithToken(id string, opts ...sync.LockOption) (uint64, error) {
 	var options sync.LockOptions
 	for _, o := range opts {
 		o(&options)
@@ -110,7 +117,7 @@ func (e *etcdSync) Lock(id string, opts ...sync.LockOption) error {
 
 	s, err := cc.NewSession(e.client, sopts...)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	m := cc.NewMutex(s, path)
@@ -122,9 +129,9 @@ func (e *etcdSync) Lock(id string, opts ...sync.LockOption) error {
 		defer cancel()
 	}
 	if err := m.Lock(lockCtx); err != nil && err == context.DeadlineExceeded {
-		return sync.ErrLockTimeout
+		return 0, sync.ErrLockTimeout
 	} else if err != nil {
-		return err
+		return 0, err
 	}
 
 	e.mtx.Lock()
@@ -133,7 +140,7 @@ func (e *etcdSync) Lock(id string, opts ...sync.LockOption) error {
 		m: m,
 	}
 	e.mtx.Unlock()
-	return nil
+	return uint64(m.Header().Revision), nil
 }
 
 func (e *etcdSync) Unlock(id string) error {
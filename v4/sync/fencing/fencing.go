@@ -0,0 +1,45 @@
+// Package fencing provides fencing tokens for sync locks. The token of a lock
+// increases every time it's acquired, so a holder whose lock expired, e.g.
+// during a GC pause, can be told apart from the current holder: its writes
+// carry an older token and are rejected.
+package fencing
+
+import (
+	"errors"
+
+	"go-micro.dev/v4/sync"
+)
+
+var (
+	// ErrNotSupported is returned for sync implementations without fencing tokens.
+	ErrNotSupported = errors.New("fencing tokens not supported")
+	// ErrStaleToken is returned for a token older than the latest seen.
+	ErrStaleToken = errors.New("stale fencing token")
+)
+
+// Locker is implemented by the sync plugins issuing fencing tokens: etcd
+// (the revision the lock was acquired at), consul (the modify index of the
+// lock key), memory and redis (a counter).
+type Locker interface {
+	// LockWithToken acquires a lock returning its fencing token
+	LockWithToken(id string, opts ...sync.LockOption) (uint64, error)
+}
+
+// Lock acquires a lock returning its fencing token.
+func Lock(s sync.Sync, id string, opts ...sync.LockOption) (uint64, error) {
+	l, ok := s.(Locker)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	return l.LockWithToken(id, opts...)
+}
+
+// Validate returns ErrStaleToken if the token is older than the latest
+// token seen by the resource, the holder of the latest token may write
+// more than once.
+func Validate(latest, token uint64) error {
+	if token < latest {
+		return ErrStaleToken
+	}
+	return nil
+}
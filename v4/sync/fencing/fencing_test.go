@@ -0,0 +1,146 @@
+package fencing
+
+import (
+	"math"
+	"testing"
+
+	"go-micro.dev/v4/store"
+	"go-micro.dev/v4/sync"
+)
+
+// counter issues increasing tokens.
+type counter struct {
+	sync.Sync
+	token uint64
+}
+
+func (c *counter) LockWithToken(id string, opts ...sync.LockOption) (uint64, error) {
+	c.token++
+	return c.token, nil
+}
+
+func TestLock(t *testing.T) {
+	token, err := Lock(&counter{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != 1 {
+		t.Fatalf("expected token 1, got %d", token)
+	}
+
+	var s sync.Sync
+	if _, err := Lock(s, "test"); err != ErrNotSupported {
+		t.Fatalf("expected not supported, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(2, 1); err != ErrStaleToken {
+		t.Fatalf("expected stale token, got %v", err)
+	}
+	if err := Validate(2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(2, 3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore(store.NewMemoryStore())
+	c := &counter{}
+
+	stale, _ := Lock(c, "test")
+	current, _ := Lock(c, "test")
+
+	if err := Write(s, &store.Record{Key: "foo", Value: []byte("current")}, current); err != nil {
+		t.Fatal(err)
+	}
+
+	// the holder of the current token may write again
+	if err := Write(s, &store.Record{Key: "foo", Value: []byte("again")}, current); err != nil {
+		t.Fatal(err)
+	}
+
+	// the stale holder can't
+	if err := Write(s, &store.Record{Key: "foo", Value: []byte("stale")}, stale); err != ErrStaleToken {
+		t.Fatalf("expected stale token, got %v", err)
+	}
+
+	recs, err := s.Read("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recs[0].Value) != "again" {
+		t.Fatalf("expected the current holder's write, got %s", recs[0].Value)
+	}
+	if token, ok := Token(recs[0]); !ok || token != current {
+		t.Fatalf("expected token %d, got %d", current, token)
+	}
+
+	// records without a token aren't checked
+	if err := s.Write(&store.Record{Key: "foo", Value: []byte("plain")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestToken(t *testing.T) {
+	for _, v := range []interface{}{uint64(3), 3, int64(3), float64(3), "3"} {
+		token, ok := Token(&store.Record{Metadata: map[string]interface{}{TokenKey: v}})
+		if !ok || token != 3 {
+			t.Fatalf("expected token 3 from %T, got %d %v", v, token, ok)
+		}
+	}
+
+	if _, ok := Token(&store.Record{}); ok {
+		t.Fatal("expected no token")
+	}
+
+	// large tokens keep their precision
+	token, ok := Token(&store.Record{Metadata: map[string]interface{}{TokenKey: "18446744073709551615"}})
+	if !ok || token != math.MaxUint64 {
+		t.Fatalf("expected token %d, got %d %v", uint64(math.MaxUint64), token, ok)
+	}
+	if _, ok := Token(&store.Record{Metadata: map[string]interface{}{TokenKey: float64(1 << 60)}}); ok {
+		t.Fatal("expected no token from an inexact float")
+	}
+}
+
+// casStore compares the tokens itself.
+type casStore struct {
+	store.Store
+
+	writes int
+}
+
+func (c *casStore) WriteFenced(r *store.Record, token uint64, opts ...store.WriteOption) (bool, error) {
+	c.writes++
+
+	recs, err := c.Store.Read(r.Key)
+	if err != nil && err != store.ErrNotFound {
+		return false, err
+	}
+	if len(recs) > 0 {
+		if latest, ok := Token(recs[0]); ok && token < latest {
+			return false, nil
+		}
+	}
+
+	return true, c.Store.Write(r, opts...)
+}
+
+func TestStoreWriter(t *testing.T) {
+	cas := &casStore{Store: store.NewMemoryStore()}
+	s := NewStore(cas)
+
+	if err := Write(s, &store.Record{Key: "foo", Value: []byte("current")}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(s, &store.Record{Key: "foo", Value: []byte("stale")}, 1); err != ErrStaleToken {
+		t.Fatalf("expected stale token, got %v", err)
+	}
+
+	if cas.writes != 2 {
+		t.Fatalf("expected the writes compared by the store, got %d", cas.writes)
+	}
+}
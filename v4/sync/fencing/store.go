@@ -0,0 +1,131 @@
+package fencing
+
+import (
+	"encoding/json"
+	"strconv"
+	gosync "sync"
+
+	"go-micro.dev/v4/store"
+)
+
+// TokenKey is the record metadata key the fencing token is written under.
+const TokenKey = "fencing_token"
+
+// maxFloatToken is the greatest token a float64 holds exactly.
+const maxFloatToken = 1 << 53
+
+// Writer is implemented by the stores comparing the fencing token of a
+// record with the token of the stored record and writing it atomically,
+// e.g. the redis store.
+type Writer interface {
+	// WriteFenced writes the record with its token unless the stored
+	// record has a greater token, returning whether it was written.
+	WriteFenced(r *store.Record, token uint64, opts ...store.WriteOption) (bool, error)
+}
+
+// NewStore returns a store rejecting writes of records whose fencing token
+// is older than the token of the stored record with ErrStaleToken. Records
+// without a token are written as is.
+//
+// The token is compared and the record written atomically by stores
+// implementing Writer. Other stores are read then written, which is only
+// atomic within the process: processes sharing such a store may still
+// overwrite a newer record with a stale one.
+func NewStore(s store.Store) store.Store {
+	return &fencedStore{Store: s}
+}
+
+// Write the record with the fencing token.
+func Write(s store.Store, r *store.Record, token uint64, opts ...store.WriteOption) error {
+	md := make(map[string]interface{}, len(r.Metadata)+1)
+	for k, v := range r.Metadata {
+		md[k] = v
+	}
+	// a string isn't decoded as a float64 by the stores encoding the
+	// metadata as JSON, which would lose the precision of large tokens
+	md[TokenKey] = strconv.FormatUint(token, 10)
+
+	rec := *r
+	rec.Metadata = md
+
+	return s.Write(&rec, opts...)
+}
+
+// Token returns the fencing token of the record.
+func Token(r *store.Record) (uint64, bool) {
+	if r == nil || r.Metadata == nil {
+		return 0, false
+	}
+
+	// the token may have been decoded by the store
+	switch v := r.Metadata[TokenKey].(type) {
+	case uint64:
+		return v, true
+	case int:
+		return uint64(v), v >= 0
+	case int64:
+		return uint64(v), v >= 0
+	case float64:
+		// only the tokens a float64 holds exactly
+		return uint64(v), v >= 0 && v <= maxFloatToken && v == float64(uint64(v))
+	case json.Number:
+		t, err := strconv.ParseUint(v.String(), 10, 64)
+		return t, err == nil
+	case string:
+		t, err := strconv.ParseUint(v, 10, 64)
+		return t, err == nil
+	}
+
+	return 0, false
+}
+
+type fencedStore struct {
+	store.Store
+
+	mtx gosync.Mutex
+}
+
+func (f *fencedStore) Write(r *store.Record, opts ...store.WriteOption) error {
+	token, ok := Token(r)
+	if !ok {
+		return f.Store.Write(r, opts...)
+	}
+
+	if w, ok := f.Store.(Writer); ok {
+		written, err := w.WriteFenced(r, token, opts...)
+		if err != nil {
+			return err
+		}
+		if !written {
+			return ErrStaleToken
+		}
+		return nil
+	}
+
+	var options store.WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// only atomic within the process, see NewStore
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	recs, err := f.Store.Read(r.Key, store.ReadFrom(options.Database, options.Table))
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	for _, rec := range recs {
+		if rec.Key != r.Key {
+			continue
+		}
+		if latest, ok := Token(rec); ok {
+			if err := Validate(latest, token); err != nil {
+				return err
+			}
+		}
+	}
+
+	return f.Store.Write(r, opts...)
+}
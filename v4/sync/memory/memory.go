@@ -8,44 +8,64 @@ import (
 	"go-micro.dev/v4/sync"
 )
 
+// leaderPrefix separates the leases of leaders from locks of the same id.
+const leaderPrefix = "leader/"
+
 type memorySync struct {
 	options sync.Options
 
 	mtx   gosync.RWMutex
 	locks map[string]*memoryLock
+	// last fencing token issued
+	token uint64
 }
 
 type memoryLock struct {
 	id      string
-	time    time.Time
+	token   uint64
 	ttl     time.Duration
+	timer   *time.Timer
 	release chan bool
 }
 
 type memoryLeader struct {
-	opts   sync.LeaderOptions
-	id     string
-	resign func(id string) error
-	status chan bool
+	opts sync.LeaderOptions
+	id   string
+	m    *memorySync
+	lock *memoryLock
 }
 
+// Resign leadership, the status channels fire.
 func (m *memoryLeader) Resign() error {
-	return m.resign(m.id)
+	m.m.mtx.Lock()
+	m.m.remove(m.lock)
+	m.m.mtx.Unlock()
+	return nil
 }
 
+// Status returns a channel receiving true when leadership is lost, either
+// resigned or expired.
 func (m *memoryLeader) Status() chan bool {
-	return m.status
+	ch := make(chan bool, 1)
+
+	go func() {
+		<-m.lock.release
+		ch <- true
+		close(ch)
+	}()
+
+	return ch
 }
 
 func (m *memorySync) Leader(id string, opts ...sync.LeaderOption) (sync.Leader, error) {
-	var once gosync.Once
 	var options sync.LeaderOptions
 	for _, o := range opts {
 		o(&options)
 	}
 
-	// acquire a lock for the id
-	if err := m.Lock(id); err != nil {
+	// campaign until the lease of the id is acquired
+	lk, err := m.acquire(leaderPrefix+id, 0, 0)
+	if err != nil {
 		return nil, err
 	}
 
@@ -53,14 +73,8 @@ func (m *memorySync) Leader(id string, opts ...sync.LeaderOption) (sync.Leader,
 	return &memoryLeader{
 		opts: options,
 		id:   id,
-		resign: func(id string) error {
-			once.Do(func() {
-				m.Unlock(id)
-			})
-			return nil
-		},
-		// TODO: signal when Unlock is called
-		status: make(chan bool, 1),
+		m:    m,
+		lock: lk,
 	}, nil
 }
 
@@ -76,113 +90,131 @@ func (m *memorySync) Options() sync.Options {
 }
 
 func (m *memorySync) Lock(id string, opts ...sync.LockOption) error {
-	// lock our access
-	m.mtx.Lock()
+	var options sync.LockOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	_, err := m.acquire(id, options.TTL, options.Wait)
+	return err
+}
 
+// LockWithToken acquires a lock returning its fencing token, a counter
+// increased every time a lock is acquired.
+func (m *memorySync) LockWithToken(id string, opts ...sync.LockOption) (uint64, error) {
 	var options sync.LockOptions
 	for _, o := range opts {
 		o(&options)
 	}
 
+	lk, err := m.acquire(id, options.TTL, options.Wait)
+	if err != nil {
+		return 0, err
+	}
+	return lk.token, nil
+}
+
+func (m *memorySync) Unlock(id string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	lk, ok := m.locks[id]
+	// no lock exists
 	if !ok {
-		m.locks[id] = &memoryLock{
-			id:      id,
-			time:    time.Now(),
-			ttl:     options.TTL,
-			release: make(chan bool),
-		}
-		// unlock
-		m.mtx.Unlock()
 		return nil
 	}
 
-	m.mtx.Unlock()
+	m.remove(lk)
+	return nil
+}
 
-	// set wait time
-	var wait <-chan time.Time
-	var ttl <-chan time.Time
+// acquire the lock of the id, waiting for it to be released or expire. A
+// zero wait waits until the lock is acquired.
+func (m *memorySync) acquire(id string, ttl, wait time.Duration) (*memoryLock, error) {
+	var deadline <-chan time.Time
 
 	// decide if we should wait
-	if options.Wait > time.Duration(0) {
-		wait = time.After(options.Wait)
+	if wait > time.Duration(0) {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		deadline = t.C
 	}
 
-	// check the ttl of the lock
-	if lk.ttl > time.Duration(0) {
-		// time lived for the lock
-		live := time.Since(lk.time)
-
-		// set a timer for the leftover ttl
-		if live > lk.ttl {
-			// release the lock if it expired
-			_ = m.Unlock(id)
-		} else {
-			ttl = time.After(live)
-		}
-	}
-
-lockLoop:
 	for {
-		// wait for the lock to be released
-		select {
-		case <-lk.release:
-			m.mtx.Lock()
+		m.mtx.Lock()
 
-			// someone locked before us
-			lk, ok = m.locks[id]
-			if ok {
-				m.mtx.Unlock()
-				continue
-			}
-
-			// got chance to lock
-			m.locks[id] = &memoryLock{
+		lk, ok := m.locks[id]
+		if !ok {
+			m.token++
+			lk = &memoryLock{
 				id:      id,
-				time:    time.Now(),
-				ttl:     options.TTL,
+				token:   m.token,
+				ttl:     ttl,
 				release: make(chan bool),
 			}
 
+			// expire the lock once its ttl elapsed
+			if ttl > time.Duration(0) {
+				lk.timer = time.AfterFunc(ttl, func() {
+					m.mtx.Lock()
+					m.remove(lk)
+					m.mtx.Unlock()
+				})
+			}
+
+			m.locks[id] = lk
 			m.mtx.Unlock()
+			return lk, nil
+		}
+
+		m.mtx.Unlock()
 
-			break lockLoop
-		case <-ttl:
-			// ttl exceeded
-			_ = m.Unlock(id)
-			// TODO: check the ttl again above
-			ttl = nil
-			// try acquire
-			continue
-		case <-wait:
-			return sync.ErrLockTimeout
+		// wait for the lock to be released or expire
+		select {
+		case <-lk.release:
+		case <-deadline:
+			return nil, sync.ErrLockTimeout
 		}
 	}
-
-	return nil
 }
 
-func (m *memorySync) Unlock(id string) error {
-	m.mtx.Lock()
-	defer m.mtx.Unlock()
+// remove the lock if it's still held, releasing its waiters. The mutex
+// must be held.
+func (m *memorySync) remove(lk *memoryLock) bool {
+	if m.locks[lk.id] != lk {
+		return false
+	}
 
-	lk, ok := m.locks[id]
-	// no lock exists
+	delete(m.locks, lk.id)
+
+	if lk.timer != nil {
+		lk.timer.Stop()
+	}
+	close(lk.release)
+
+	return true
+}
+
+// Expire releases the lock or leadership of the id as if its lease expired,
+// simulating a partition from the backend. The leader's status fires and
+// the next waiter acquires it. It reports whether anything was expired.
+func Expire(s sync.Sync, id string) bool {
+	m, ok := s.(*memorySync)
 	if !ok {
-		return nil
+		return false
 	}
 
-	// delete the lock
-	delete(m.locks, id)
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
 
-	select {
-	case <-lk.release:
-		return nil
-	default:
-		close(lk.release)
+	var expired bool
+	for _, k := range []string{id, leaderPrefix + id} {
+		if lk, ok := m.locks[k]; ok && m.remove(lk) {
+			expired = true
+		}
 	}
 
-	return nil
+	return expired
 }
 
 func (m *memorySync) String() string {
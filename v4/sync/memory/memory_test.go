@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/sync"
+)
+
+func TestLockTTL(t *testing.T) {
+	s := NewSync()
+
+	if err := s.Lock("test", sync.LockTTL(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Lock("test", sync.LockWait(20*time.Millisecond)); err != sync.ErrLockTimeout {
+		t.Fatalf("expected lock timeout, got %v", err)
+	}
+
+	// acquired once the ttl elapsed
+	start := time.Now()
+	if err := s.Lock("test", sync.LockWait(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("expected the lock to expire after its ttl, waited %v", d)
+	}
+
+	if err := s.Unlock("test"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLeaderStatus(t *testing.T) {
+	s := NewSync()
+
+	l, err := s.Leader("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// leader and lock of the same id are separate
+	if err := s.Lock("test", sync.LockWait(20*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	elected := make(chan sync.Leader, 1)
+	go func() {
+		l, _ := s.Leader("test")
+		elected <- l
+	}()
+
+	status := l.Status()
+
+	if err := l.Resign(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case lost := <-status:
+		if !lost {
+			t.Fatal("expected leadership lost")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected status to fire on resign")
+	}
+
+	var next sync.Leader
+	select {
+	case next = <-elected:
+	case <-time.After(time.Second):
+		t.Fatal("expected the candidate to be elected")
+	}
+
+	// resigning again doesn't affect the new leader
+	if err := l.Resign(); err != nil {
+		t.Fatal(err)
+	}
+
+	status = next.Status()
+	select {
+	case <-status:
+		t.Fatal("unexpected status")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// simulate a partition
+	if !Expire(s, "test") {
+		t.Fatal("expected the leader to expire")
+	}
+
+	select {
+	case <-status:
+	case <-time.After(time.Second):
+		t.Fatal("expected status to fire on expiry")
+	}
+
+	if Expire(s, "test") {
+		t.Fatal("expected nothing to expire")
+	}
+}
+
+func TestLockWithToken(t *testing.T) {
+	s := NewSync().(*memorySync)
+
+	first, err := s.LockWithToken("test", sync.LockTTL(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lease expired while the first holder was paused
+	second, err := s.LockWithToken("test", sync.LockWait(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second <= first {
+		t.Fatalf("expected an increasing token, got %d after %d", second, first)
+	}
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go-micro.dev/v4/sync"
+)
+
+type redisOptionsContextKey struct{}
+type leaderTTLKey struct{}
+
+// WithRedisOptions sets advanced options for redis.
+func WithRedisOptions(options redis.UniversalOptions) sync.Option {
+	return setSyncOption(redisOptionsContextKey{}, options)
+}
+
+// LeaderTTL sets the lease of the leaders, it's renewed while leading. The
+// default is DefaultLeaderTTL.
+func LeaderTTL(d time.Duration) sync.Option {
+	return setSyncOption(leaderTTLKey{}, d)
+}
+
+func setSyncOption(k, v interface{}) sync.Option {
+	return func(o *sync.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+func newUniversalClient(o sync.Options) redis.UniversalClient {
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+
+	opts, ok := o.Context.Value(redisOptionsContextKey{}).(redis.UniversalOptions)
+	if !ok && len(o.Nodes) <= 1 {
+		addr := "redis://127.0.0.1:6379"
+		if len(o.Nodes) > 0 {
+			addr = o.Nodes[0]
+		}
+
+		redisOptions, err := redis.ParseURL(addr)
+		if err != nil {
+			redisOptions = &redis.Options{Addr: addr}
+		}
+		if o.TLSConfig != nil {
+			redisOptions.TLSConfig = o.TLSConfig
+		}
+
+		return redis.NewClient(redisOptions)
+	}
+
+	if len(opts.Addrs) == 0 && len(o.Nodes) > 0 {
+		opts.Addrs = o.Nodes
+	}
+	if opts.TLSConfig == nil {
+		opts.TLSConfig = o.TLSConfig
+	}
+
+	return redis.NewUniversalClient(&opts)
+}
@@ -0,0 +1,355 @@
+// Package redis is a redis implementation of sync
+package redis
+
+import (
+	"context"
+	"errors"
+	gosync "sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	log "go-micro.dev/v4/logger"
+	"go-micro.dev/v4/sync"
+)
+
+var (
+	// DefaultLockTTL is the lease of locks without a TTL, it's renewed
+	// while the lock is held.
+	DefaultLockTTL = 30 * time.Second
+	// DefaultLeaderTTL is the lease of leaders, it's renewed while leading.
+	DefaultLeaderTTL = 15 * time.Second
+
+	// ErrLockNotFound is returned when unlocking a lock which isn't held.
+	ErrLockNotFound = errors.New("lock not found")
+	// ErrLockLost is returned when unlocking a lock whose lease was lost.
+	ErrLockLost = errors.New("lock lost")
+
+	// maximum time waiting for a lock between attempts, in case the
+	// release or expiry of the lock is missed
+	maxRetry = time.Second
+)
+
+var (
+	// sets the key if it doesn't exist and returns the next fencing token
+	lockScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+
+	// deletes the key if it holds the token
+	unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	// renews the lease of the key if it holds the token
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+type redisSync struct {
+	options   sync.Options
+	client    redis.UniversalClient
+	prefix    string
+	leaderTTL time.Duration
+
+	mtx   gosync.Mutex
+	locks map[string]*lease
+}
+
+// lease is a key held with a token and renewed until released or lost.
+type lease struct {
+	key   string
+	token string
+	ttl   time.Duration
+	// fencing token
+	fence uint64
+
+	stop chan bool
+	lost chan bool
+	once gosync.Once
+}
+
+type redisLeader struct {
+	s     *redisSync
+	l     *lease
+	opts  sync.LeaderOptions
+	once  gosync.Once
+	error error
+}
+
+func (r *redisSync) Leader(id string, opts ...sync.LeaderOption) (sync.Leader, error) {
+	var options sync.LeaderOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// campaign until elected
+	l, err := r.acquire(r.prefix+"leader:"+r.options.Prefix+id, r.leaderTTL, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redisLeader{
+		s:    r,
+		l:    l,
+		opts: options,
+	}, nil
+}
+
+// Status returns a channel receiving true when leadership is lost, either
+// resigned or when the lease couldn't be renewed.
+func (r *redisLeader) Status() chan bool {
+	ch := make(chan bool, 1)
+
+	go func() {
+		<-r.l.lost
+		ch <- true
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (r *redisLeader) Resign() error {
+	r.once.Do(func() {
+		r.error = r.s.release(r.l)
+	})
+	if r.error == ErrLockLost {
+		return nil
+	}
+	return r.error
+}
+
+func (r *redisSync) Init(opts ...sync.Option) error {
+	for _, o := range opts {
+		o(&r.options)
+	}
+	return r.configure()
+}
+
+func (r *redisSync) Options() sync.Options {
+	return r.options
+}
+
+func (r *redisSync) Lock(id string, opts ...sync.LockOption) error {
+	_, err := r.LockWithToken(id, opts...)
+	return err
+}
+
+// LockWithToken acquires a lock returning its fencing token, a counter
+// increased every time the lock is acquired.
+func (r *redisSync) LockWithToken(id string, opts ...sync.LockOption) (uint64, error) {
+	var options sync.LockOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	ttl := options.TTL
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+
+	l, err := r.acquire(r.prefix+"lock:"+r.options.Prefix+id, ttl, options.Wait)
+	if err != nil {
+		return 0, err
+	}
+
+	r.mtx.Lock()
+	r.locks[id] = l
+	r.mtx.Unlock()
+
+	return l.fence, nil
+}
+
+func (r *redisSync) Unlock(id string) error {
+	r.mtx.Lock()
+	l, ok := r.locks[id]
+	delete(r.locks, id)
+	r.mtx.Unlock()
+
+	if !ok {
+		return ErrLockNotFound
+	}
+
+	return r.release(l)
+}
+
+func (r *redisSync) String() string {
+	return "redis"
+}
+
+// acquire the key, waiting for it to be released or expire. A zero wait
+// waits until the key is acquired.
+func (r *redisSync) acquire(key string, ttl, wait time.Duration) (*lease, error) {
+	ctx := context.Background()
+	token := uuid.New().String()
+
+	// the key and its fencing counter are hashed to the same cluster
+	// slot, lockScript uses both
+	key = "{" + key + "}"
+
+	// subscribe before trying so a release isn't missed
+	sub := r.client.Subscribe(ctx, channel(key))
+	defer sub.Close()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return nil, err
+	}
+	released := sub.Channel()
+
+	var deadline <-chan time.Time
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		deadline = t.C
+	}
+
+	for {
+		fence, err := lockScript.Run(ctx, r.client, []string{key, key + ":fence"}, token, ttl.Milliseconds()).Int64()
+		if err != nil {
+			return nil, err
+		}
+
+		if fence > 0 {
+			l := &lease{
+				key:   key,
+				token: token,
+				ttl:   ttl,
+				fence: uint64(fence),
+				stop:  make(chan bool),
+				lost:  make(chan bool),
+			}
+			go r.renew(l)
+			return l, nil
+		}
+
+		// wait for the key to be released or expire
+		retry, err := r.client.PTTL(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		if retry <= 0 || retry > maxRetry {
+			retry = maxRetry
+		}
+
+		t := time.NewTimer(retry)
+
+		select {
+		case <-released:
+		case <-t.C:
+		case <-deadline:
+			t.Stop()
+			return nil, sync.ErrLockTimeout
+		}
+
+		t.Stop()
+	}
+}
+
+// renew the lease until it's released, or lost when the key no longer
+// holds the token or couldn't be renewed within the ttl.
+func (r *redisSync) renew(l *lease) {
+	t := time.NewTicker(l.ttl / 3)
+	defer t.Stop()
+
+	renewed := time.Now()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+		}
+
+		res, err := renewScript.Run(context.Background(), r.client, []string{l.key}, l.token, l.ttl.Milliseconds()).Int()
+		if err == nil && res == 1 {
+			renewed = time.Now()
+			continue
+		}
+
+		if err != nil && time.Since(renewed) < l.ttl {
+			log.Errorf("Error renewing lease of %s: %v", l.key, err)
+			continue
+		}
+
+		l.once.Do(func() {
+			close(l.lost)
+		})
+		return
+	}
+}
+
+// release the lease and wake up the waiters, a lease is released once.
+func (r *redisSync) release(l *lease) error {
+	close(l.stop)
+
+	var lost bool
+	select {
+	case <-l.lost:
+		// renewal gave up
+		lost = true
+	default:
+		l.once.Do(func() {
+			close(l.lost)
+		})
+	}
+
+	ctx := context.Background()
+
+	res, err := unlockScript.Run(ctx, r.client, []string{l.key}, l.token).Int()
+	if err != nil {
+		return err
+	}
+	if res == 0 || lost {
+		return ErrLockLost
+	}
+
+	return r.client.Publish(ctx, channel(l.key), l.token).Err()
+}
+
+func (r *redisSync) configure() error {
+	if r.client != nil {
+		r.client.Close()
+	}
+	r.client = newUniversalClient(r.options)
+
+	r.leaderTTL = DefaultLeaderTTL
+	if r.options.Context != nil {
+		if d, ok := r.options.Context.Value(leaderTTLKey{}).(time.Duration); ok && d > 0 {
+			r.leaderTTL = d
+		}
+	}
+
+	return nil
+}
+
+// channel the release of the key is published on.
+func channel(key string) string {
+	return key + ":released"
+}
+
+func NewSync(opts ...sync.Option) sync.Sync {
+	var options sync.Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	r := &redisSync{
+		options: options,
+		prefix:  "micro:sync:",
+		locks:   make(map[string]*lease),
+	}
+
+	if err := r.configure(); err != nil {
+		log.Fatal(err)
+	}
+
+	return r
+}
@@ -0,0 +1,242 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go-micro.dev/v4/sync"
+)
+
+func newSync(t *testing.T, opts ...sync.Option) (sync.Sync, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	opts = append([]sync.Option{sync.Nodes("redis://" + mr.Addr())}, opts...)
+	return NewSync(opts...), mr
+}
+
+func TestLock(t *testing.T) {
+	s, mr := newSync(t)
+
+	if err := s.Lock("test", sync.LockTTL(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !mr.Exists("{micro:sync:lock:test}") {
+		t.Fatal("expected the lock key")
+	}
+
+	// held by another instance
+	other := NewSync(sync.Nodes("redis://" + mr.Addr()))
+	if err := other.Lock("test", sync.LockWait(100*time.Millisecond)); err != sync.ErrLockTimeout {
+		t.Fatalf("expected lock timeout, got %v", err)
+	}
+
+	// a release of another instance is ignored
+	if err := other.Unlock("test"); err != ErrLockNotFound {
+		t.Fatalf("expected lock not found, got %v", err)
+	}
+
+	if err := s.Unlock("test"); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("{micro:sync:lock:test}") {
+		t.Fatal("expected the lock key to be deleted")
+	}
+
+	if err := other.Lock("test", sync.LockWait(100*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockReleaseChecksToken(t *testing.T) {
+	s, mr := newSync(t)
+
+	if err := s.Lock("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lease expired and the lock was taken by another instance
+	mr.Set("{micro:sync:lock:test}", "other")
+
+	if err := s.Unlock("test"); err != ErrLockLost {
+		t.Fatalf("expected lock lost, got %v", err)
+	}
+	if v, _ := mr.Get("{micro:sync:lock:test}"); v != "other" {
+		t.Fatalf("expected the lock of the other instance to be kept, got %s", v)
+	}
+}
+
+func TestLockWaitWakeup(t *testing.T) {
+	s, _ := newSync(t)
+
+	if err := s.Lock("test", sync.LockTTL(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	errCh := make(chan error, 1)
+	start := time.Now()
+
+	go func() {
+		errCh <- NewSync(sync.Nodes(s.Options().Nodes...)).Lock("test", sync.LockWait(5*time.Second))
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := s.Unlock("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the lock")
+	}
+
+	// woken up by the release rather than polling
+	if d := time.Since(start); d > 800*time.Millisecond {
+		t.Fatalf("expected to be woken up by the release, took %v", d)
+	}
+}
+
+func TestLockRenewal(t *testing.T) {
+	s, mr := newSync(t)
+
+	if err := s.Lock("test", sync.LockTTL(300*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	mr.FastForward(250 * time.Millisecond)
+	time.Sleep(250 * time.Millisecond)
+
+	if ttl := mr.TTL("{micro:sync:lock:test}"); ttl <= 100*time.Millisecond {
+		t.Fatalf("expected the lease to be renewed, got %v", ttl)
+	}
+
+	if err := s.Unlock("test"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLeader(t *testing.T) {
+	s, mr := newSync(t, LeaderTTL(300*time.Millisecond))
+
+	l, err := s.Leader("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second candidate waits for the leader to resign
+	elected := make(chan sync.Leader, 1)
+	go func() {
+		l, err := NewSync(sync.Nodes("redis://"+mr.Addr()), LeaderTTL(300*time.Millisecond)).Leader("test")
+		if err != nil {
+			t.Error(err)
+		}
+		elected <- l
+	}()
+
+	select {
+	case <-elected:
+		t.Fatal("elected while another candidate leads")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	status := l.Status()
+
+	if err := l.Resign(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-status:
+	case <-time.After(time.Second):
+		t.Fatal("expected status to fire on resign")
+	}
+
+	var next sync.Leader
+	select {
+	case next = <-elected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the candidate to be elected")
+	}
+
+	// leadership is lost when the lease can't be renewed
+	status = next.Status()
+	mr.Del("{micro:sync:leader:test}")
+
+	select {
+	case lost := <-status:
+		if !lost {
+			t.Fatal("expected leadership lost")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected status to fire when the lease is lost")
+	}
+
+	if err := next.Resign(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockWithToken(t *testing.T) {
+	s, mr := newSync(t)
+	r := s.(*redisSync)
+
+	first, err := r.LockWithToken("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lease expired while the first holder was paused
+	mr.Del("{micro:sync:lock:test}")
+
+	second, err := NewSync(sync.Nodes("redis://" + mr.Addr())).(*redisSync).LockWithToken("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second <= first {
+		t.Fatalf("expected an increasing token, got %d after %d", second, first)
+	}
+}
+
+func TestLockCluster(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	// a cluster client, the addresses are the nodes of the cluster
+	s := NewSync(WithRedisOptions(redis.UniversalOptions{Addrs: []string{mr.Addr(), mr.Addr()}}))
+	if _, ok := s.(*redisSync).client.(*redis.ClusterClient); !ok {
+		t.Fatalf("expected a cluster client, got %T", s.(*redisSync).client)
+	}
+
+	if err := s.Lock("test"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Unlock("test")
+
+	// the lock script uses both keys, they must be in the same slot
+	c := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer c.Close()
+
+	var slots []int64
+	for _, key := range []string{"{micro:sync:lock:test}", "{micro:sync:lock:test}:fence"} {
+		if !mr.Exists(key) {
+			t.Fatalf("expected %s to exist", key)
+		}
+
+		slot, err := c.Do(context.Background(), "CLUSTER", "KEYSLOT", key).Int64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		slots = append(slots, slot)
+	}
+
+	if slots[0] != slots[1] {
+		t.Fatalf("expected the keys in the same slot, got %v", slots)
+	}
+}
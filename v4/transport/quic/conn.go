@@ -0,0 +1,142 @@
+package transport
+
+import (
+	"context"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+// quicConn is a connection shared by the clients dialed to an address, each
+// client has its own stream.
+type quicConn struct {
+	addr string
+
+	// closed once dialed, sess or err is set then
+	ready chan struct{}
+	sess  quic.Session
+	err   error
+
+	// guarded by the transport lock
+	streams int
+	idle    *time.Timer
+}
+
+// closed reports whether the connection failed or was closed.
+func (c *quicConn) closed() bool {
+	select {
+	case <-c.ready:
+		return c.err != nil || c.sess.Context().Err() != nil
+	default:
+		return false
+	}
+}
+
+// conn returns the connection to the address, dialing it within the timeout
+// if there's none. Clients dialing an address at the same time share the
+// handshake.
+func (q *quicTransport) conn(ctx context.Context, addr string, timeout time.Duration) (*quicConn, error) {
+	q.Lock()
+	c, ok := q.conns[addr]
+	if !ok || c.closed() {
+		c = &quicConn{addr: addr, ready: make(chan struct{})}
+		q.conns[addr] = c
+		go q.connect(c, timeout)
+	}
+	c.streams++
+	if c.idle != nil {
+		c.idle.Stop()
+		c.idle = nil
+	}
+	q.Unlock()
+
+	select {
+	case <-c.ready:
+	case <-ctx.Done():
+		q.release(c)
+		return nil, ctx.Err()
+	}
+
+	if c.err != nil {
+		q.release(c)
+		return nil, c.err
+	}
+
+	return c, nil
+}
+
+func (q *quicTransport) connect(c *quicConn, timeout time.Duration) {
+	// the handshake isn't bound to the client dialing first, only to its
+	// timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if zeroRTT(q.opts) {
+		c.sess, c.err = quic.DialAddrEarlyContext(ctx, c.addr, q.dialTLS(c.addr), q.config)
+	} else {
+		c.sess, c.err = quic.DialAddrContext(ctx, c.addr, q.dialTLS(c.addr), q.config)
+	}
+
+	close(c.ready)
+
+	q.Lock()
+	defer q.Unlock()
+
+	if c.err != nil {
+		if q.conns[c.addr] == c {
+			delete(q.conns, c.addr)
+		}
+		return
+	}
+
+	// the clients dialing gave up
+	if c.streams == 0 && c.idle == nil {
+		q.closeIdle(c)
+	}
+}
+
+// release a stream of the connection, the connection is closed once none of
+// its streams were used for the idle timeout.
+func (q *quicTransport) release(c *quicConn) {
+	q.Lock()
+	defer q.Unlock()
+
+	c.streams--
+	if c.streams > 0 || c.closed() {
+		return
+	}
+
+	select {
+	case <-c.ready:
+	default:
+		// the dial in progress is left to the clients waiting for it
+		return
+	}
+
+	q.closeIdle(c)
+}
+
+// closeIdle closes the connection after the idle timeout unless a client
+// dials it, the transport lock is held.
+func (q *quicTransport) closeIdle(c *quicConn) {
+	c.idle = time.AfterFunc(q.idleTimeout(), func() {
+		q.Lock()
+		if c.streams > 0 {
+			q.Unlock()
+			return
+		}
+		if q.conns[c.addr] == c {
+			delete(q.conns, c.addr)
+		}
+		q.Unlock()
+
+		c.sess.CloseWithError(0, "idle")
+	})
+}
+
+func (q *quicTransport) idleTimeout() time.Duration {
+	if q.config.MaxIdleTimeout > 0 {
+		return q.config.MaxIdleTimeout
+	}
+	return defaultIdleTimeout
+}
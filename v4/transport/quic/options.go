@@ -0,0 +1,164 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"go-micro.dev/v4/transport"
+)
+
+type quicConfigKey struct{}
+type idleTimeoutKey struct{}
+type maxStreamsKey struct{}
+type keepAliveKey struct{}
+type zeroRTTKey struct{}
+
+func setOption(k, v interface{}) transport.Option {
+	return func(o *transport.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+// Config sets the base quic config of the connections dialed and accepted,
+// the other options are applied on top of it.
+func Config(c *quic.Config) transport.Option {
+	return setOption(quicConfigKey{}, c)
+}
+
+// IdleTimeout sets how long a connection stays open without network
+// activity, two minutes by default. A dialed connection is also closed once
+// none of its streams were used for that long.
+func IdleTimeout(d time.Duration) transport.Option {
+	return setOption(idleTimeoutKey{}, d)
+}
+
+// MaxStreams sets the number of concurrent streams a peer may open on a
+// connection, 100 by default. Dialing blocks while the limit is reached.
+func MaxStreams(n int64) transport.Option {
+	return setOption(maxStreamsKey{}, n)
+}
+
+// KeepAlive sets whether connections are kept alive by pinging the peer,
+// true by default.
+func KeepAlive(b bool) transport.Option {
+	return setOption(keepAliveKey{}, b)
+}
+
+// ZeroRTT sends the first messages on a resumed connection with the
+// handshake, saving a round trip. The messages sent that way can be replayed
+// by an attacker, only enable it for idempotent services. With MutualTLS the
+// listeners pass them to the handlers once the handshake completed and the
+// client certificate is verified.
+func ZeroRTT() transport.Option {
+	return setOption(zeroRTTKey{}, true)
+}
+
+// quicConfig returns the quic config of the options.
+func quicConfig(opts transport.Options) *quic.Config {
+	config := &quic.Config{
+		MaxIdleTimeout: time.Minute * 2,
+		KeepAlive:      true,
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		return config
+	}
+
+	if c, ok := ctx.Value(quicConfigKey{}).(*quic.Config); ok && c != nil {
+		config = c.Clone()
+	}
+	if d, ok := ctx.Value(idleTimeoutKey{}).(time.Duration); ok {
+		config.MaxIdleTimeout = d
+	}
+	if n, ok := ctx.Value(maxStreamsKey{}).(int64); ok {
+		config.MaxIncomingStreams = n
+	}
+	if b, ok := ctx.Value(keepAliveKey{}).(bool); ok {
+		config.KeepAlive = b
+	}
+
+	return config
+}
+
+func zeroRTT(opts transport.Options) bool {
+	if opts.Context == nil {
+		return false
+	}
+	b, _ := opts.Context.Value(zeroRTTKey{}).(bool)
+	return b
+}
+
+type rootCAsKey struct{}
+type certificateKey struct{}
+type clientCAsKey struct{}
+type serverNameKey struct{}
+type pinsKey struct{}
+type insecureKey struct{}
+
+// RootCAs sets the certificate authorities verifying the servers dialed, the
+// system ones by default.
+func RootCAs(pool *x509.CertPool) transport.Option {
+	return setOption(rootCAsKey{}, pool)
+}
+
+// CAFile sets the PEM bundle of the certificate authorities verifying the
+// servers dialed. Dialing fails if the file can't be read.
+func CAFile(path string) transport.Option {
+	pool := x509.NewCertPool()
+	b, err := ioutil.ReadFile(path)
+	if err == nil && !pool.AppendCertsFromPEM(b) {
+		err = fmt.Errorf("no certificates in %s", path)
+	}
+	if err != nil {
+		return setOption(rootCAsKey{}, err)
+	}
+	return RootCAs(pool)
+}
+
+// Certificate sets the certificate presented by the listeners, and by the
+// clients to servers requiring mutual TLS.
+func Certificate(cert tls.Certificate) transport.Option {
+	return setOption(certificateKey{}, cert)
+}
+
+// MutualTLS requires the clients to present a certificate issued by one of
+// the authorities of the pool. The identity of the client certificate is
+// passed to the handlers in the PeerIdentityHeader metadata.
+func MutualTLS(clientCAs *x509.CertPool) transport.Option {
+	return setOption(clientCAsKey{}, clientCAs)
+}
+
+// ServerName sets the name the certificates of the servers dialed are
+// verified against, the host of the address by default.
+func ServerName(name string) transport.Option {
+	return ServerNameFunc(func(string) string { return name })
+}
+
+// ServerNameFunc sets the function returning the name the certificate of the
+// server dialed at an address is verified against, see RegistryServerName.
+func ServerNameFunc(fn func(addr string) string) transport.Option {
+	return setOption(serverNameKey{}, fn)
+}
+
+// Pin only accepts servers whose certificate public key has one of the
+// hashes, see PublicKeyHash. The certificates are still verified against the
+// certificate authorities unless Insecure is set.
+func Pin(hashes ...[]byte) transport.Option {
+	return setOption(pinsKey{}, hashes)
+}
+
+// Insecure skips the verification of the servers dialed and generates a self
+// signed certificate for the listeners without one. Only pinned certificates
+// are verified.
+func Insecure() transport.Option {
+	return setOption(insecureKey{}, true)
+}
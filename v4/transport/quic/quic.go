@@ -4,13 +4,19 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/gob"
+	"net"
+	"sync"
 	"time"
 
 	"go-micro.dev/v4/util/cmd"
 
 	quic "github.com/lucas-clemente/quic-go"
 	"go-micro.dev/v4/transport"
-	utls "go-micro.dev/v4/util/tls"
+)
+
+const (
+	// quic closes idle connections after 30 seconds by default
+	defaultIdleTimeout = 30 * time.Second
 )
 
 type quicSocket struct {
@@ -18,20 +24,54 @@ type quicSocket struct {
 	st  quic.Stream
 	enc *gob.Encoder
 	dec *gob.Decoder
+
+	// the sockets accepted pass the identity of the client
+	accepted bool
+	once     sync.Once
+	identity string
+	// the handshake didn't complete
+	err error
 }
 
 type quicTransport struct {
-	opts transport.Options
+	opts       transport.Options
+	config     *quic.Config
+	clientTLS  *tls.Config
+	serverName func(addr string) string
+	// the options failed to apply
+	err error
+
+	// the connections dialed by address
+	sync.Mutex
+	conns map[string]*quicConn
 }
 
 type quicClient struct {
 	*quicSocket
 	t    *quicTransport
+	c    *quicConn
 	opts transport.DialOptions
+	once sync.Once
+}
+
+// listener is a quic listener accepting sessions before the handshake
+// completes or after.
+type listener interface {
+	Accept(context.Context) (quic.Session, error)
+	Addr() net.Addr
+	Close() error
+}
+
+type earlyListener struct {
+	quic.EarlyListener
+}
+
+func (l earlyListener) Accept(ctx context.Context) (quic.Session, error) {
+	return l.EarlyListener.Accept(ctx)
 }
 
 type quicListener struct {
-	l    quic.Listener
+	l    listener
 	t    *quicTransport
 	opts transport.ListenOptions
 }
@@ -40,12 +80,75 @@ func init() {
 	cmd.DefaultTransports["quic"] = NewTransport
 }
 
+// Close the stream of the client, the connection is shared with the other
+// clients dialed to the address.
 func (q *quicClient) Close() error {
-	return q.quicSocket.st.Close()
+	err := q.quicSocket.Close()
+	q.once.Do(func() { q.t.release(q.c) })
+	return err
+}
+
+func newSocket(s quic.Session, st quic.Stream) *quicSocket {
+	return &quicSocket{
+		s:   s,
+		st:  st,
+		enc: gob.NewEncoder(st),
+		dec: gob.NewDecoder(st),
+	}
 }
 
 func (q *quicSocket) Recv(m *transport.Message) error {
-	return q.dec.Decode(&m)
+	if err := q.dec.Decode(&m); err != nil {
+		return err
+	}
+	if !q.accepted {
+		return nil
+	}
+
+	// messages sent with 0-RTT arrive before the client certificate is
+	// verified, the identity is only known once the handshake completes
+	q.once.Do(func() {
+		if q.err = q.handshake(); q.err == nil {
+			q.identity = peerIdentity(q.s.ConnectionState().TLS.ConnectionState)
+		}
+	})
+	if q.err != nil {
+		return q.err
+	}
+
+	// the header can't be set by the client
+	if m.Header == nil {
+		m.Header = make(map[string]string)
+	}
+	if len(q.identity) > 0 {
+		m.Header[PeerIdentityHeader] = q.identity
+	} else {
+		delete(m.Header, PeerIdentityHeader)
+	}
+
+	return nil
+}
+
+// handshake waits for the handshake of a session accepted early to complete.
+func (q *quicSocket) handshake() error {
+	s, ok := q.s.(quic.EarlySession)
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-s.HandshakeComplete().Done():
+		return nil
+	case <-s.Context().Done():
+	}
+
+	// closed, maybe right after completing the handshake
+	select {
+	case <-s.HandshakeComplete().Done():
+		return nil
+	default:
+		return ErrHandshake
+	}
 }
 
 func (q *quicSocket) Send(m *transport.Message) error {
@@ -55,8 +158,10 @@ func (q *quicSocket) Send(m *transport.Message) error {
 	return q.enc.Encode(m)
 }
 
+// Close the stream, the session is left open for its other streams.
 func (q *quicSocket) Close() error {
-	return q.s.CloseWithError(0, "EOF")
+	q.st.CancelRead(0)
+	return q.st.Close()
 }
 
 func (q *quicSocket) Local() string {
@@ -82,19 +187,21 @@ func (q *quicListener) Accept(fn func(transport.Socket)) error {
 			return err
 		}
 
-		stream, err := s.AcceptStream(context.TODO())
+		go q.serve(s, fn)
+	}
+}
+
+// serve every stream of the session until it's closed.
+func (q *quicListener) serve(s quic.Session, fn func(transport.Socket)) {
+	for {
+		stream, err := s.AcceptStream(s.Context())
 		if err != nil {
-			continue
+			return
 		}
 
-		go func() {
-			fn(&quicSocket{
-				s:   s,
-				st:  stream,
-				enc: gob.NewEncoder(stream),
-				dec: gob.NewDecoder(stream),
-			})
-		}()
+		sock := newSocket(s, stream)
+		sock.accepted = true
+		go fn(sock)
 	}
 }
 
@@ -102,52 +209,71 @@ func (q *quicTransport) Init(opts ...transport.Option) error {
 	for _, o := range opts {
 		o(&q.opts)
 	}
-	return nil
+	return q.init()
+}
+
+func (q *quicTransport) init() error {
+	q.config = quicConfig(q.opts)
+	q.clientTLS, q.err = clientTLS(q.opts)
+	if q.opts.Context != nil {
+		q.serverName, _ = q.opts.Context.Value(serverNameKey{}).(func(string) string)
+	}
+	return q.err
+}
+
+// dialTLS returns the tls config of a connection to the address.
+func (q *quicTransport) dialTLS(addr string) *tls.Config {
+	config := q.clientTLS.Clone()
+	if q.serverName != nil {
+		config.ServerName = q.serverName(addr)
+	}
+	return config
 }
 
 func (q *quicTransport) Options() transport.Options {
 	return q.opts
 }
 
+// Dial opens a stream to the address, on the connection of the clients
+// dialed to it before if there's one.
 func (q *quicTransport) Dial(addr string, opts ...transport.DialOption) (transport.Client, error) {
-	var options transport.DialOptions
+	options := transport.DialOptions{
+		Timeout: transport.DefaultDialTimeout,
+	}
 	for _, o := range opts {
 		o(&options)
 	}
 
-	config := q.opts.TLSConfig
-	if config == nil {
-		config = &tls.Config{
-			InsecureSkipVerify: true,
-			NextProtos:         []string{"http/1.1"},
-		}
-	}
-	s, err := quic.DialAddr(addr, config, &quic.Config{
-		MaxIdleTimeout: time.Minute * 2,
-		KeepAlive:      true,
-	})
-	if err != nil {
-		return nil, err
+	if q.err != nil {
+		return nil, q.err
 	}
 
-	st, err := s.OpenStreamSync(context.TODO())
-	if err != nil {
-		return nil, err
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), options.Timeout)
+	defer cancel()
 
-	enc := gob.NewEncoder(st)
-	dec := gob.NewDecoder(st)
+	for retry := true; ; retry = false {
+		c, err := q.conn(ctx, addr, options.Timeout)
+		if err != nil {
+			return nil, err
+		}
 
-	return &quicClient{
-		&quicSocket{
-			s:   s,
-			st:  st,
-			enc: enc,
-			dec: dec,
-		},
-		q,
-		options,
-	}, nil
+		st, err := c.sess.OpenStreamSync(ctx)
+		if err != nil {
+			q.release(c)
+			// the connection was closed by the peer, dial a new one
+			if retry && c.closed() {
+				continue
+			}
+			return nil, err
+		}
+
+		return &quicClient{
+			quicSocket: newSocket(c.sess, st),
+			t:          q,
+			c:          c,
+			opts:       options,
+		}, nil
+	}
 }
 
 func (q *quicTransport) Listen(addr string, opts ...transport.ListenOption) (transport.Listener, error) {
@@ -156,21 +282,24 @@ func (q *quicTransport) Listen(addr string, opts ...transport.ListenOption) (tra
 		o(&options)
 	}
 
-	config := q.opts.TLSConfig
-	if config == nil {
-		cfg, err := utls.Certificate(addr)
+	config, err := serverTLS(q.opts, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var l listener
+	if zeroRTT(q.opts) {
+		el, err := quic.ListenAddrEarly(addr, config, q.config)
 		if err != nil {
 			return nil, err
 		}
-		config = &tls.Config{
-			Certificates: []tls.Certificate{cfg},
-			NextProtos:   []string{"http/1.1"},
+		l = earlyListener{el}
+	} else {
+		ql, err := quic.ListenAddr(addr, config, q.config)
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	l, err := quic.ListenAddr(addr, config, &quic.Config{KeepAlive: true})
-	if err != nil {
-		return nil, err
+		l = ql
 	}
 
 	return &quicListener{
@@ -191,7 +320,10 @@ func NewTransport(opts ...transport.Option) transport.Transport {
 		o(&options)
 	}
 
-	return &quicTransport{
-		opts: options,
+	q := &quicTransport{
+		opts:  options,
+		conns: make(map[string]*quicConn),
 	}
+	q.init()
+	return q
 }
@@ -0,0 +1,334 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/transport"
+)
+
+// testCA issues the certificates of the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue a certificate for the IP addresses or DNS names.
+func (ca *testCA) issue(t *testing.T, usage x509.ExtKeyUsage, names ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: names[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, name)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+}
+
+// listen serves the messages received back with the identity of the client
+// in the identity header. The sockets accepted are sent on the channel.
+func listen(t *testing.T, opts ...transport.Option) (transport.Listener, <-chan transport.Socket) {
+	l, err := NewTransport(opts...).Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	socks := make(chan transport.Socket, 10)
+
+	go l.Accept(func(sock transport.Socket) {
+		select {
+		case socks <- sock:
+		default:
+		}
+
+		for {
+			var m transport.Message
+			if err := sock.Recv(&m); err != nil {
+				return
+			}
+
+			reply := &transport.Message{
+				Header: map[string]string{"identity": m.Header[PeerIdentityHeader]},
+				Body:   m.Body,
+			}
+			if err := sock.Send(reply); err != nil {
+				return
+			}
+		}
+	})
+
+	return l, socks
+}
+
+func dial(t *testing.T, tr transport.Transport, addr string) transport.Client {
+	c, err := tr.Dial(addr, transport.WithTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// roundtrip sends a message with the header and returns the reply.
+func roundtrip(t *testing.T, c transport.Client, header map[string]string) *transport.Message {
+	if err := c.Send(&transport.Message{Header: header, Body: []byte("hello")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var m transport.Message
+	if err := c.Recv(&m); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.Body) != "hello" {
+		t.Fatalf("expected the message back, got %s", m.Body)
+	}
+
+	return &m
+}
+
+// waitClosed waits for the connection to be closed.
+func waitClosed(t *testing.T, c *quicConn) {
+	select {
+	case <-c.sess.Context().Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+}
+
+func TestSessionReuse(t *testing.T) {
+	l, _ := listen(t, Insecure())
+	tr := NewTransport(Insecure()).(*quicTransport)
+
+	a := dial(t, tr, l.Addr())
+	b := dial(t, tr, l.Addr())
+
+	// each client has its own stream on the connection
+	roundtrip(t, a, nil)
+	roundtrip(t, b, nil)
+
+	if a.(*quicClient).c != b.(*quicClient).c {
+		t.Fatal("expected the clients to share the connection")
+	}
+
+	tr.Lock()
+	conns := len(tr.conns)
+	tr.Unlock()
+	if conns != 1 {
+		t.Fatalf("expected 1 connection, got %d", conns)
+	}
+}
+
+func TestIdleClose(t *testing.T) {
+	l, _ := listen(t, Insecure())
+	tr := NewTransport(Insecure(), IdleTimeout(200*time.Millisecond)).(*quicTransport)
+
+	a := dial(t, tr, l.Addr())
+	roundtrip(t, a, nil)
+	conn := a.(*quicClient).c
+	a.Close()
+
+	// dialing within the idle timeout reuses the connection
+	b := dial(t, tr, l.Addr())
+	if b.(*quicClient).c != conn {
+		t.Fatal("expected the released connection to be reused")
+	}
+	roundtrip(t, b, nil)
+	b.Close()
+
+	// released for the idle timeout, the connection is closed
+	waitClosed(t, conn)
+
+	tr.Lock()
+	_, ok := tr.conns[l.Addr()]
+	tr.Unlock()
+	if ok {
+		t.Fatal("expected the idle connection to be removed")
+	}
+}
+
+func TestRedial(t *testing.T) {
+	l, socks := listen(t, Insecure())
+	tr := NewTransport(Insecure()).(*quicTransport)
+
+	a := dial(t, tr, l.Addr())
+	roundtrip(t, a, nil)
+	conn := a.(*quicClient).c
+
+	// the server closes the connection
+	sock := <-socks
+	sock.(*quicSocket).s.CloseWithError(0, "closed")
+	waitClosed(t, conn)
+
+	// a new connection is dialed
+	b := dial(t, tr, l.Addr())
+	if b.(*quicClient).c == conn {
+		t.Fatal("expected a new connection after the peer closed it")
+	}
+	roundtrip(t, b, nil)
+}
+
+func TestPin(t *testing.T) {
+	ca := newCA(t)
+	pinned := ca.issue(t, x509.ExtKeyUsageServerAuth, "127.0.0.1")
+	other := ca.issue(t, x509.ExtKeyUsageServerAuth, "127.0.0.1")
+
+	lp, _ := listen(t, Certificate(pinned))
+	lo, _ := listen(t, Certificate(other))
+
+	tr := NewTransport(RootCAs(ca.pool), Pin(PublicKeyHash(pinned.Leaf)))
+
+	roundtrip(t, dial(t, tr, lp.Addr()), nil)
+
+	// the certificate is issued by the authority, but isn't pinned
+	if c, err := tr.Dial(lo.Addr(), transport.WithTimeout(5*time.Second)); err == nil {
+		c.Close()
+		t.Fatal("expected dialing an unpinned server to fail")
+	}
+}
+
+func TestPeerIdentity(t *testing.T) {
+	ca := newCA(t)
+	server := ca.issue(t, x509.ExtKeyUsageServerAuth, "127.0.0.1")
+	client := ca.issue(t, x509.ExtKeyUsageClientAuth, "client.example")
+
+	spoofed := map[string]string{PeerIdentityHeader: "spoofed"}
+
+	t.Run("MutualTLS", func(t *testing.T) {
+		l, _ := listen(t, Certificate(server), MutualTLS(ca.pool))
+		tr := NewTransport(RootCAs(ca.pool), Certificate(client))
+
+		m := roundtrip(t, dial(t, tr, l.Addr()), spoofed)
+		if m.Header["identity"] != "client.example" {
+			t.Fatalf("expected the identity of the client certificate, got %q", m.Header["identity"])
+		}
+	})
+
+	t.Run("NoClientCertificate", func(t *testing.T) {
+		l, _ := listen(t, Certificate(server))
+		tr := NewTransport(RootCAs(ca.pool))
+
+		// the header can't be set by the client
+		m := roundtrip(t, dial(t, tr, l.Addr()), spoofed)
+		if id, ok := m.Header["identity"]; ok && len(id) > 0 {
+			t.Fatalf("expected the spoofed identity to be stripped, got %q", id)
+		}
+	})
+}
+
+func TestZeroRTTPeerIdentity(t *testing.T) {
+	ca := newCA(t)
+	server := ca.issue(t, x509.ExtKeyUsageServerAuth, "127.0.0.1")
+	client := ca.issue(t, x509.ExtKeyUsageClientAuth, "client.example")
+
+	l, _ := listen(t, Certificate(server), MutualTLS(ca.pool), ZeroRTT())
+	tr := NewTransport(RootCAs(ca.pool), Certificate(client), ZeroRTT(), IdleTimeout(200*time.Millisecond)).(*quicTransport)
+
+	spoofed := map[string]string{PeerIdentityHeader: "spoofed"}
+
+	a := dial(t, tr, l.Addr())
+	if m := roundtrip(t, a, spoofed); m.Header["identity"] != "client.example" {
+		t.Fatalf("expected the identity of the client certificate, got %q", m.Header["identity"])
+	}
+	conn := a.(*quicClient).c
+	a.Close()
+	waitClosed(t, conn)
+
+	// the connection is resumed, the first message is sent with 0-RTT
+	// before the client certificate is verified
+	b := dial(t, tr, l.Addr())
+	if m := roundtrip(t, b, spoofed); m.Header["identity"] != "client.example" {
+		t.Fatalf("expected the identity of the client certificate with 0-RTT, got %q", m.Header["identity"])
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	// a peer never answering the handshake
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	tr := NewTransport(Insecure())
+
+	start := time.Now()
+	if _, err := tr.Dial(pc.LocalAddr().String(), transport.WithTimeout(200*time.Millisecond)); err == nil {
+		t.Fatal("expected the dial to time out")
+	}
+	if d := time.Since(start); d > transport.DefaultDialTimeout/2 {
+		t.Fatalf("expected the dial timeout to bound the handshake, took %v", d)
+	}
+}
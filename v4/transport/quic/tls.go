@@ -0,0 +1,185 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+
+	"go-micro.dev/v4/registry"
+	"go-micro.dev/v4/transport"
+	utls "go-micro.dev/v4/util/tls"
+)
+
+// PeerIdentityHeader is the metadata passed to the handlers with the identity
+// of the client certificate: its first URI, DNS name or its common name.
+const PeerIdentityHeader = "Micro-Peer-Identity"
+
+var (
+	// ErrNoCertificate is returned when listening without a certificate.
+	ErrNoCertificate = errors.New("no certificate to listen with, set a certificate or Insecure")
+	// ErrPinMismatch is returned when dialing a server whose certificate
+	// isn't pinned.
+	ErrPinMismatch = errors.New("server certificate isn't pinned")
+	// ErrHandshake is returned when receiving a message sent with 0-RTT on
+	// a connection closed before its handshake completed.
+	ErrHandshake = errors.New("connection closed before the handshake completed")
+)
+
+// PublicKeyHash returns the SHA-256 hash of the public key of the
+// certificate, the hash pinned by Pin.
+func PublicKeyHash(cert *x509.Certificate) []byte {
+	h := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return h[:]
+}
+
+// RegistryServerName derives the server name of an address from the
+// registry, the name of the service with a node at the address. It's the
+// host of the address for the addresses of no service.
+func RegistryServerName(r registry.Registry) func(addr string) string {
+	return func(addr string) string {
+		services, err := r.ListServices()
+		if err != nil {
+			return hostName(addr)
+		}
+
+		for _, service := range services {
+			versions, err := r.GetService(service.Name)
+			if err != nil {
+				continue
+			}
+			for _, version := range versions {
+				for _, node := range version.Nodes {
+					if node.Address == addr {
+						return service.Name
+					}
+				}
+			}
+		}
+
+		return hostName(addr)
+	}
+}
+
+func hostName(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// clientTLS returns the config verifying the servers dialed. The config set
+// with transport.TLSConfig is the base of the options.
+func clientTLS(opts transport.Options) (*tls.Config, error) {
+	config := &tls.Config{
+		NextProtos: []string{"http/1.1"},
+	}
+	if opts.TLSConfig != nil {
+		config = opts.TLSConfig.Clone()
+	}
+	// resume the sessions of the servers dialed before
+	if config.ClientSessionCache == nil {
+		config.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		return config, nil
+	}
+
+	switch v := ctx.Value(rootCAsKey{}).(type) {
+	case *x509.CertPool:
+		config.RootCAs = v
+	case error:
+		return nil, v
+	}
+	if cert, ok := ctx.Value(certificateKey{}).(tls.Certificate); ok {
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if insecure, ok := ctx.Value(insecureKey{}).(bool); ok && insecure {
+		config.InsecureSkipVerify = true
+	}
+	if pins, ok := ctx.Value(pinsKey{}).([][]byte); ok && len(pins) > 0 {
+		config.VerifyPeerCertificate = verifyPins(pins)
+	}
+
+	return config, nil
+}
+
+// verifyPins checks the public key of the server certificate is pinned, it's
+// called once the certificate chain is verified.
+func verifyPins(pins [][]byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return ErrPinMismatch
+		}
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return err
+		}
+
+		hash := PublicKeyHash(cert)
+		for _, pin := range pins {
+			if bytes.Equal(pin, hash) {
+				return nil
+			}
+		}
+		return ErrPinMismatch
+	}
+}
+
+// serverTLS returns the config of the listener at the address.
+func serverTLS(opts transport.Options, addr string) (*tls.Config, error) {
+	config := &tls.Config{
+		NextProtos: []string{"http/1.1"},
+	}
+	if opts.TLSConfig != nil {
+		config = opts.TLSConfig.Clone()
+	}
+
+	var insecure bool
+	if ctx := opts.Context; ctx != nil {
+		if cert, ok := ctx.Value(certificateKey{}).(tls.Certificate); ok {
+			config.Certificates = []tls.Certificate{cert}
+		}
+		if pool, ok := ctx.Value(clientCAsKey{}).(*x509.CertPool); ok {
+			config.ClientCAs = pool
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		insecure, _ = ctx.Value(insecureKey{}).(bool)
+	}
+
+	if len(config.Certificates) == 0 && config.GetCertificate == nil {
+		if !insecure {
+			return nil, ErrNoCertificate
+		}
+
+		cert, err := utls.Certificate(addr)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// peerIdentity returns the identity of the client certificate.
+func peerIdentity(state tls.ConnectionState) string {
+	if len(state.PeerCertificates) == 0 {
+		return ""
+	}
+
+	cert := state.PeerCertificates[0]
+	switch {
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	default:
+		return cert.Subject.CommonName
+	}
+}
@@ -0,0 +1,244 @@
+package tcp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+	"sync"
+
+	"go-micro.dev/v4/transport"
+)
+
+// DefaultMaxFrameSize is the size of the largest message read or written by
+// the binary framing, 16 MiB.
+const DefaultMaxFrameSize = 16 << 20
+
+var (
+	// GobFraming encodes the messages with encoding/gob, it's the default.
+	GobFraming Framing = gobFraming{}
+
+	// ErrFrameTooLarge is returned for messages larger than the max frame
+	// size, the connection can't be read from after.
+	ErrFrameTooLarge = errors.New("frame too large")
+	// ErrMalformedFrame is returned for frames that can't be decoded.
+	ErrMalformedFrame = errors.New("malformed frame")
+)
+
+// Framing reads and writes the messages on the connections, the client and
+// the server have to use the same.
+type Framing interface {
+	// NewCodec returns the codec of a connection.
+	NewCodec(rw io.ReadWriter) Codec
+	String() string
+}
+
+// Codec reads and writes the messages of a connection. The reads and the
+// writes are done by a goroutine at a time.
+type Codec interface {
+	ReadMessage(m *transport.Message) error
+	WriteMessage(m *transport.Message) error
+}
+
+type gobFraming struct{}
+
+func (gobFraming) NewCodec(rw io.ReadWriter) Codec {
+	encBuf := bufio.NewWriter(rw)
+	return &gobCodec{
+		encBuf: encBuf,
+		enc:    gob.NewEncoder(encBuf),
+		dec:    gob.NewDecoder(rw),
+	}
+}
+
+func (gobFraming) String() string {
+	return "gob"
+}
+
+type gobCodec struct {
+	encBuf *bufio.Writer
+	enc    *gob.Encoder
+	dec    *gob.Decoder
+}
+
+func (c *gobCodec) ReadMessage(m *transport.Message) error {
+	return c.dec.Decode(&m)
+}
+
+func (c *gobCodec) WriteMessage(m *transport.Message) error {
+	if err := c.enc.Encode(m); err != nil {
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+// BinaryFraming returns the length prefixed framing of the messages, readable
+// by peers in any language. A frame is:
+//
+//	uint32 big endian length of the rest of the frame
+//	uvarint number of headers
+//	uvarint length and bytes of each header key, then of its value
+//	body, the rest of the frame
+//
+// Frames larger than maxFrameSize are neither read nor written, zero is
+// DefaultMaxFrameSize.
+func BinaryFraming(maxFrameSize int) Framing {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return binaryFraming{maxFrameSize: maxFrameSize}
+}
+
+type binaryFraming struct {
+	maxFrameSize int
+}
+
+func (f binaryFraming) NewCodec(rw io.ReadWriter) Codec {
+	return &binaryCodec{
+		r:            bufio.NewReader(rw),
+		w:            rw,
+		maxFrameSize: f.maxFrameSize,
+	}
+}
+
+func (binaryFraming) String() string {
+	return "binary"
+}
+
+// frame buffers are reused by the connections
+var framePool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 4096)
+		return &b
+	},
+}
+
+func getFrame(size int) *[]byte {
+	b := framePool.Get().(*[]byte)
+	if cap(*b) < size {
+		*b = make([]byte, 0, size)
+	}
+	*b = (*b)[:0]
+	return b
+}
+
+func putFrame(b *[]byte) {
+	// don't keep the buffers of the largest messages around
+	if cap(*b) > 1<<20 {
+		return
+	}
+	framePool.Put(b)
+}
+
+type binaryCodec struct {
+	r            *bufio.Reader
+	w            io.Writer
+	maxFrameSize int
+	lenBuf       [4]byte
+}
+
+func (c *binaryCodec) ReadMessage(m *transport.Message) error {
+	if _, err := io.ReadFull(c.r, c.lenBuf[:]); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(c.lenBuf[:])
+	if uint64(size) > uint64(c.maxFrameSize) {
+		return ErrFrameTooLarge
+	}
+
+	buf := getFrame(int(size))
+	defer putFrame(buf)
+
+	frame := (*buf)[:size]
+	if _, err := io.ReadFull(c.r, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	n, frame, err := readUvarint(frame)
+	if err != nil {
+		return err
+	}
+	// a header takes two bytes at least
+	if n > uint64(len(frame)/2) {
+		return ErrMalformedFrame
+	}
+
+	header := make(map[string]string, int(n))
+	for i := uint64(0); i < n; i++ {
+		var k, v []byte
+		if k, frame, err = readBytes(frame); err != nil {
+			return err
+		}
+		if v, frame, err = readBytes(frame); err != nil {
+			return err
+		}
+		header[string(k)] = string(v)
+	}
+
+	m.Header = header
+	m.Body = nil
+	if len(frame) > 0 {
+		m.Body = make([]byte, len(frame))
+		copy(m.Body, frame)
+	}
+
+	return nil
+}
+
+func (c *binaryCodec) WriteMessage(m *transport.Message) error {
+	size := binary.MaxVarintLen64 + len(m.Body)
+	for k, v := range m.Header {
+		size += 2*binary.MaxVarintLen64 + len(k) + len(v)
+	}
+
+	buf := getFrame(4 + size)
+	defer putFrame(buf)
+
+	frame := append(*buf, 0, 0, 0, 0)
+	frame = appendUvarint(frame, uint64(len(m.Header)))
+	for k, v := range m.Header {
+		frame = appendUvarint(frame, uint64(len(k)))
+		frame = append(frame, k...)
+		frame = appendUvarint(frame, uint64(len(v)))
+		frame = append(frame, v...)
+	}
+	frame = append(frame, m.Body...)
+	*buf = frame
+
+	if len(frame)-4 > c.maxFrameSize {
+		return ErrFrameTooLarge
+	}
+	binary.BigEndian.PutUint32(frame, uint32(len(frame)-4))
+
+	_, err := c.w.Write(frame)
+	return err
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(b, tmp[:n]...)
+}
+
+func readUvarint(b []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, ErrMalformedFrame
+	}
+	return v, b[n:], nil
+}
+
+func readBytes(b []byte) ([]byte, []byte, error) {
+	n, b, err := readUvarint(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n > uint64(len(b)) {
+		return nil, nil, ErrMalformedFrame
+	}
+	return b[:n], b[n:], nil
+}
@@ -0,0 +1,145 @@
+package tcp
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"go-micro.dev/v4/transport"
+)
+
+var testMessage = transport.Message{
+	Header: map[string]string{
+		"Content-Type":  "application/json",
+		"Micro-Service": "greeter",
+		"Micro-Method":  "Greeter.Hello",
+	},
+	Body: []byte(`{"message": "Hello World"}`),
+}
+
+func TestTCPTransportFraming(t *testing.T) {
+	for _, f := range []Framing{GobFraming, BinaryFraming(0)} {
+		t.Run(f.String(), func(t *testing.T) {
+			tr := NewTransport(WithFraming(f))
+
+			l, err := tr.Listen(":0")
+			if err != nil {
+				t.Fatalf("Unexpected listen err: %v", err)
+			}
+			defer l.Close()
+
+			go l.Accept(func(sock transport.Socket) {
+				defer sock.Close()
+
+				for {
+					var m transport.Message
+					if err := sock.Recv(&m); err != nil {
+						return
+					}
+					if err := sock.Send(&m); err != nil {
+						return
+					}
+				}
+			})
+
+			c, err := tr.Dial(l.Addr())
+			if err != nil {
+				t.Fatalf("Unexpected dial err: %v", err)
+			}
+			defer c.Close()
+
+			for _, m := range []transport.Message{testMessage, {Header: map[string]string{}}} {
+				if err := c.Send(&m); err != nil {
+					t.Fatalf("Unexpected send err: %v", err)
+				}
+
+				var rm transport.Message
+				if err := c.Recv(&rm); err != nil {
+					t.Fatalf("Unexpected recv err: %v", err)
+				}
+				if !reflect.DeepEqual(rm.Header, m.Header) || !bytes.Equal(rm.Body, m.Body) {
+					t.Fatalf("Expected %v, got %v", m, rm)
+				}
+			}
+		})
+	}
+}
+
+func TestBinaryFramingMaxFrameSize(t *testing.T) {
+	var buf bytes.Buffer
+	small := BinaryFraming(16).NewCodec(&buf)
+
+	if err := small.WriteMessage(&testMessage); err != ErrFrameTooLarge {
+		t.Fatalf("Expected %v, got %v", ErrFrameTooLarge, err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Expected nothing written, got %d bytes", buf.Len())
+	}
+
+	// the frame is rejected before it's read
+	if err := BinaryFraming(0).NewCodec(&buf).WriteMessage(&testMessage); err != nil {
+		t.Fatal(err)
+	}
+	if err := small.ReadMessage(&transport.Message{}); err != ErrFrameTooLarge {
+		t.Fatalf("Expected %v, got %v", ErrFrameTooLarge, err)
+	}
+}
+
+func TestBinaryFramingMalformed(t *testing.T) {
+	frames := map[string][]byte{
+		"truncated":       {0, 0, 0, 8, 1},
+		"no headers":      {0, 0, 0, 0},
+		"too many":        {0, 0, 0, 2, 200, 1},
+		"key overflow":    {0, 0, 0, 3, 1, 5, 'a'},
+		"missing value":   {0, 0, 0, 3, 1, 1, 'a'},
+		"value overflow":  {0, 0, 0, 5, 1, 1, 'a', 9, 'b'},
+		"varint overflow": {0, 0, 0, 11, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+
+	for name, frame := range frames {
+		t.Run(name, func(t *testing.T) {
+			c := BinaryFraming(0).NewCodec(bytes.NewBuffer(frame))
+			err := c.ReadMessage(&transport.Message{})
+			if err != ErrMalformedFrame && err != io.ErrUnexpectedEOF {
+				t.Fatalf("Expected a malformed frame, got %v", err)
+			}
+		})
+	}
+}
+
+func benchmarkFraming(b *testing.B, f Framing, m *transport.Message) {
+	// the messages written by a codec are read by the other
+	var buf bytes.Buffer
+	w := f.NewCodec(&buf)
+	r := f.NewCodec(&buf)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := w.WriteMessage(m); err != nil {
+			b.Fatal(err)
+		}
+		var rm transport.Message
+		if err := r.ReadMessage(&rm); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGobFraming(b *testing.B) {
+	benchmarkFraming(b, GobFraming, &testMessage)
+}
+
+func BenchmarkBinaryFraming(b *testing.B) {
+	benchmarkFraming(b, BinaryFraming(0), &testMessage)
+}
+
+func BenchmarkGobFramingLarge(b *testing.B) {
+	benchmarkFraming(b, GobFraming, &transport.Message{Header: testMessage.Header, Body: make([]byte, 64<<10)})
+}
+
+func BenchmarkBinaryFramingLarge(b *testing.B) {
+	benchmarkFraming(b, BinaryFraming(0), &transport.Message{Header: testMessage.Header, Body: make([]byte, 64<<10)})
+}
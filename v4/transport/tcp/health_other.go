@@ -0,0 +1,26 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !solaris && !illumos
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!solaris,!illumos
+
+package tcp
+
+import (
+	"net"
+	"time"
+)
+
+// healthy checks the connection wasn't closed by the peer and has nothing to
+// read, a server doesn't send anything to an idle client. The read waits for
+// a millisecond at most.
+func healthy(conn net.Conn) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return false
+	}
+
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		return false
+	}
+
+	return conn.SetReadDeadline(time.Time{}) == nil
+}
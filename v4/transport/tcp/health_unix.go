@@ -0,0 +1,37 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris || illumos
+// +build linux darwin dragonfly freebsd netbsd openbsd solaris illumos
+
+package tcp
+
+import (
+	"net"
+	"syscall"
+)
+
+// healthy checks the connection wasn't closed by the peer and has nothing to
+// read, a server doesn't send anything to an idle client. The socket is
+// peeked without blocking.
+func healthy(conn net.Conn) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	var peekErr error
+	err = rc.Read(func(fd uintptr) bool {
+		var b [1]byte
+		_, _, peekErr = syscall.Recvfrom(int(fd), b[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		// don't wait for the socket to be readable
+		return true
+	})
+	if err != nil {
+		return false
+	}
+
+	// nothing was read, neither data nor the end of the stream
+	return peekErr == syscall.EAGAIN || peekErr == syscall.EWOULDBLOCK
+}
@@ -0,0 +1,83 @@
+package tcp
+
+import (
+	"context"
+	"time"
+
+	"go-micro.dev/v4/transport"
+)
+
+type framingKey struct{}
+
+// WithFraming sets the framing of the messages, GobFraming by default. The
+// clients and the servers of a service have to use the same.
+func WithFraming(f Framing) transport.Option {
+	return setOption(framingKey{}, f)
+}
+
+func framing(opts transport.Options) Framing {
+	if opts.Context != nil {
+		if f, ok := opts.Context.Value(framingKey{}).(Framing); ok && f != nil {
+			return f
+		}
+	}
+	return GobFraming
+}
+
+type poolKey struct{}
+type poolHooksKey struct{}
+type keepAliveKey struct{}
+type noDelayKey struct{}
+
+type poolOptions struct {
+	maxIdle     int
+	idleTimeout time.Duration
+}
+
+// Pool keeps the connections of the clients closed to be reused when dialing
+// the same address, up to maxIdle per address for idleTimeout. Zero values
+// are DefaultPoolMaxIdle and DefaultPoolIdleTimeout. The connection of a
+// client is only kept if it received a message for each sent and had no
+// error, and it's checked before being reused. The idle connections are
+// closed once expired, or by the Close method of the transport.
+func Pool(maxIdle int, idleTimeout time.Duration) transport.Option {
+	return setOption(poolKey{}, poolOptions{maxIdle: maxIdle, idleTimeout: idleTimeout})
+}
+
+// WithPoolHooks sets the hooks called on the events of the pool.
+func WithPoolHooks(h PoolHooks) transport.Option {
+	return setOption(poolHooksKey{}, h)
+}
+
+// KeepAlive sets the keep-alive period of the connections dialed, 15 seconds
+// by default as with net.Dialer. A negative period disables keep-alives.
+func KeepAlive(d time.Duration) transport.Option {
+	return setOption(keepAliveKey{}, d)
+}
+
+// NoDelay sets whether the connections dialed send the data without waiting
+// to fill packets, true by default as with net.Dial.
+func NoDelay(b bool) transport.Option {
+	return setOption(noDelayKey{}, b)
+}
+
+func setOption(k, v interface{}) transport.Option {
+	return func(o *transport.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+func newPoolFromOptions(opts transport.Options) *pool {
+	if opts.Context == nil {
+		return nil
+	}
+	po, ok := opts.Context.Value(poolKey{}).(poolOptions)
+	if !ok {
+		return nil
+	}
+	hooks, _ := opts.Context.Value(poolHooksKey{}).(PoolHooks)
+	return newPool(po.maxIdle, po.idleTimeout, hooks)
+}
@@ -0,0 +1,208 @@
+package tcp
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPoolMaxIdle is the number of idle connections kept per address.
+	DefaultPoolMaxIdle = 2
+	// DefaultPoolIdleTimeout is how long a connection is kept idle.
+	DefaultPoolIdleTimeout = time.Minute
+)
+
+// PoolHooks are called on the events of the connection pool, to record
+// metrics. They're called synchronously by the clients dialing and closing.
+type PoolHooks struct {
+	// Hit is called when dialing reuses an idle connection.
+	Hit func(addr string)
+	// Miss is called when dialing opens a connection.
+	Miss func(addr string)
+	// Evict is called when an idle connection is closed, because it's
+	// been idle for too long, over the idle limit or broken.
+	Evict func(addr string)
+}
+
+// poolConn is an idle connection with its codec, the state of the codec
+// is kept for the next client.
+type poolConn struct {
+	addr  string
+	conn  net.Conn
+	codec Codec
+	// the tcp connection under tls
+	raw       net.Conn
+	idleSince time.Time
+}
+
+// pool of the idle connections by address, most recently used last. The
+// reaper closes the expired connections while the pool isn't empty.
+type pool struct {
+	maxIdle     int
+	idleTimeout time.Duration
+	hooks       PoolHooks
+
+	sync.Mutex
+	idle   map[string][]*poolConn
+	reaper *time.Timer
+	closed bool
+}
+
+func newPool(maxIdle int, idleTimeout time.Duration, hooks PoolHooks) *pool {
+	if maxIdle <= 0 {
+		maxIdle = DefaultPoolMaxIdle
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultPoolIdleTimeout
+	}
+
+	return &pool{
+		maxIdle:     maxIdle,
+		idleTimeout: idleTimeout,
+		hooks:       hooks,
+		idle:        make(map[string][]*poolConn),
+	}
+}
+
+// get returns a healthy idle connection to the address, nil if there's none.
+func (p *pool) get(addr string) *poolConn {
+	for {
+		p.Lock()
+		expired := p.prune()
+		clients := p.idle[addr]
+		var c *poolConn
+		if len(clients) > 0 {
+			c = clients[len(clients)-1]
+			p.idle[addr] = clients[:len(clients)-1]
+		}
+		p.Unlock()
+
+		p.close(expired)
+		if c == nil {
+			p.miss(addr)
+			return nil
+		}
+
+		if healthy(c.raw) {
+			p.hit(addr)
+			return c
+		}
+		c.conn.Close()
+		p.evict(addr)
+	}
+}
+
+// put the connection of a client back, it's closed if the address has enough
+// idle connections.
+func (p *pool) put(c *poolConn) {
+	c.idleSince = time.Now()
+
+	p.Lock()
+	expired := p.prune()
+	clients := p.idle[c.addr]
+	if p.closed || len(clients) >= p.maxIdle {
+		expired = append(expired, c)
+	} else {
+		p.idle[c.addr] = append(clients, c)
+		if p.reaper == nil {
+			p.reaper = time.AfterFunc(p.idleTimeout, p.reap)
+		}
+	}
+	p.Unlock()
+
+	p.close(expired)
+}
+
+// reap closes the expired connections, it's rescheduled for the oldest
+// connection left.
+func (p *pool) reap() {
+	p.Lock()
+	expired := p.prune()
+	p.reaper = nil
+	if oldest, ok := p.oldest(); ok && !p.closed {
+		p.reaper = time.AfterFunc(time.Until(oldest.Add(p.idleTimeout)), p.reap)
+	}
+	p.Unlock()
+
+	p.close(expired)
+}
+
+// oldest returns when the longest idle connection was put back, the pool
+// lock is held.
+func (p *pool) oldest() (time.Time, bool) {
+	var oldest time.Time
+	for _, clients := range p.idle {
+		if since := clients[0].idleSince; oldest.IsZero() || since.Before(oldest) {
+			oldest = since
+		}
+	}
+	return oldest, !oldest.IsZero()
+}
+
+// closeAll closes the idle connections and stops the reaper, the connections
+// put back afterwards are closed.
+func (p *pool) closeAll() {
+	p.Lock()
+	var clients []*poolConn
+	for _, c := range p.idle {
+		clients = append(clients, c...)
+	}
+	p.idle = make(map[string][]*poolConn)
+	if p.reaper != nil {
+		p.reaper.Stop()
+		p.reaper = nil
+	}
+	p.closed = true
+	p.Unlock()
+
+	p.close(clients)
+}
+
+// prune removes the connections idle for too long, the pool lock is held.
+func (p *pool) prune() []*poolConn {
+	var expired []*poolConn
+
+	deadline := time.Now().Add(-p.idleTimeout)
+	for addr, clients := range p.idle {
+		// the oldest connections are first
+		var i int
+		for i < len(clients) && clients[i].idleSince.Before(deadline) {
+			i++
+		}
+		expired = append(expired, clients[:i]...)
+
+		if i == len(clients) {
+			delete(p.idle, addr)
+		} else if i > 0 {
+			p.idle[addr] = append(clients[:0], clients[i:]...)
+		}
+	}
+
+	return expired
+}
+
+func (p *pool) close(clients []*poolConn) {
+	for _, c := range clients {
+		c.conn.Close()
+		p.evict(c.addr)
+	}
+}
+
+func (p *pool) hit(addr string) {
+	if p.hooks.Hit != nil {
+		p.hooks.Hit(addr)
+	}
+}
+
+func (p *pool) miss(addr string) {
+	if p.hooks.Miss != nil {
+		p.hooks.Miss(addr)
+	}
+}
+
+func (p *pool) evict(addr string) {
+	if p.hooks.Evict != nil {
+		p.hooks.Evict(addr)
+	}
+}
@@ -0,0 +1,263 @@
+package tcp
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/transport"
+)
+
+type poolCounts struct {
+	sync.Mutex
+	hits, misses, evictions int
+}
+
+func (c *poolCounts) hooks() PoolHooks {
+	count := func(n *int) func(string) {
+		return func(string) {
+			c.Lock()
+			*n++
+			c.Unlock()
+		}
+	}
+	return PoolHooks{Hit: count(&c.hits), Miss: count(&c.misses), Evict: count(&c.evictions)}
+}
+
+func (c *poolCounts) check(t *testing.T, hits, misses, evictions int) {
+	t.Helper()
+
+	c.Lock()
+	defer c.Unlock()
+	if c.hits != hits || c.misses != misses || c.evictions != evictions {
+		t.Fatalf("Expected %d hits, %d misses and %d evictions, got %d, %d and %d",
+			hits, misses, evictions, c.hits, c.misses, c.evictions)
+	}
+}
+
+// echoServer replies to the messages, a message with a Close header closes
+// the connection instead.
+func echoServer(t *testing.T, tr transport.Transport) string {
+	l, err := tr.Listen(":0")
+	if err != nil {
+		t.Fatalf("Unexpected listen err: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go l.Accept(func(sock transport.Socket) {
+		defer sock.Close()
+
+		for {
+			var m transport.Message
+			if err := sock.Recv(&m); err != nil {
+				return
+			}
+			if _, ok := m.Header["Close"]; ok {
+				return
+			}
+			if err := sock.Send(&m); err != nil {
+				return
+			}
+		}
+	})
+
+	return l.Addr()
+}
+
+func call(t *testing.T, tr transport.Transport, addr string) string {
+	t.Helper()
+
+	c, err := tr.Dial(addr)
+	if err != nil {
+		t.Fatalf("Unexpected dial err: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Send(&testMessage); err != nil {
+		t.Fatalf("Unexpected send err: %v", err)
+	}
+	var m transport.Message
+	if err := c.Recv(&m); err != nil {
+		t.Fatalf("Unexpected recv err: %v", err)
+	}
+
+	return c.Local()
+}
+
+func TestTCPTransportPool(t *testing.T) {
+	for _, f := range []Framing{GobFraming, BinaryFraming(0)} {
+		t.Run(f.String(), func(t *testing.T) {
+			var counts poolCounts
+			tr := NewTransport(WithFraming(f), Pool(1, time.Minute), WithPoolHooks(counts.hooks()), NoDelay(false), KeepAlive(time.Second))
+			addr := echoServer(t, tr)
+
+			// the connection is reused, with the state of its codec
+			local := call(t, tr, addr)
+			for i := 0; i < 3; i++ {
+				if l := call(t, tr, addr); l != local {
+					t.Fatalf("Expected the connection from %s to be reused, got %s", local, l)
+				}
+			}
+			counts.check(t, 3, 1, 0)
+
+			// the connections over the idle limit are closed
+			one, err := tr.Dial(addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			two, err := tr.Dial(addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			one.Close()
+			two.Close()
+			counts.check(t, 4, 2, 1)
+		})
+	}
+}
+
+func TestTCPTransportPoolUnreplied(t *testing.T) {
+	var counts poolCounts
+	tr := NewTransport(Pool(1, time.Minute), WithPoolHooks(counts.hooks()))
+	addr := echoServer(t, tr)
+
+	// the reply could be read by the next client
+	c, err := tr.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send(&testMessage); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	call(t, tr, addr)
+	counts.check(t, 0, 2, 0)
+}
+
+func TestTCPTransportPoolFailed(t *testing.T) {
+	var counts poolCounts
+	tr := NewTransport(Pool(1, time.Minute), WithPoolHooks(counts.hooks()))
+	addr := echoServer(t, tr)
+
+	call(t, tr, addr)
+
+	// the connection of a client failing isn't put back
+	c, err := tr.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send(&transport.Message{Header: map[string]string{"Close": ""}}); err != nil {
+		t.Fatal(err)
+	}
+	var m transport.Message
+	if err := c.Recv(&m); err == nil {
+		t.Fatal("Expected the connection to be closed")
+	}
+	c.Close()
+	counts.check(t, 1, 1, 0)
+
+	call(t, tr, addr)
+	call(t, tr, addr)
+	counts.check(t, 2, 2, 0)
+}
+
+func TestTCPTransportPoolIdleTimeout(t *testing.T) {
+	var counts poolCounts
+	tr := NewTransport(Pool(1, 50*time.Millisecond), WithPoolHooks(counts.hooks()))
+	addr := echoServer(t, tr)
+
+	call(t, tr, addr)
+	time.Sleep(100 * time.Millisecond)
+	call(t, tr, addr)
+	counts.check(t, 0, 2, 1)
+}
+
+func TestTCPTransportPoolBroken(t *testing.T) {
+	var counts poolCounts
+	tr := NewTransport(Pool(1, time.Minute), WithPoolHooks(counts.hooks()))
+	addr := echoServer(t, tr)
+
+	c, err := tr.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send(&testMessage); err != nil {
+		t.Fatal(err)
+	}
+	var m transport.Message
+	if err := c.Recv(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	// the peer closes the connection once idle, the message is counted
+	// as replied for the connection to be put back
+	if err := c.Send(&transport.Message{Header: map[string]string{"Close": ""}}); err != nil {
+		t.Fatal(err)
+	}
+	c.(*tcpTransportClient).recvd++
+	c.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	call(t, tr, addr)
+	counts.check(t, 0, 2, 1)
+}
+
+func TestTCPTransportPoolReaper(t *testing.T) {
+	var counts poolCounts
+	tr := NewTransport(Pool(1, 50*time.Millisecond), WithPoolHooks(counts.hooks()))
+	addr := echoServer(t, tr)
+
+	// the idle connection is closed without dialing again
+	call(t, tr, addr)
+	time.Sleep(150 * time.Millisecond)
+	counts.check(t, 0, 1, 1)
+
+	p := tr.(*tcpTransport).pool
+	p.Lock()
+	idle, reaper := len(p.idle), p.reaper
+	p.Unlock()
+	if idle != 0 || reaper != nil {
+		t.Fatalf("Expected an empty pool without reaper, got %d idle", idle)
+	}
+}
+
+func TestTCPTransportPoolClose(t *testing.T) {
+	var counts poolCounts
+	tr := NewTransport(Pool(1, time.Minute), WithPoolHooks(counts.hooks()))
+	addr := echoServer(t, tr)
+
+	call(t, tr, addr)
+	if err := tr.(*tcpTransport).Close(); err != nil {
+		t.Fatal(err)
+	}
+	counts.check(t, 0, 1, 1)
+
+	// the transport still dials, the connections aren't kept
+	call(t, tr, addr)
+	counts.check(t, 0, 2, 2)
+}
+
+func TestTCPTransportClientClosed(t *testing.T) {
+	var counts poolCounts
+	tr := NewTransport(Pool(1, time.Minute), WithPoolHooks(counts.hooks()))
+	addr := echoServer(t, tr)
+
+	c, err := tr.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// the connection is back in the pool, the client can't use it
+	if err := c.Send(&testMessage); err != ErrClientClosed {
+		t.Fatalf("Expected %v, got %v", ErrClientClosed, err)
+	}
+	var m transport.Message
+	if err := c.Recv(&m); err != ErrClientClosed {
+		t.Fatalf("Expected %v, got %v", ErrClientClosed, err)
+	}
+
+	call(t, tr, addr)
+	counts.check(t, 1, 1, 0)
+}
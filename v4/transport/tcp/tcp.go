@@ -2,11 +2,10 @@
 package tcp
 
 import (
-	"bufio"
 	"crypto/tls"
-	"encoding/gob"
 	"errors"
 	"net"
+	"sync"
 	"time"
 
 	log "go-micro.dev/v4/logger"
@@ -17,29 +16,42 @@ import (
 	mls "go-micro.dev/v4/util/tls"
 )
 
+// ErrClientClosed is returned sending or receiving on a closed client.
+var ErrClientClosed = errors.New("client closed")
+
 type tcpTransport struct {
 	opts transport.Options
+	pool *pool
 }
 
 type tcpTransportClient struct {
 	dialOpts transport.DialOptions
+	addr     string
 	conn     net.Conn
-	enc      *gob.Encoder
-	dec      *gob.Decoder
-	encBuf   *bufio.Writer
+	codec    Codec
+	raw      net.Conn
 	timeout  time.Duration
+
+	// the connection is put back in the pool if the messages sent were
+	// replied to without errors and none is in flight
+	pool *pool
+
+	sync.Mutex
+	sent, recvd int
+	inflight    int
+	failed      bool
+	closed      bool
 }
 
 type tcpTransportSocket struct {
 	conn    net.Conn
-	enc     *gob.Encoder
-	dec     *gob.Decoder
-	encBuf  *bufio.Writer
+	codec   Codec
 	timeout time.Duration
 }
 
 type tcpTransportListener struct {
 	listener net.Listener
+	framing  Framing
 	timeout  time.Duration
 }
 
@@ -56,26 +68,74 @@ func (t *tcpTransportClient) Remote() string {
 }
 
 func (t *tcpTransportClient) Send(m *transport.Message) error {
+	if err := t.begin(); err != nil {
+		return err
+	}
 	// set timeout if its greater than 0
 	if t.timeout > time.Duration(0) {
 		t.conn.SetDeadline(time.Now().Add(t.timeout))
 	}
-	if err := t.enc.Encode(m); err != nil {
-		return err
-	}
-	return t.encBuf.Flush()
+	err := t.codec.WriteMessage(m)
+	t.end(&t.sent, err)
+	return err
 }
 
 func (t *tcpTransportClient) Recv(m *transport.Message) error {
+	if err := t.begin(); err != nil {
+		return err
+	}
 	// set timeout if its greater than 0
 	if t.timeout > time.Duration(0) {
 		t.conn.SetDeadline(time.Now().Add(t.timeout))
 	}
-	return t.dec.Decode(&m)
+	err := t.codec.ReadMessage(m)
+	t.end(&t.recvd, err)
+	return err
+}
+
+// begin a message, ErrClientClosed once the client is closed as the
+// connection may be reused by another.
+func (t *tcpTransportClient) begin() error {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.closed {
+		return ErrClientClosed
+	}
+	t.inflight++
+	return nil
+}
+
+// end a message, counted unless it failed.
+func (t *tcpTransportClient) end(count *int, err error) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.inflight--
+	if err != nil {
+		t.failed = true
+	} else {
+		*count++
+	}
 }
 
 func (t *tcpTransportClient) Close() error {
-	return t.conn.Close()
+	t.Lock()
+	if t.closed {
+		t.Unlock()
+		return nil
+	}
+	t.closed = true
+	reuse := t.pool != nil && !t.failed && t.sent == t.recvd && t.inflight == 0
+	t.Unlock()
+
+	// a message in flight closes the connection, unblocking it
+	if !reuse || t.conn.SetDeadline(time.Time{}) != nil {
+		return t.conn.Close()
+	}
+
+	t.pool.put(&poolConn{addr: t.addr, conn: t.conn, codec: t.codec, raw: t.raw})
+	return nil
 }
 
 func (t *tcpTransportSocket) Local() string {
@@ -96,7 +156,7 @@ func (t *tcpTransportSocket) Recv(m *transport.Message) error {
 		t.conn.SetDeadline(time.Now().Add(t.timeout))
 	}
 
-	return t.dec.Decode(&m)
+	return t.codec.ReadMessage(m)
 }
 
 func (t *tcpTransportSocket) Send(m *transport.Message) error {
@@ -104,10 +164,7 @@ func (t *tcpTransportSocket) Send(m *transport.Message) error {
 	if t.timeout > time.Duration(0) {
 		t.conn.SetDeadline(time.Now().Add(t.timeout))
 	}
-	if err := t.enc.Encode(m); err != nil {
-		return err
-	}
-	return t.encBuf.Flush()
+	return t.codec.WriteMessage(m)
 }
 
 func (t *tcpTransportSocket) Close() error {
@@ -144,13 +201,10 @@ func (t *tcpTransportListener) Accept(fn func(transport.Socket)) error {
 			return err
 		}
 
-		encBuf := bufio.NewWriter(c)
 		sock := &tcpTransportSocket{
 			timeout: t.timeout,
 			conn:    c,
-			encBuf:  encBuf,
-			enc:     gob.NewEncoder(encBuf),
-			dec:     gob.NewDecoder(c),
+			codec:   t.framing.NewCodec(c),
 		}
 
 		go func() {
@@ -175,36 +229,83 @@ func (t *tcpTransport) Dial(addr string, opts ...transport.DialOption) (transpor
 		opt(&dopts)
 	}
 
-	var conn net.Conn
-	var err error
+	client := &tcpTransportClient{
+		dialOpts: dopts,
+		addr:     addr,
+		timeout:  t.opts.Timeout,
+		pool:     t.pool,
+	}
 
-	// TODO: support dial option here rather than using internal config
-	if t.opts.Secure || t.opts.TLSConfig != nil {
-		config := t.opts.TLSConfig
-		if config == nil {
-			config = &tls.Config{
-				InsecureSkipVerify: true,
-			}
+	if t.pool != nil {
+		if pc := t.pool.get(addr); pc != nil {
+			client.conn = pc.conn
+			client.codec = pc.codec
+			client.raw = pc.raw
+			return client, nil
 		}
-		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: dopts.Timeout}, "tcp", addr, config)
-	} else {
-		conn, err = net.DialTimeout("tcp", addr, dopts.Timeout)
 	}
 
+	conn, raw, err := t.dial(addr, dopts.Timeout)
 	if err != nil {
 		return nil, err
 	}
 
-	encBuf := bufio.NewWriter(conn)
+	client.conn = conn
+	client.codec = framing(t.opts).NewCodec(conn)
+	client.raw = raw
+	return client, nil
+}
 
-	return &tcpTransportClient{
-		dialOpts: dopts,
-		conn:     conn,
-		encBuf:   encBuf,
-		enc:      gob.NewEncoder(encBuf),
-		dec:      gob.NewDecoder(conn),
-		timeout:  t.opts.Timeout,
-	}, nil
+// dial returns the connection to the address and the tcp connection under it.
+func (t *tcpTransport) dial(addr string, timeout time.Duration) (net.Conn, net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if t.opts.Context != nil {
+		if d, ok := t.opts.Context.Value(keepAliveKey{}).(time.Duration); ok {
+			dialer.KeepAlive = d
+		}
+	}
+
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if t.opts.Context != nil {
+		if noDelay, ok := t.opts.Context.Value(noDelayKey{}).(bool); ok {
+			if tc, ok := conn.(*net.TCPConn); ok {
+				tc.SetNoDelay(noDelay)
+			}
+		}
+	}
+
+	// TODO: support dial option here rather than using internal config
+	if !t.opts.Secure && t.opts.TLSConfig == nil {
+		return conn, conn, nil
+	}
+
+	config := t.opts.TLSConfig
+	if config == nil {
+		config = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	}
+	// verify the host dialed as tls.Dial does
+	if len(config.ServerName) == 0 {
+		config = config.Clone()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			config.ServerName = host
+		}
+	}
+
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	return tlsConn, conn, nil
 }
 
 func (t *tcpTransport) Listen(addr string, opts ...transport.ListenOption) (transport.Listener, error) {
@@ -258,6 +359,7 @@ func (t *tcpTransport) Listen(addr string, opts ...transport.ListenOption) (tran
 
 	return &tcpTransportListener{
 		timeout:  t.opts.Timeout,
+		framing:  framing(t.opts),
 		listener: l,
 	}, nil
 }
@@ -266,6 +368,18 @@ func (t *tcpTransport) Init(opts ...transport.Option) error {
 	for _, o := range opts {
 		o(&t.opts)
 	}
+	if t.pool == nil {
+		t.pool = newPoolFromOptions(t.opts)
+	}
+	return nil
+}
+
+// Close closes the idle connections of the pool, the connections of the
+// clients closed afterwards aren't kept. The transport can still dial.
+func (t *tcpTransport) Close() error {
+	if t.pool != nil {
+		t.pool.closeAll()
+	}
 	return nil
 }
 
@@ -282,5 +396,5 @@ func NewTransport(opts ...transport.Option) transport.Transport {
 	for _, o := range opts {
 		o(&options)
 	}
-	return &tcpTransport{opts: options}
+	return &tcpTransport{opts: options, pool: newPoolFromOptions(options)}
 }